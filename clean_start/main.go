@@ -7,15 +7,23 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"regexp"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 const (
-	baseURL   = "https://public.api.bsky.app/xrpc/app.bsky.graph.getFollowers?actor=did%3Aplc%3Az72i7hdynmk6r22z27h6tvur&limit=30"
-	dbFile    = "followers.db"
-	tableName = "followers"
+	baseURL    = "https://public.api.bsky.app/xrpc/app.bsky.graph.getFollowers?actor=did%3Aplc%3Az72i7hdynmk6r22z27h6tvur&limit=30"
+	dbFile     = "followers.db"
+	tableName  = "followers"
+	maxRetries = 5
+)
+
+// metadataTable holds key/value state such as the cursor a failed run stopped at.
+const (
+	metadataTable = "metadata"
+	cursorKey     = "resume_cursor"
 )
 
 // Follower represents a follower's structure as per the JSON response.
@@ -44,27 +52,38 @@ func main() {
 	defer db.Close()
 	log.Println("Database initialized successfully.")
 
+	// Continue where a failed run stopped, if it left a cursor behind.
+	cursor, err := loadCursor(db)
+	if err != nil {
+		log.Fatalf("Failed to load saved cursor: %v", err)
+	}
+	if cursor != "" {
+		log.Printf("Resuming from saved cursor: %s\n", cursor)
+	}
+
 	// Start fetching followers recursively.
-	cursor := ""
 	for {
 		log.Printf("Fetching followers with cursor: %s\n", cursor)
 
 		// Fetch data from API and parse the result.
 		followers, newCursor, err := fetchFollowers(cursor)
 		if err != nil {
-			log.Fatalf("Error fetching followers: %v", err)
+			fatal(db, cursor, "Error fetching followers: %v", err)
 		}
 		log.Printf("Fetched %d followers.\n", len(followers))
 
 		// Insert followers into the database.
 		log.Println("Saving followers to the database...")
 		if err := saveFollowers(db, followers); err != nil {
-			log.Fatalf("Error saving followers: %v", err)
+			fatal(db, cursor, "Error saving followers: %v", err)
 		}
 		log.Println("Followers saved successfully.")
 
 		// If there is no new cursor, we reached the end of the data.
 		if newCursor == "" {
+			if err := saveCursor(db, ""); err != nil {
+				log.Printf("Failed to clear saved cursor: %v", err)
+			}
 			log.Println("All followers processed.")
 			break
 		}
@@ -72,8 +91,74 @@ func main() {
 	}
 }
 
+// fatal saves cursor, the page that failed, so the next run starts there, then
+// logs the error with how to resume and exits.
+func fatal(db *sql.DB, cursor, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if err := saveCursor(db, cursor); err != nil {
+		log.Fatalf("%s. Failed to save cursor %q (%v); the next run starts from the beginning.", msg, cursor, err)
+	}
+	if cursor == "" {
+		log.Fatalf("%s. Run again to start over.", msg)
+	}
+	log.Fatalf("%s. Cursor %q saved in the %s table; run again to resume from it.", msg, cursor, metadataTable)
+}
+
+// loadCursor returns the cursor saved by a failed run, or an empty string.
+func loadCursor(db *sql.DB) (string, error) {
+	table, err := quoteIdent(metadataTable)
+	if err != nil {
+		return "", err
+	}
+
+	var cursor string
+	err = db.QueryRow(fmt.Sprintf(`SELECT value FROM %s WHERE key = ?;`, table), cursorKey).Scan(&cursor)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read cursor: %w", err)
+	}
+	return cursor, nil
+}
+
+// saveCursor records the cursor to resume from; an empty cursor clears it.
+func saveCursor(db *sql.DB, cursor string) error {
+	table, err := quoteIdent(metadataTable)
+	if err != nil {
+		return err
+	}
+
+	if cursor == "" {
+		_, err = db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE key = ?;`, table), cursorKey)
+	} else {
+		_, err = db.Exec(fmt.Sprintf(`INSERT OR REPLACE INTO %s (key, value) VALUES (?, ?);`, table), cursorKey, cursor)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save cursor: %w", err)
+	}
+	return nil
+}
+
+// identPattern matches SQL identifiers that are safe to interpolate once quoted.
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// quoteIdent validates a SQL identifier and wraps it in double quotes so it can be
+// interpolated into a query without risking injection.
+func quoteIdent(name string) (string, error) {
+	if !identPattern.MatchString(name) {
+		return "", fmt.Errorf("invalid SQL identifier %q", name)
+	}
+	return `"` + name + `"`, nil
+}
+
 // initializeDB sets up the SQLite database.
 func initializeDB(dbFile string) (*sql.DB, error) {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return nil, err
+	}
+
 	db, err := sql.Open("sqlite3", dbFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -88,12 +173,21 @@ func initializeDB(dbFile string) (*sql.DB, error) {
 			createdAt DATETIME,
 			indexedAt DATETIME
 		);
-	`, tableName)
+	`, table)
 	_, err = db.Exec(createTableQuery)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create table: %w", err)
 	}
 
+	metadata, err := quoteIdent(metadataTable)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (key TEXT PRIMARY KEY, value TEXT);`, metadata))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metadata table: %w", err)
+	}
+
 	return db, nil
 }
 
@@ -158,15 +252,22 @@ func fetchFollowers(cursor string) ([]Follower, string, error) {
 
 // saveFollowers inserts followers data into the database.
 func saveFollowers(db *sql.DB, followers []Follower) error {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return err
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	// Release the write lock on failure, so the cursor can still be saved.
+	defer tx.Rollback()
 
 	stmt, err := tx.Prepare(fmt.Sprintf(`
 		INSERT OR REPLACE INTO %s (did, handle, displayName, avatar, createdAt, indexedAt) 
 		VALUES (?, ?, ?, ?, ?, ?);
-	`, tableName))
+	`, table))
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFatalSavesCursor fails a save in a child process, as fatal exits, and
+// checks the cursor of the failed page is in the database afterwards.
+func TestFatalSavesCursor(t *testing.T) {
+	if path := os.Getenv("CLEAN_START_FATAL_DB"); path != "" {
+		db, err := initializeDB(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(`DROP TABLE followers;`); err != nil {
+			t.Fatal(err)
+		}
+		if err := saveFollowers(db, []Follower{{DID: "did:plc:a", Handle: "a.test"}}); err != nil {
+			fatal(db, "cursor-2", "Error saving followers: %v", err)
+		}
+		t.Fatal("save into a dropped table succeeded")
+	}
+
+	path := filepath.Join(t.TempDir(), dbFile)
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalSavesCursor$")
+	cmd.Env = append(os.Environ(), "CLEAN_START_FATAL_DB="+path)
+	out, err := cmd.CombinedOutput()
+	if exit, ok := err.(*exec.ExitError); !ok || exit.Success() {
+		t.Fatalf("child = %v, want a non-zero exit:\n%s", err, out)
+	}
+	if !strings.Contains(string(out), `Cursor "cursor-2" saved in the metadata table`) {
+		t.Errorf("exit message does not say how to resume:\n%s", out)
+	}
+
+	db, err := initializeDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if cursor, err := loadCursor(db); err != nil || cursor != "cursor-2" {
+		t.Errorf("loadCursor = %q, %v; want the failed page's cursor-2", cursor, err)
+	}
+	if err := saveCursor(db, ""); err != nil {
+		t.Fatal(err)
+	}
+	if cursor, err := loadCursor(db); err != nil || cursor != "" {
+		t.Errorf("loadCursor after clearing = %q, %v; want none", cursor, err)
+	}
+}
@@ -0,0 +1,111 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// splitActors parses a comma-separated actor list, dropping blanks and duplicates.
+func splitActors(list string) []string {
+	var actors []string
+	seen := make(map[string]bool)
+	for _, actor := range strings.Split(list, ",") {
+		actor = strings.TrimSpace(actor)
+		if actor == "" || seen[actor] {
+			continue
+		}
+		seen[actor] = true
+		actors = append(actors, actor)
+	}
+	return actors
+}
+
+// initializeActorFollowers creates the table shared by multi-actor crawls.
+func initializeActorFollowers(db *sql.DB) error {
+	ddl, err := actorFollowersDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create actor followers table: %w", err)
+	}
+	if err := ensureColumns(db, actorFollowersTable, seqColumns); err != nil {
+		return err
+	}
+	if err := ensureColumns(db, actorFollowersTable, firstSeenColumns); err != nil {
+		return err
+	}
+	if err := ensureColumns(db, actorFollowersTable, lastSeenColumns); err != nil {
+		return err
+	}
+	return ensureColumns(db, actorFollowersTable, handleOriginalColumns)
+}
+
+// crawlActors crawls each actor's followers into the actor_followers table using
+// a pool of concurrency workers. Fetches run in parallel, sharing the client's
+// rate limiter and circuit breaker, while database writes are serialized.
+func crawlActors(ctx context.Context, db *sql.DB, client *apiClient, actors []string, concurrency int, failFast bool) error {
+	if err := initializeActorFollowers(db); err != nil {
+		return err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan string)
+	var (
+		writeMu sync.Mutex
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		failed  []string
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for actor := range jobs {
+				c := &crawler{
+					store:    newSQLiteStore(db, actorFollowersTable, actorColumns(actor)),
+					db:       db,
+					client:   client,
+					actor:    actor,
+					writeMu:  &writeMu,
+					failFast: failFast,
+				}
+				seen, err := c.crawl(ctx, "")
+				if err != nil {
+					log.Printf("Crawl of actor %s failed: %v", actor, err)
+					mu.Lock()
+					failed = append(failed, actor)
+					mu.Unlock()
+					continue
+				}
+				log.Printf("Crawled %d followers of actor %s.\n", len(seen), actor)
+			}
+		}()
+	}
+
+	for _, actor := range actors {
+		select {
+		case jobs <- actor:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d actors failed: %s", len(failed), len(actors), strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSplitActors(t *testing.T) {
+	got := splitActors(" alice.test, ,bob.test,alice.test,did:plc:c ")
+	if want := []string{"alice.test", "bob.test", "did:plc:c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("splitActors = %v, want %v", got, want)
+	}
+}
+
+// actorsAPI serves each actor's followers from its own followersAPI. The
+// first pages are held back until every actor has asked for one, so a
+// crawl only completes if the actors are fetched concurrently.
+type actorsAPI struct {
+	actors map[string]*followersAPI
+
+	mu       sync.Mutex
+	started  int
+	allReady chan struct{}
+}
+
+func (a *actorsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("cursor") == "" {
+		a.mu.Lock()
+		a.started++
+		if a.started == len(a.actors) {
+			close(a.allReady)
+		}
+		a.mu.Unlock()
+		select {
+		case <-a.allReady:
+		case <-time.After(5 * time.Second):
+			http.Error(w, "actors were not fetched concurrently", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	api, ok := a.actors[r.URL.Query().Get("actor")]
+	if !ok {
+		http.Error(w, `{"error":"InvalidRequest","message":"unknown actor"}`, http.StatusBadRequest)
+		return
+	}
+	api.ServeHTTP(w, r)
+}
+
+func TestCrawlActorsConcurrently(t *testing.T) {
+	db := openTestDB(t)
+	api := &actorsAPI{allReady: make(chan struct{}), actors: map[string]*followersAPI{
+		"did:plc:x": {pages: [][]Follower{testFollowers("x", 2), testFollowers("xx", 1)}},
+		"did:plc:y": {pages: [][]Follower{testFollowers("y", 3)}},
+		// z shares a follower with x, which is stored once per actor.
+		"did:plc:z": {pages: [][]Follower{append(testFollowers("z", 1), testFollowers("x", 1)...)}},
+	}}
+	client, _ := newTestClient(t, api)
+	client.breaker = &circuitBreaker{threshold: 1, abort: true}
+
+	if err := crawlActors(context.Background(), db, client, []string{"did:plc:x", "did:plc:y", "did:plc:z"}, 3, true); err != nil {
+		t.Fatalf("crawlActors: %v", err)
+	}
+
+	rows, err := db.Query(`SELECT actor_did, COUNT(*) FROM actor_followers GROUP BY actor_did ORDER BY actor_did;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	got := make(map[string]int)
+	for rows.Next() {
+		var actor string
+		var n int
+		if err := rows.Scan(&actor, &n); err != nil {
+			t.Fatal(err)
+		}
+		got[actor] = n
+	}
+	if want := map[string]int{"did:plc:x": 3, "did:plc:y": 3, "did:plc:z": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("followers per actor = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout bounds a single webhook delivery.
+const webhookTimeout = 10 * time.Second
+
+// churnAlert is the payload posted to the webhook when a run loses too many followers.
+type churnAlert struct {
+	Alert     string `json:"alert"`
+	Actor     string `json:"actor"`
+	RunID     int64  `json:"run_id"`
+	Lost      int    `json:"lost_followers"`
+	Threshold int    `json:"threshold"`
+}
+
+// checkChurn logs an error, and posts to webhookURL when set, if the run lost
+// more than threshold followers. A non-positive threshold disables the check.
+func checkChurn(ctx context.Context, client *http.Client, webhookURL, actor string, stats Stats, threshold int) {
+	if threshold <= 0 || stats.LostFollowers <= threshold {
+		return
+	}
+	log.Printf("ERROR: run %d lost %d followers of %s, above the churn alert threshold of %d.\n", stats.ID, stats.LostFollowers, actor, threshold)
+	if webhookURL == "" {
+		return
+	}
+
+	alert := churnAlert{Alert: "follower_churn", Actor: actor, RunID: stats.ID, Lost: stats.LostFollowers, Threshold: threshold}
+	if err := postWebhook(ctx, client, webhookURL, alert); err != nil {
+		log.Printf("Failed to deliver churn alert: %v", err)
+	}
+}
+
+// postWebhook posts payload as JSON to url.
+func postWebhook(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package crawl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestChurnAlertAboveThreshold(t *testing.T) {
+	alerts := make(chan churnAlert, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert churnAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("webhook payload: %v", err)
+		}
+		alerts <- alert
+	}))
+	defer webhook.Close()
+
+	// A run that loses three of four followers.
+	db := openTestDB(t)
+	store := newSQLiteStore(db, tableName, followerColumns)
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 4)}}
+	ctx := context.Background()
+	if _, err := newTestCrawler(t, store, api).Run(ctx); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	api.pages = [][]Follower{testFollowers("a", 1)}
+	stats, err := newTestCrawler(t, store, api).Run(ctx)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+
+	logs := captureLog(t)
+	checkChurn(ctx, webhook.Client(), webhook.URL, "did:plc:subject", stats, 3)
+	if logs.Len() != 0 || len(alerts) != 0 {
+		t.Errorf("alerted for %d lost followers at a threshold of 3:\n%s", stats.LostFollowers, logs)
+	}
+
+	checkChurn(ctx, webhook.Client(), webhook.URL, "did:plc:subject", stats, 2)
+	if !strings.Contains(logs.String(), "ERROR: run 2 lost 3 followers of did:plc:subject") {
+		t.Errorf("no error logged:\n%s", logs)
+	}
+	select {
+	case alert := <-alerts:
+		want := churnAlert{Alert: "follower_churn", Actor: "did:plc:subject", RunID: 2, Lost: 3, Threshold: 2}
+		if alert != want {
+			t.Errorf("webhook got %+v, want %+v", alert, want)
+		}
+	default:
+		t.Error("webhook was not called")
+	}
+}
+
+func TestChurnAlertsOncePerDrop(t *testing.T) {
+	var mu sync.Mutex
+	var alerts []churnAlert
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert churnAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("webhook payload: %v", err)
+		}
+		mu.Lock()
+		alerts = append(alerts, alert)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+	captureLog(t)
+
+	// One drop from four followers to one, then two more runs with the same one.
+	db := openTestDB(t)
+	ctx := context.Background()
+	for i, followers := range [][]Follower{testFollowers("a", 4), testFollowers("a", 1), testFollowers("a", 1), testFollowers("a", 1)} {
+		store := newSQLiteStore(db, tableName, followerColumns)
+		stats, err := newTestCrawler(t, store, &followersAPI{pages: [][]Follower{followers}}).Run(ctx)
+		if err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+		checkChurn(ctx, webhook.Client(), webhook.URL, "did:plc:subject", stats, 2)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(alerts) != 1 || alerts[0].RunID != 2 {
+		t.Errorf("webhook got %+v, want one alert for run 2", alerts)
+	}
+}
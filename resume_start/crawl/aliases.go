@@ -0,0 +1,152 @@
+package crawl
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+const aliasesTable = "did_aliases"
+
+// didAlias links an older DID to the newer DID now using the same handle.
+type didAlias struct {
+	Handle string
+	OldDID string
+	NewDID string
+}
+
+// initializeAliases creates the table recording DIDs that share a handle.
+func initializeAliases(db *sql.DB) error {
+	ddl, err := aliasesDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create aliases table: %w", err)
+	}
+	return nil
+}
+
+// aliasesDDL returns the statements creating the DID aliases table.
+func aliasesDDL() (string, error) {
+	table, err := quoteIdent(aliasesTable)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			handle TEXT,
+			old_did TEXT,
+			new_did TEXT,
+			detected_at DATETIME,
+			PRIMARY KEY (old_did, new_did)
+		);
+	`, table), nil
+}
+
+// findAliases returns, for every handle held by more than one DID, an alias from
+// each older DID to the most recently indexed one.
+func findAliases(db *sql.DB) ([]didAlias, error) {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT handle, did FROM %s
+		WHERE handle IN (SELECT handle FROM %s WHERE handle != '' GROUP BY handle HAVING COUNT(*) > 1)
+		ORDER BY handle, indexedAt DESC, did;
+	`, table, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shared handles: %w", err)
+	}
+	defer rows.Close()
+
+	var aliases []didAlias
+	var handle, newest string
+	for rows.Next() {
+		var h, did string
+		if err := rows.Scan(&h, &did); err != nil {
+			return nil, fmt.Errorf("failed to scan shared handle: %w", err)
+		}
+		if h != handle {
+			handle, newest = h, did
+			continue
+		}
+		aliases = append(aliases, didAlias{Handle: h, OldDID: did, NewDID: newest})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate shared handles: %w", err)
+	}
+	return aliases, nil
+}
+
+// reconcileHandles records an alias for every DID whose handle was taken over by
+// a newer DID. When merge is set the older rows are deleted, keeping the newer DID.
+func reconcileHandles(db *sql.DB, merge bool) error {
+	if err := initializeAliases(db); err != nil {
+		return err
+	}
+
+	aliases, err := findAliases(db)
+	if err != nil {
+		return err
+	}
+	if len(aliases) == 0 {
+		log.Println("No DIDs share a handle.")
+		return nil
+	}
+
+	aliasTable, err := quoteIdent(aliasesTable)
+	if err != nil {
+		return err
+	}
+	followersTable, err := quoteIdent(tableName)
+	if err != nil {
+		return err
+	}
+	labelTable, err := quoteIdent(labelsTable)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for _, alias := range aliases {
+		log.Printf("Handle %s is shared by %s (older) and %s (newer).\n", alias.Handle, alias.OldDID, alias.NewDID)
+		_, err := tx.Exec(fmt.Sprintf(`
+			INSERT OR IGNORE INTO %s (handle, old_did, new_did, detected_at) VALUES (?, ?, ?, ?);
+		`, aliasTable), alias.Handle, alias.OldDID, alias.NewDID, now)
+		if err != nil {
+			return fmt.Errorf("failed to record alias for %s: %w", alias.OldDID, err)
+		}
+
+		if !merge {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE did = ?;`, followersTable), alias.OldDID); err != nil {
+			return fmt.Errorf("failed to merge %s: %w", alias.OldDID, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE did = ?;`, labelTable), alias.OldDID); err != nil {
+			return fmt.Errorf("failed to merge labels of %s: %w", alias.OldDID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if merge {
+		log.Printf("Recorded and merged %d DID aliases.\n", len(aliases))
+	} else {
+		log.Printf("Recorded %d DID aliases. Pass -merge-handles to keep only the newer DIDs.\n", len(aliases))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package crawl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconcileHandlesRecordsAlias(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Now().UTC()
+	seedFollowers(t, db,
+		Follower{DID: "did:plc:old", Handle: "alice.test", IndexedAt: bskyTime{now.Add(-48 * time.Hour)}},
+		Follower{DID: "did:plc:new", Handle: "alice.test", IndexedAt: bskyTime{now}},
+		Follower{DID: "did:plc:bob", Handle: "bob.test", IndexedAt: bskyTime{now}},
+	)
+
+	if err := reconcileHandles(db, false); err != nil {
+		t.Fatalf("reconcileHandles: %v", err)
+	}
+	var handle, oldDID, newDID string
+	if err := db.QueryRow(`SELECT handle, old_did, new_did FROM did_aliases;`).Scan(&handle, &oldDID, &newDID); err != nil {
+		t.Fatalf("reading alias: %v", err)
+	}
+	if handle != "alice.test" || oldDID != "did:plc:old" || newDID != "did:plc:new" {
+		t.Errorf("alias = %s %s -> %s, want alice.test did:plc:old -> did:plc:new", handle, oldDID, newDID)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM followers;`).Scan(&n); err != nil || n != 3 {
+		t.Errorf("followers without -merge-handles = %d, %v; want all 3 kept", n, err)
+	}
+
+	if err := reconcileHandles(db, true); err != nil {
+		t.Fatalf("reconcileHandles with merge: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM followers WHERE did = 'did:plc:old';`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("older DID rows after merging = %d, %v; want 0", n, err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM did_aliases;`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("aliases after reconciling twice = %d, %v; want 1", n, err)
+	}
+}
@@ -0,0 +1,155 @@
+package crawl
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// anomalySigma is how many standard deviations a run's change in followers
+// must differ from the actor's other changes to be reported, from -anomaly-sigma.
+var anomalySigma = 3.0
+
+// minAnomalyHistory is the number of changes an actor needs before any of them
+// is judged; with fewer there is no telling what is normal.
+const minAnomalyHistory = 4
+
+// runCount is the number of followers a completed run saw.
+type runCount struct {
+	id         int64
+	actor      string
+	finishedAt time.Time
+	followers  int
+}
+
+// anomaly is a run whose change in followers since the previous run stands out.
+type anomaly struct {
+	run    runCount
+	change int     // followers gained (positive) or lost (negative) since the previous run
+	score  float64 // standard deviations away from the mean of the other changes
+}
+
+// findAnomalies compares each run's change in followers with the actor's
+// other changes, in run order, and returns those more than sigma standard
+// deviations from their mean. Each change is measured against the others, so
+// a single large outlier doesn't inflate the deviation it is judged by.
+func findAnomalies(counts []runCount, sigma float64) []anomaly {
+	byActor := make(map[string][]runCount)
+	var actors []string
+	for _, c := range counts {
+		if _, ok := byActor[c.actor]; !ok {
+			actors = append(actors, c.actor)
+		}
+		byActor[c.actor] = append(byActor[c.actor], c)
+	}
+
+	var found []anomaly
+	for _, actor := range actors {
+		runs := byActor[actor]
+		if len(runs)-1 < minAnomalyHistory {
+			continue
+		}
+		changes := make([]float64, len(runs)-1)
+		for i := 1; i < len(runs); i++ {
+			changes[i-1] = float64(runs[i].followers - runs[i-1].followers)
+		}
+		for i, change := range changes {
+			mean, stddev := meanStddevExcept(changes, i)
+			score := math.Inf(1)
+			if stddev > 0 {
+				score = math.Abs(change-mean) / stddev
+			} else if change == mean {
+				score = 0
+			}
+			if score > sigma {
+				found = append(found, anomaly{run: runs[i+1], change: int(change), score: score})
+			}
+		}
+	}
+	return found
+}
+
+// meanStddevExcept returns the mean and population standard deviation of
+// values without the one at skip.
+func meanStddevExcept(values []float64, skip int) (mean, stddev float64) {
+	n := float64(len(values) - 1)
+	for i, v := range values {
+		if i != skip {
+			mean += v
+		}
+	}
+	mean /= n
+	for i, v := range values {
+		if i != skip {
+			stddev += (v - mean) * (v - mean)
+		}
+	}
+	return mean, math.Sqrt(stddev / n)
+}
+
+// loadRunCounts returns the follower counts of completed runs, oldest first.
+// Runs resumed from a cursor saw only part of the list and would look like
+// drops, so they are left out.
+func loadRunCounts(db *sql.DB) ([]runCount, error) {
+	table, err := quoteIdent(runsTable)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT id, COALESCE(actor, ''), finished_at, followers_seen
+		FROM %s WHERE status = 'completed' AND followers_seen IS NOT NULL AND COALESCE(start_cursor, '') = ''
+		ORDER BY id;
+	`, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runs: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []runCount
+	for rows.Next() {
+		var c runCount
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&c.id, &c.actor, &finishedAt, &c.followers); err != nil {
+			return nil, fmt.Errorf("failed to scan run: %w", err)
+		}
+		c.finishedAt = finishedAt.Time
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate runs: %w", err)
+	}
+	return counts, nil
+}
+
+// anomaliesReport lists completed runs whose change in followers stands out
+// from the actor's history: a jump may be a bot raid, a drop a mass block.
+func anomaliesReport(db *sql.DB) (reportTable, error) {
+	counts, err := loadRunCounts(db)
+	if err != nil {
+		return reportTable{}, err
+	}
+
+	report := reportTable{columns: []string{"run_id", "actor", "finished_at", "followers", "change", "direction", "sigma"}}
+	for _, a := range findAnomalies(counts, anomalySigma) {
+		direction := "jump"
+		if a.change < 0 {
+			direction = "drop"
+		}
+		finishedAt := ""
+		if !a.run.finishedAt.IsZero() {
+			finishedAt = a.run.finishedAt.UTC().Format(time.RFC3339)
+		}
+		score := "inf"
+		if !math.IsInf(a.score, 1) {
+			score = strconv.FormatFloat(a.score, 'f', 1, 64)
+		}
+		report.rows = append(report.rows, []string{
+			strconv.FormatInt(a.run.id, 10), a.run.actor, finishedAt,
+			strconv.Itoa(a.run.followers), strconv.Itoa(a.change), direction, score,
+		})
+	}
+	return report, nil
+}
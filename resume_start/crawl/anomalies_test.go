@@ -0,0 +1,57 @@
+package crawl
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestAnomaliesReportFlagsOutlier(t *testing.T) {
+	db := openTestDB(t)
+	record := func(actor, startCursor string, followers int) int64 {
+		t.Helper()
+		id, err := startRun(db, actor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := finishRun(db, id, "completed", startCursor, followers, nil); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	var raid int64
+	for _, n := range []int{100, 102, 101, 103, 102, 104, 500, 502} {
+		id := record("did:plc:a", "", n)
+		if n == 500 {
+			raid = id
+		}
+		// Resumed runs see part of the list and are not counted.
+		record("did:plc:a", "c5", 10)
+	}
+	// Too little history to judge.
+	for _, n := range []int{10, 1000, 10} {
+		record("did:plc:b", "", n)
+	}
+
+	report, err := anomaliesReport(db)
+	if err != nil {
+		t.Fatalf("anomaliesReport: %v", err)
+	}
+	if len(report.rows) != 1 {
+		t.Fatalf("flagged %d runs, want only the jump: %v", len(report.rows), report.rows)
+	}
+	row := report.rows[0]
+	if row[0] != strconv.FormatInt(raid, 10) || row[1] != "did:plc:a" || row[3] != "500" || row[4] != "396" || row[5] != "jump" {
+		t.Errorf("flagged run = %v, want run %d jumping by 396 to 500", row, raid)
+	}
+}
+
+func TestFindAnomaliesFlagsDrop(t *testing.T) {
+	var counts []runCount
+	for i, n := range []int{1000, 1001, 1003, 1002, 1004, 600, 601} {
+		counts = append(counts, runCount{id: int64(i + 1), actor: "did:plc:a", followers: n})
+	}
+	found := findAnomalies(counts, 3)
+	if len(found) != 1 || found[0].run.id != 6 || found[0].change != -404 {
+		t.Fatalf("findAnomalies = %+v, want run 6 dropping by 404", found)
+	}
+}
@@ -0,0 +1,226 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// recrawlTable is where a -atomic-recrawl run saves followers until it
+// completes and the table replaces the followers table.
+const recrawlTable = "followers_recrawl"
+
+// recrawlLabelsTable is where a -atomic-recrawl run saves the normalized labels
+// of its followers until they replace those of the followers table.
+const recrawlLabelsTable = "follower_labels_recrawl"
+
+// runAtomic runs a full crawl into the recrawl table and, once the crawl
+// completes, swaps it in for the store's table in one transaction. Readers
+// see either the previous list or the new one, never a partial crawl; a run
+// that doesn't complete leaves the store's table as it was.
+func (cr *Crawler) runAtomic(ctx context.Context, runID int64) (Stats, error) {
+	target := cr.store.(*sqliteStore)
+	staging := newSQLiteStore(target.db, recrawlTable, target.columns)
+	if err := resetRecrawlTable(ctx, staging); err != nil {
+		return Stats{}, err
+	}
+
+	cr.store = staging
+	stats, err := cr.run(ctx, runID)
+	cr.store = target
+	if stats.Status != "completed" {
+		cr.logf("Recrawl ended %s, keeping the previous %s table.\n", stats.Status, target.table)
+		return stats, err
+	}
+
+	if err := cr.locked(func() error { return swapRecrawlTable(ctx, staging, target) }); err != nil {
+		// The diff recorded for the run describes a list that was never swapped in.
+		cr.baseline = nil
+		stats.Status, stats.Err = "failed", fmt.Errorf("failed to swap in recrawled followers: %w", err)
+		return stats, stats.Err
+	}
+	cr.logf("Swapped the recrawled followers into the %s table.\n", target.table)
+	return stats, nil
+}
+
+// resetRecrawlTable drops whatever an earlier recrawl left in the staging
+// tables and creates them empty.
+func resetRecrawlTable(ctx context.Context, staging *sqliteStore) error {
+	for _, name := range []string{staging.table, recrawlLabelsTable} {
+		table, err := quoteIdent(name)
+		if err != nil {
+			return err
+		}
+		if _, err := staging.db.ExecContext(ctx, fmt.Sprintf(`DROP TABLE IF EXISTS %s;`, table)); err != nil {
+			return fmt.Errorf("failed to drop %s table: %w", name, err)
+		}
+	}
+	ddl, err := labelsTableDDL(recrawlLabelsTable)
+	if err != nil {
+		return err
+	}
+	if _, err := staging.db.ExecContext(ctx, ddl); err != nil {
+		return fmt.Errorf("failed to create %s table: %w", recrawlLabelsTable, err)
+	}
+	return staging.Init(ctx)
+}
+
+// swapRecrawlTable replaces target's table with staging's in one transaction.
+// What the crawl doesn't fetch is carried over from the rows being replaced:
+// preserved columns such as first_seen, and columns other steps added, such
+// as those of the enrich pass. The staged labels replace those of the old
+// rows, and the handles of the new ones are recorded as identities.
+func swapRecrawlTable(ctx context.Context, staging, target *sqliteStore) error {
+	from, err := quoteIdent(staging.table)
+	if err != nil {
+		return err
+	}
+	to, err := quoteIdent(target.table)
+	if err != nil {
+		return err
+	}
+
+	tx, err := target.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	existing, err := columnTypes(ctx, tx, to)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for _, col := range staging.columns {
+		if col.key {
+			keys = append(keys, fmt.Sprintf("old.%[1]s = %[2]s.%[1]s", col.name, from))
+		}
+	}
+	match := strings.Join(keys, " AND ")
+
+	var carried []string
+	for _, col := range staging.columns {
+		if col.preserve && existing[col.name] != "" {
+			carried = append(carried, col.name)
+		}
+	}
+	names := make([]string, 0, len(existing))
+	for name := range existing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if hasColumn(staging.columns, name) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s;`, from, name, existing[name])); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", name, err)
+		}
+		carried = append(carried, name)
+	}
+	for _, name := range carried {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+			UPDATE %[1]s SET %[2]s = (SELECT old.%[2]s FROM %[3]s old WHERE %[4]s)
+			WHERE EXISTS (SELECT 1 FROM %[3]s old WHERE %[4]s);
+		`, from, name, to, match)); err != nil {
+			return fmt.Errorf("failed to carry over column %s: %w", name, err)
+		}
+	}
+
+	if err := swapRecrawlLabels(ctx, tx, to); err != nil {
+		return err
+	}
+	if err := recordStagedIdentities(ctx, tx, from); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DROP TABLE %s;`, to)); err != nil {
+		return fmt.Errorf("failed to drop %s table: %w", target.table, err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s RENAME TO %s;`, from, to)); err != nil {
+		return fmt.Errorf("failed to rename %s table: %w", staging.table, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// swapRecrawlLabels replaces the labels of the followers in table (already
+// quoted) with the staged labels of the recrawl, within tx.
+func swapRecrawlLabels(ctx context.Context, tx *sql.Tx, table string) error {
+	labels, err := quoteIdent(labelsTable)
+	if err != nil {
+		return err
+	}
+	staged, err := quoteIdent(recrawlLabelsTable)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE did IN (SELECT did FROM %s);`, labels, table)); err != nil {
+		return fmt.Errorf("failed to clear replaced labels: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`INSERT OR IGNORE INTO %s (did, type, value) SELECT did, type, value FROM %s;`, labels, staged)); err != nil {
+		return fmt.Errorf("failed to copy recrawled labels: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DROP TABLE %s;`, staged)); err != nil {
+		return fmt.Errorf("failed to drop %s table: %w", recrawlLabelsTable, err)
+	}
+	return nil
+}
+
+// recordStagedIdentities records the handles of the followers in the staging
+// table (already quoted) within tx.
+func recordStagedIdentities(ctx context.Context, tx *sql.Tx, staging string) error {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT did, COALESCE(handle, '') FROM %s;`, staging))
+	if err != nil {
+		return fmt.Errorf("failed to query recrawled identities: %w", err)
+	}
+	var ids []identity
+	for rows.Next() {
+		var id identity
+		if err := rows.Scan(&id.did, &id.handle); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan recrawled identity: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate recrawled identities: %w", err)
+	}
+	return recordIdentities(ctx, tx, ids, time.Now().UTC())
+}
+
+// columnTypes returns the declared type of each column of table (already
+// quoted), keyed by name.
+func columnTypes(ctx context.Context, db dbtx, table string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`PRAGMA table_info(%s);`, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table info: %w", err)
+	}
+	defer rows.Close()
+
+	types := make(map[string]string)
+	for rows.Next() {
+		var (
+			cid          int
+			name, typ    string
+			notNull, pk  int
+			defaultValue sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("failed to scan table info: %w", err)
+		}
+		types[name] = typ
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate table info: %w", err)
+	}
+	return types, nil
+}
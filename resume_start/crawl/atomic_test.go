@@ -0,0 +1,98 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// tableSnapshot returns the rows of query as strings, in order.
+func tableSnapshot(t *testing.T, db *sql.DB, query string) []string {
+	t.Helper()
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var a, b string
+		if err := rows.Scan(&a, &b); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, a+" "+b)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// recrawlState returns the followers, labels and identities stored in db.
+func recrawlState(t *testing.T, db *sql.DB) [][]string {
+	t.Helper()
+	return [][]string{
+		tableSnapshot(t, db, `SELECT did, handle FROM followers ORDER BY did;`),
+		tableSnapshot(t, db, `SELECT did, value FROM follower_labels ORDER BY did, value;`),
+		tableSnapshot(t, db, `SELECT did, handle FROM identities ORDER BY did;`),
+	}
+}
+
+func TestInterruptedRecrawlKeepsPreviousTables(t *testing.T) {
+	db := openTestDB(t)
+	api := &followersAPI{pages: [][]Follower{
+		{{DID: "did:plc:a", Handle: "a.test", Labels: []Label{{Type: "t", Value: "old"}}}},
+		{{DID: "did:plc:b", Handle: "b.test"}},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var interrupt bool
+	c := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if interrupt && r.URL.Query().Get("cursor") == "c1" {
+			cancel()
+		}
+		api.ServeHTTP(w, r)
+	}))
+	c.atomicRecrawl = true
+	captureLog(t)
+
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("first recrawl: %v", err)
+	}
+	before := recrawlState(t, db)
+	want := [][]string{{"did:plc:a a.test", "did:plc:b b.test"}, {"did:plc:a old"}, {"did:plc:a a.test", "did:plc:b b.test"}}
+	if !reflect.DeepEqual(before, want) {
+		t.Fatalf("after the first recrawl = %v, want %v", before, want)
+	}
+
+	// The next recrawl renames a, relabels it and finds c, then is interrupted.
+	api.mu.Lock()
+	api.pages = [][]Follower{
+		{{DID: "did:plc:a", Handle: "renamed.test", Labels: []Label{{Type: "t", Value: "new"}}}, {DID: "did:plc:c", Handle: "c.test", Labels: []Label{{Type: "t", Value: "c"}}}},
+		{{DID: "did:plc:d", Handle: "d.test"}},
+	}
+	api.mu.Unlock()
+	interrupt = true
+	stats, _ := c.Run(ctx)
+	if stats.Status != "interrupted" {
+		t.Fatalf("interrupted recrawl ended %q", stats.Status)
+	}
+	if got := recrawlState(t, db); !reflect.DeepEqual(got, before) {
+		t.Errorf("after an interrupted recrawl = %v, want it unchanged: %v", got, before)
+	}
+
+	interrupt = false
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("completed recrawl: %v", err)
+	}
+	want = [][]string{
+		{"did:plc:a renamed.test", "did:plc:c c.test", "did:plc:d d.test"},
+		{"did:plc:a new", "did:plc:c c"},
+		{"did:plc:a renamed.test", "did:plc:b b.test", "did:plc:c c.test", "did:plc:d d.test"},
+	}
+	if got := recrawlState(t, db); !reflect.DeepEqual(got, want) {
+		t.Errorf("after a completed recrawl = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,224 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// avatarColumns are added to the followers table by -download-avatars.
+var avatarColumns = map[string]string{"avatar_path": "TEXT"}
+
+// maxAvatarSize bounds a single avatar download.
+const maxAvatarSize = 10 << 20
+
+// defaultAvatarHost serves the avatars the API links to.
+const defaultAvatarHost = "https://cdn.bsky.app"
+
+var (
+	// normalizeAvatars is set by -normalize-avatar-host: avatars are stored as
+	// the path of their URL, without the CDN host, so stored data survives a
+	// change of host.
+	normalizeAvatars bool
+	// avatarHost is what stored avatar paths are resolved against, set by -avatar-host.
+	avatarHost = defaultAvatarHost
+)
+
+// stripAvatarHost returns the path and query of an absolute avatar URL, which
+// identify the image by DID and CID. Anything else is returned unchanged.
+func stripAvatarHost(avatar string) string {
+	u, err := url.Parse(avatar)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return avatar
+	}
+	return u.RequestURI()
+}
+
+// resolveAvatar turns a stored avatar path back into a URL on avatarHost.
+// Absolute URLs, stored without -normalize-avatar-host, are left as they are.
+func resolveAvatar(avatar string) string {
+	if !strings.HasPrefix(avatar, "/") {
+		return avatar
+	}
+	return strings.TrimRight(avatarHost, "/") + avatar
+}
+
+// avatarJob is a follower whose avatar should be stored locally.
+type avatarJob struct {
+	did, url, path string
+}
+
+// avatarFileName returns the file an avatar is stored in. Colons in the DID are
+// replaced so the name is valid on every filesystem.
+func avatarFileName(did string) string {
+	return strings.ReplaceAll(did, ":", "_") + ".jpg"
+}
+
+// downloadAvatars stores the avatar of every follower that has one in dir as
+// <did>.jpg, using concurrency workers that share the client's rate limiter.
+// Files that already exist are not downloaded again. The local path is recorded
+// in the avatar_path column.
+func downloadAvatars(ctx context.Context, db *sql.DB, client *apiClient, dir string, concurrency int) error {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return err
+	}
+	if err := ensureColumns(db, tableName, avatarColumns); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create avatar directory: %w", err)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`SELECT did, avatar FROM %s WHERE avatar IS NOT NULL AND avatar != '' ORDER BY did;`, table))
+	if err != nil {
+		return fmt.Errorf("failed to query avatars: %w", err)
+	}
+	var pending []avatarJob
+	stored := make(map[string]string)
+	for rows.Next() {
+		var job avatarJob
+		if err := rows.Scan(&job.did, &job.url); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan avatar: %w", err)
+		}
+		job.url = resolveAvatar(job.url)
+		job.path = filepath.Join(dir, avatarFileName(job.did))
+		if _, err := os.Stat(job.path); err == nil {
+			stored[job.did] = job.path
+			continue
+		}
+		pending = append(pending, job)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate avatars: %w", err)
+	}
+	log.Printf("Downloading %d avatars, %d already present.\n", len(pending), len(stored))
+
+	jobs := make(chan avatarJob)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				err := downloadAvatar(ctx, client, job.url, job.path)
+				mu.Lock()
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Printf("Failed to download avatar of %s: %v", job.did, err)
+					}
+					failed++
+				} else {
+					stored[job.did] = job.path
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, job := range pending {
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Record what is on disk even when interrupted, so a rerun skips it.
+	if err := saveAvatarPaths(db, table, stored); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	log.Printf("Avatars stored for %d followers, %d downloads failed.\n", len(stored), failed)
+	return nil
+}
+
+// downloadAvatar fetches url into path, writing through a temporary file so an
+// interrupted download never leaves a partial image behind.
+func downloadAvatar(ctx context.Context, client *apiClient, url, path string) error {
+	if err := client.limiter.wait(ctx); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build avatar request: %w", err)
+	}
+	resp, err := client.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch avatar: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "image/") {
+		return fmt.Errorf("unexpected content type %q", contentType)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".avatar-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, io.LimitReader(resp.Body, maxAvatarSize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write avatar: %w", err)
+	}
+	if n > maxAvatarSize {
+		return errors.New("avatar exceeds maximum size")
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// saveAvatarPaths records the local avatar file of each follower.
+func saveAvatarPaths(db *sql.DB, table string, paths map[string]string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`UPDATE %s SET avatar_path = ? WHERE did = ?;`, table))
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for did, path := range paths {
+		if _, err := stmt.Exec(path, did); err != nil {
+			return fmt.Errorf("failed to record avatar of %s: %w", did, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
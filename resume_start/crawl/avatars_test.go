@@ -0,0 +1,106 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestDownloadAvatarsSkipsExisting(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/html" {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html>not found</html>"))
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("jpeg" + r.URL.Path))
+	}))
+	db := openTestDB(t)
+	seedFollowers(t, db,
+		Follower{DID: "did:plc:a", Handle: "a.test", Avatar: client.serviceURL + "/a"},
+		Follower{DID: "did:plc:b", Handle: "b.test", Avatar: client.serviceURL + "/b"},
+		Follower{DID: "did:plc:c", Handle: "c.test", Avatar: client.serviceURL + "/html"},
+		Follower{DID: "did:plc:d", Handle: "d.test"},
+	)
+	dir := filepath.Join(t.TempDir(), "avatars")
+	ctx := context.Background()
+
+	if err := downloadAvatars(ctx, db, client, dir, 2); err != nil {
+		t.Fatalf("downloadAvatars: %v", err)
+	}
+	for did, want := range map[string]string{"did:plc:a": "jpeg/a", "did:plc:b": "jpeg/b"} {
+		path := filepath.Join(dir, avatarFileName(did))
+		if data, err := os.ReadFile(path); err != nil || string(data) != want {
+			t.Errorf("avatar of %s = %q, %v; want %q", did, data, err, want)
+		}
+		var stored string
+		if err := db.QueryRow(`SELECT avatar_path FROM followers WHERE did = ?;`, did).Scan(&stored); err != nil || stored != path {
+			t.Errorf("avatar_path of %s = %q, %v; want %q", did, stored, err, path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, avatarFileName("did:plc:c"))); !os.IsNotExist(err) {
+		t.Errorf("avatar served as text/html was written: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("avatar directory holds %d files, want 2 and no leftover temporary files", len(entries))
+	}
+
+	if err := downloadAvatars(ctx, db, client, dir, 2); err != nil {
+		t.Fatalf("second downloadAvatars: %v", err)
+	}
+	if requests["/a"] != 1 || requests["/b"] != 1 || requests["/html"] != 2 {
+		t.Errorf("requests = %v, want stored avatars fetched once and the failed one retried", requests)
+	}
+}
+
+func TestNormalizedAvatarRoundTrip(t *testing.T) {
+	const avatar = "https://cdn.bsky.app/img/avatar/plain/did:plc:a/bafkreiabc@jpeg"
+	for in, want := range map[string]string{
+		avatar:                 "/img/avatar/plain/did:plc:a/bafkreiabc@jpeg",
+		"https://cdn.test/x?y": "/x?y",
+		"":                     "",
+		"not a url":            "not a url",
+	} {
+		if got := stripAvatarHost(in); got != want {
+			t.Errorf("stripAvatarHost(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	savedNormalize, savedHost := normalizeAvatars, avatarHost
+	t.Cleanup(func() { normalizeAvatars, avatarHost = savedNormalize, savedHost })
+	normalizeAvatars = true
+	db := openTestDB(t)
+	seedFollowers(t, db, Follower{DID: "did:plc:a", Handle: "a.test", Avatar: avatar})
+
+	var stored string
+	if err := db.QueryRow(`SELECT avatar FROM followers WHERE did = 'did:plc:a';`).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != "/img/avatar/plain/did:plc:a/bafkreiabc@jpeg" {
+		t.Errorf("stored avatar %q, want the path without the host", stored)
+	}
+
+	avatarHost = "https://images.example/"
+	var buf bytes.Buffer
+	if err := exportFollowers(db, "json", &buf, exportOptions{}); err != nil {
+		t.Fatalf("exportFollowers: %v", err)
+	}
+	var exported []Follower
+	if err := json.Unmarshal(buf.Bytes(), &exported); err != nil || len(exported) != 1 {
+		t.Fatalf("export = %v, %s", err, buf.String())
+	}
+	if want := "https://images.example/img/avatar/plain/did:plc:a/bafkreiabc@jpeg"; exported[0].Avatar != want {
+		t.Errorf("exported avatar %q, want %q", exported[0].Avatar, want)
+	}
+}
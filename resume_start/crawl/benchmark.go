@@ -0,0 +1,112 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// benchmarkResult is the outcome of a -benchmark run.
+type benchmarkResult struct {
+	rows    int
+	elapsed time.Duration
+}
+
+// rowsPerSecond returns the measured insert throughput.
+func (r benchmarkResult) rowsPerSecond() float64 {
+	if r.elapsed <= 0 {
+		return 0
+	}
+	return float64(r.rows) / r.elapsed.Seconds()
+}
+
+// plcAlphabet is the base32 alphabet did:plc identifiers are written in.
+const plcAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+
+// syntheticFollowers returns n followers with distinct, well-formed DIDs and
+// handles and a typical amount of profile text.
+func syntheticFollowers(n int) []Follower {
+	now := time.Now().UTC()
+	followers := make([]Follower, n)
+	for i := range followers {
+		id := make([]byte, 24)
+		for j, v := len(id)-1, i; j >= 0; j-- {
+			id[j] = plcAlphabet[v%len(plcAlphabet)]
+			v /= len(plcAlphabet)
+		}
+		handle := "bench" + strconv.Itoa(i) + ".bsky.social"
+		followers[i] = Follower{
+			DID:         "did:plc:" + string(id),
+			Handle:      handle,
+			DisplayName: "Benchmark follower " + strconv.Itoa(i),
+			Avatar:      defaultAvatarHost + "/img/avatar/plain/did:plc:" + string(id) + "/bafkrei@jpeg",
+			Description: "Synthetic follower generated by -benchmark to measure insert throughput.",
+			CreatedAt:   bskyTime{now},
+			IndexedAt:   bskyTime{now},
+		}
+	}
+	return followers
+}
+
+// runBenchmark saves n synthetic followers through the crawl's save path, in
+// pages of pageLimit and committed per commitBatch rows as a crawl would, and
+// measures how long that takes. It writes to a scratch database in dir that is
+// removed afterwards, so the real database is left alone; the schema options
+// in effect, such as -minimal and -label-format, apply to it.
+func runBenchmark(ctx context.Context, dir string, n, commitBatch int) (benchmarkResult, error) {
+	tmp, err := os.MkdirTemp(dir, "benchmark-")
+	if err != nil {
+		return benchmarkResult{}, fmt.Errorf("failed to create benchmark directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	db, err := initializeDB(filepath.Join(tmp, "benchmark.db"))
+	if err != nil {
+		return benchmarkResult{}, err
+	}
+	defer db.Close()
+	if validateDIDs {
+		if err := initializeInvalidFollowers(db); err != nil {
+			return benchmarkResult{}, err
+		}
+	}
+	store := newSQLiteStore(db, tableName, followerColumns)
+	if err := store.Init(ctx); err != nil {
+		return benchmarkResult{}, err
+	}
+
+	c := &crawler{store: store, db: db, actor: "benchmark", commitBatch: commitBatch}
+	followers := syntheticFollowers(n)
+	start := time.Now()
+	for i := 0; i < len(followers); i += pageLimit {
+		end := i + pageLimit
+		if end > len(followers) {
+			end = len(followers)
+		}
+		if _, err := c.buffer(ctx, strconv.Itoa(i), strconv.Itoa(end), followers[i:end]); err != nil {
+			return benchmarkResult{}, err
+		}
+	}
+	if err := c.flush(ctx); err != nil {
+		return benchmarkResult{}, err
+	}
+	result := benchmarkResult{elapsed: time.Since(start)}
+
+	if result.rows, err = store.Count(ctx); err != nil {
+		return benchmarkResult{}, err
+	}
+	if result.rows != n {
+		return result, fmt.Errorf("saved %d of %d synthetic followers", result.rows, n)
+	}
+	return result, nil
+}
+
+// writeBenchmark prints the result of a -benchmark run.
+func writeBenchmark(w io.Writer, r benchmarkResult) error {
+	_, err := fmt.Fprintf(w, "Saved %d followers in %s: %.0f rows/sec\n", r.rows, r.elapsed.Round(time.Millisecond), r.rowsPerSecond())
+	return err
+}
@@ -0,0 +1,47 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBenchmarkReportsThroughput(t *testing.T) {
+	dir := t.TempDir()
+	result, err := runBenchmark(context.Background(), dir, 250, 100)
+	if err != nil {
+		t.Fatalf("runBenchmark: %v", err)
+	}
+	if result.rows != 250 || result.rowsPerSecond() <= 0 {
+		t.Errorf("benchmark saved %d rows at %.0f rows/sec, want 250 at a positive rate", result.rows, result.rowsPerSecond())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("benchmark left %d entries behind in its directory", len(entries))
+	}
+
+	var buf bytes.Buffer
+	if err := writeBenchmark(&buf, result); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "Saved 250 followers in ") || !strings.Contains(buf.String(), "rows/sec") {
+		t.Errorf("benchmark output = %q", buf.String())
+	}
+}
+
+func TestSyntheticFollowersAreWellFormed(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, f := range syntheticFollowers(100) {
+		if problem := didProblem(f.DID); problem != "" {
+			t.Errorf("synthetic DID %s: %s", f.DID, problem)
+		}
+		if seen[f.DID] {
+			t.Errorf("synthetic DID %s repeated", f.DID)
+		}
+		seen[f.DID] = true
+		if !strings.HasPrefix(f.Avatar, defaultAvatarHost+"/") {
+			t.Errorf("synthetic avatar %q is not on %s", f.Avatar, defaultAvatarHost)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package crawl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxCaptureCursorLen bounds the part of a capture file name taken from the cursor.
+const maxCaptureCursorLen = 100
+
+// pageCapture archives the raw body of every followers page fetched, so a crawl
+// can be replayed later with -from-stdin.
+type pageCapture struct {
+	dir   string
+	mu    sync.Mutex
+	pages int
+}
+
+// newPageCapture creates dir if needed and returns a capture writing into it.
+func newPageCapture(dir string) (*pageCapture, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	return &pageCapture{dir: dir}, nil
+}
+
+// save writes body to dir/page-<n>-<cursor>.json, numbering pages from 1 in the
+// order they were fetched.
+func (p *pageCapture) save(cursor string, body []byte) error {
+	p.mu.Lock()
+	p.pages++
+	n := p.pages
+	p.mu.Unlock()
+
+	path := filepath.Join(p.dir, fmt.Sprintf("page-%d-%s.json", n, captureName(cursor)))
+	if err := writeFileAtomic(path, body); err != nil {
+		return fmt.Errorf("failed to capture page %d: %w", n, err)
+	}
+	return nil
+}
+
+// captureName makes cursor safe to use in a file name: anything other than
+// ASCII letters, digits, dots, dashes and underscores becomes an underscore.
+// The first page, which has no cursor, is named "start".
+func captureName(cursor string) string {
+	if cursor == "" {
+		return "start"
+	}
+	if len(cursor) > maxCaptureCursorLen {
+		cursor = cursor[:maxCaptureCursorLen]
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, cursor)
+}
@@ -0,0 +1,68 @@
+package crawl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestCaptureWritesOneFilePerPage(t *testing.T) {
+	// Cursors with slashes and other characters unsafe in file names.
+	pages := map[string]APIResponse{
+		"":            {Followers: testFollowers("a", 2), Cursor: "2024/01:x+y"},
+		"2024/01:x+y": {Followers: testFollowers("b", 1), Cursor: "../last"},
+		"../last":     {Followers: testFollowers("c", 1)},
+	}
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Query().Get("cursor")]
+		if !ok {
+			http.Error(w, "unknown cursor", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	dir := filepath.Join(t.TempDir(), "capture")
+	capture, err := newPageCapture(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.capture = capture
+
+	c := &crawler{store: newMemoryStore(), client: client, actor: "did:plc:subject"}
+	if _, err := c.crawl(context.Background(), ""); err != nil {
+		t.Fatalf("crawl: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	want := []string{"page-1-start.json", "page-2-2024_01_x_y.json", "page-3-.._last.json"}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Fatalf("captured files = %v, want %v", names, want)
+	}
+	for i, name := range want {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var page APIResponse
+		if err := json.Unmarshal(data, &page); err != nil {
+			t.Errorf("%s is not valid JSON: %v", name, err)
+			continue
+		}
+		if i == 0 && (len(page.Followers) != 2 || page.Cursor != "2024/01:x+y") {
+			t.Errorf("%s = %+v, want the raw first page", name, page)
+		}
+	}
+}
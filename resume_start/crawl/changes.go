@@ -0,0 +1,114 @@
+package crawl
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const changesTable = "follower_changes"
+
+// Kinds of followerChange.
+const (
+	changeNew    = "new"
+	changeLost   = "lost"
+	changeHandle = "handle"
+)
+
+// followerChange is one difference between a run and the state the previous
+// run left behind.
+type followerChange struct {
+	Kind      string `json:"change"`
+	DID       string `json:"did"`
+	Handle    string `json:"handle"`
+	OldHandle string `json:"old_handle,omitempty"`
+}
+
+// runChanges lists the new, lost and renamed followers of a run, in that order.
+func runChanges(before, now map[string]string, added, lost, renamed []string) []followerChange {
+	changes := make([]followerChange, 0, len(added)+len(lost)+len(renamed))
+	for _, did := range added {
+		changes = append(changes, followerChange{Kind: changeNew, DID: did, Handle: now[did]})
+	}
+	for _, did := range lost {
+		changes = append(changes, followerChange{Kind: changeLost, DID: did, Handle: before[did]})
+	}
+	for _, did := range renamed {
+		changes = append(changes, followerChange{Kind: changeHandle, DID: did, Handle: now[did], OldHandle: before[did]})
+	}
+	return changes
+}
+
+// countChanges returns how many changes are of kind.
+func countChanges(changes []followerChange, kind string) int {
+	n := 0
+	for _, change := range changes {
+		if change.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// initializeChanges creates the history table of per-run follower changes.
+func initializeChanges(db *sql.DB) error {
+	ddl, err := changesDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create changes table: %w", err)
+	}
+	return nil
+}
+
+// changesDDL returns the statements creating the follower changes table.
+func changesDDL() (string, error) {
+	table, err := quoteIdent(changesTable)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			run_id INTEGER,
+			did TEXT,
+			change TEXT,
+			handle TEXT,
+			old_handle TEXT,
+			detected_at DATETIME
+		);
+		CREATE INDEX IF NOT EXISTS follower_changes_run ON %s (run_id);
+	`, table, table), nil
+}
+
+// recordChanges stores the changes detected by run id in one transaction.
+func recordChanges(db *sql.DB, id int64, changes []followerChange) error {
+	table, err := quoteIdent(changesTable)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`INSERT INTO %s (run_id, did, change, handle, old_handle, detected_at) VALUES (?, ?, ?, ?, ?, ?);`, table))
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for _, change := range changes {
+		if _, err := stmt.Exec(id, change.DID, change.Kind, change.Handle, change.OldHandle, now); err != nil {
+			return fmt.Errorf("failed to record change for %s: %w", change.DID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package crawl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// checkpoint is the progress of a crawl kept in a human-readable JSON file by
+// -checkpoint, independent of the database.
+type checkpoint struct {
+	Actor          string    `json:"actor"`
+	Cursor         string    `json:"cursor"`
+	PagesDone      int       `json:"pages_done"`
+	FollowersSaved int       `json:"followers_saved"`
+	StartedAt      time.Time `json:"started_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Done           bool      `json:"done"`
+}
+
+// readCheckpoint loads the checkpoint at path, returning nil if there is none.
+func readCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// writeCheckpoint atomically replaces the checkpoint at path.
+func writeCheckpoint(path string, cp *checkpoint) error {
+	cp.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// checkpointWatcher records the pages_done of the checkpoint file each time a
+// page is saved.
+type checkpointWatcher struct {
+	Store
+	t    *testing.T
+	path string
+	seen []int
+}
+
+func (s *checkpointWatcher) Save(ctx context.Context, followers []Follower) error {
+	cp, err := readCheckpoint(s.path)
+	if err != nil {
+		s.t.Errorf("readCheckpoint: %v", err)
+	}
+	if cp == nil {
+		s.seen = append(s.seen, -1)
+	} else {
+		s.seen = append(s.seen, cp.PagesDone)
+	}
+	return s.Store.Save(ctx, followers)
+}
+
+func TestCheckpointWrittenPerPageAndResumed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 2), testFollowers("b", 2), testFollowers("c", 2)}}
+	store := &checkpointWatcher{Store: newMemoryStore(), t: t, path: path}
+	c := newTestCrawler(t, store, api, WithLimit(2))
+	c.checkpointFile = path
+
+	if _, err := c.Run(context.Background()); !errors.Is(err, errPageCap) {
+		t.Fatalf("Run = %v, want errPageCap", err)
+	}
+	// No checkpoint while the first page is saved, one page done for the second.
+	if want := []int{-1, 1}; !reflect.DeepEqual(store.seen, want) {
+		t.Errorf("pages_done seen while saving = %v, want %v", store.seen, want)
+	}
+	cp, err := readCheckpoint(path)
+	if err != nil || cp == nil {
+		t.Fatalf("readCheckpoint = %v, %v", cp, err)
+	}
+	if cp.Actor != "did:plc:subject" || cp.Cursor != "c2" || cp.PagesDone != 2 || cp.FollowersSaved != 4 || cp.Done || cp.StartedAt.IsZero() {
+		t.Errorf("checkpoint after the page cap = %+v", cp)
+	}
+
+	// A new process resumes from the checkpoint and marks it done.
+	resume, err := chooseResume(context.Background(), resumeAuto, resumeSources{actor: "did:plc:subject", checkpointFile: path})
+	if err != nil || resume.cursor != "c2" || resume.checkpoint == nil {
+		t.Fatalf("chooseResume = %+v, %v; want the checkpoint's cursor", resume, err)
+	}
+	resumed := newTestCrawler(t, store, api, WithCursor(resume.cursor))
+	resumed.checkpointFile = path
+	resumed.checkpoint = resume.checkpoint
+	if _, err := resumed.Run(context.Background()); err != nil {
+		t.Fatalf("resumed Run: %v", err)
+	}
+	if got := api.cursors[len(api.cursors)-1]; got != "c2" || len(api.cursors) != 3 {
+		t.Errorf("requested cursors %v, want the resume to fetch only c2", api.cursors)
+	}
+	cp, err = readCheckpoint(path)
+	if err != nil || !cp.Done || cp.Cursor != "" || cp.PagesDone != 3 || cp.FollowersSaved != 6 {
+		t.Errorf("checkpoint after completing = %+v, %v; want done with 3 pages and 6 followers", cp, err)
+	}
+	if resume, _ := chooseResume(context.Background(), resumeAuto, resumeSources{actor: "did:plc:subject", checkpointFile: path}); resume.cursor != "" {
+		t.Errorf("a done checkpoint resumes at %q, want the beginning", resume.cursor)
+	}
+}
+
+// brokenAfterStore saves the first ok pages, then fails every save.
+type brokenAfterStore struct {
+	Store
+	ok    int
+	saves int
+}
+
+func (s *brokenAfterStore) Save(ctx context.Context, followers []Follower) error {
+	s.saves++
+	if s.saves > s.ok {
+		return errors.New("disk I/O error")
+	}
+	return s.Store.Save(ctx, followers)
+}
+
+func TestFatalSaveErrorKeepsCursor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 2), testFollowers("b", 2), testFollowers("c", 2)}}
+	c := newTestCrawler(t, &brokenAfterStore{Store: newMemoryStore(), ok: 1}, api)
+	c.checkpointFile = path
+	c.failFast = true
+
+	_, err := c.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `resume with -cursor "c1"`) {
+		t.Fatalf("Run = %v, want a save error naming cursor c1", err)
+	}
+	cp, err := readCheckpoint(path)
+	if err != nil || cp == nil {
+		t.Fatalf("readCheckpoint = %v, %v", cp, err)
+	}
+	if cp.Cursor != "c1" || cp.Done {
+		t.Errorf("checkpoint after the failed save = %+v, want cursor c1 of the failed page", cp)
+	}
+}
@@ -0,0 +1,78 @@
+package crawl
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// cleanupReport counts rows left behind by older versions that a crawl would
+// never write today.
+type cleanupReport struct {
+	EmptyDIDs     int // rows whose DID is empty or blank
+	DuplicateDIDs int // extra copies of a DID, from tables created without a primary key
+	SharedHandles int // handles held by more than one DID, left to -dedupe-handles
+}
+
+// cleanupQueries returns the WHERE clauses selecting empty-DID rows and all but
+// the newest copy of each duplicated DID.
+func cleanupQueries(table string) (emptyDID, duplicateDID string) {
+	emptyDID = `TRIM(COALESCE(did, '')) = ''`
+	duplicateDID = fmt.Sprintf(`TRIM(COALESCE(did, '')) != '' AND rowid NOT IN (SELECT MAX(rowid) FROM %s GROUP BY did)`, table)
+	return emptyDID, duplicateDID
+}
+
+// cleanup scans the followers table for invalid rows and logs what it finds.
+// Empty-DID rows and duplicate DIDs are deleted only when apply is set; handles
+// shared by several DIDs are only reported.
+func cleanup(db *sql.DB, apply bool) (cleanupReport, error) {
+	var report cleanupReport
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return report, err
+	}
+
+	emptyDID, duplicateDID := cleanupQueries(table)
+	counts := []struct {
+		dest  *int
+		query string
+	}{
+		{&report.EmptyDIDs, fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s;`, table, emptyDID)},
+		{&report.DuplicateDIDs, fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s;`, table, duplicateDID)},
+		{&report.SharedHandles, fmt.Sprintf(`SELECT COUNT(*) FROM (SELECT handle FROM %s WHERE handle != '' GROUP BY handle HAVING COUNT(DISTINCT did) > 1);`, table)},
+	}
+	for _, c := range counts {
+		if err := db.QueryRow(c.query).Scan(c.dest); err != nil {
+			return report, fmt.Errorf("failed to scan for invalid rows: %w", err)
+		}
+	}
+	log.Printf("Cleanup scan: %d rows with an empty DID, %d duplicate DID rows, %d handles shared by several DIDs.\n",
+		report.EmptyDIDs, report.DuplicateDIDs, report.SharedHandles)
+	if report.SharedHandles > 0 {
+		log.Println("Shared handles are not removed by -cleanup; use -dedupe-handles to record or merge them.")
+	}
+
+	if report.EmptyDIDs+report.DuplicateDIDs == 0 {
+		return report, nil
+	}
+	if !apply {
+		log.Println("Run with -cleanup -apply to delete the empty and duplicate DID rows.")
+		return report, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return report, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	for _, where := range []string{emptyDID, duplicateDID} {
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE %s;`, table, where)); err != nil {
+			tx.Rollback()
+			return report, fmt.Errorf("failed to delete invalid rows: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return report, fmt.Errorf("failed to commit cleanup: %w", err)
+	}
+	log.Printf("Cleanup deleted %d invalid rows.\n", report.EmptyDIDs+report.DuplicateDIDs)
+	return report, nil
+}
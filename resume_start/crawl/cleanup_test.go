@@ -0,0 +1,68 @@
+package crawl
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanupReportsThenDeletes(t *testing.T) {
+	// An old table without a primary key, holding rows no crawl writes today.
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), dbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`
+		CREATE TABLE followers (did TEXT, handle TEXT, displayName TEXT);
+		INSERT INTO followers (did, handle) VALUES
+			('did:plc:a', 'a.test'),
+			('did:plc:b', 'b.test'),
+			('did:plc:b', 'b.test'),
+			('did:plc:b', 'b-renamed.test'),
+			('', 'empty.test'),
+			('  ', 'blank.test'),
+			(NULL, 'null.test'),
+			('did:plc:c', 'shared.test'),
+			('did:plc:d', 'shared.test');
+	`); err != nil {
+		t.Fatal(err)
+	}
+	count := func() int {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM followers;`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	want := cleanupReport{EmptyDIDs: 3, DuplicateDIDs: 2, SharedHandles: 1}
+	report, err := cleanup(db, false)
+	if err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if report != want {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+	if n := count(); n != 9 {
+		t.Fatalf("%d rows after a report-only cleanup, want all 9", n)
+	}
+
+	if report, err = cleanup(db, true); err != nil {
+		t.Fatalf("cleanup with -apply: %v", err)
+	}
+	if report != want {
+		t.Errorf("report with -apply = %+v, want %+v", report, want)
+	}
+	if n := count(); n != 4 {
+		t.Errorf("%d rows after cleanup, want 4", n)
+	}
+	var handle string
+	if err := db.QueryRow(`SELECT handle FROM followers WHERE did = 'did:plc:b';`).Scan(&handle); err != nil || handle != "b-renamed.test" {
+		t.Errorf("kept copy of did:plc:b has handle %q, %v; want the newest", handle, err)
+	}
+
+	if report, err = cleanup(db, true); err != nil || report != (cleanupReport{SharedHandles: 1}) {
+		t.Errorf("second cleanup = %+v, %v; want only the shared handle left", report, err)
+	}
+}
@@ -0,0 +1,1067 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	dbFile     = "followers.db"
+	tableName  = "followers"
+	maxRetries = 5
+)
+
+// Follower represents a follower's structure as per the JSON response.
+type Follower struct {
+	DID         string   `json:"did"`
+	Handle      string   `json:"handle"`
+	DisplayName string   `json:"displayName"`
+	Avatar      string   `json:"avatar"`
+	Viewer      Viewer   `json:"viewer"`
+	Labels      []Label  `json:"labels"`
+	CreatedAt   bskyTime `json:"createdAt"`
+	Description string   `json:"description"`
+	IndexedAt   bskyTime `json:"indexedAt"`
+
+	// FetchSeq is the position the follower was saved at, preserving the API's
+	// ordering. It is assigned on save and not part of the API response.
+	FetchSeq int64 `json:"-"`
+
+	// missing marks the optional profile fields the API response left out, so
+	// they are stored as NULL rather than as an empty string.
+	missing optionalFields
+}
+
+// optionalFields is a set of the optional profile fields of a Follower.
+type optionalFields uint8
+
+const (
+	fieldDisplayName optionalFields = 1 << iota
+	fieldAvatar
+	fieldDescription
+)
+
+// UnmarshalJSON implements json.Unmarshaler, recording which optional fields
+// were absent or null.
+func (f *Follower) UnmarshalJSON(data []byte) error {
+	type plain Follower // without this method
+	aux := struct {
+		*plain
+		DisplayName *string `json:"displayName"`
+		Avatar      *string `json:"avatar"`
+		Description *string `json:"description"`
+	}{plain: (*plain)(f)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	f.missing = 0
+	for _, field := range []struct {
+		value *string
+		dst   *string
+		flag  optionalFields
+	}{
+		{aux.DisplayName, &f.DisplayName, fieldDisplayName},
+		{aux.Avatar, &f.Avatar, fieldAvatar},
+		{aux.Description, &f.Description, fieldDescription},
+	} {
+		if field.value == nil {
+			*field.dst = ""
+			f.missing |= field.flag
+			continue
+		}
+		*field.dst = *field.value
+	}
+	return nil
+}
+
+// setOptional records whether the stored optional field was NULL and returns
+// its value, empty when it was.
+func (f *Follower) setOptional(field optionalFields, value sql.NullString) string {
+	if !value.Valid {
+		f.missing |= field
+	}
+	return value.String
+}
+
+// optional returns value as it is stored for the optional field: NULL when the
+// API left it out, even if it would otherwise be an empty string.
+func (f Follower) optional(field optionalFields, value string) sql.NullString {
+	return sql.NullString{String: value, Valid: f.missing&field == 0}
+}
+
+// Viewer represents the viewer-specific information within a follower.
+type Viewer struct {
+	Muted     bool   `json:"muted"`
+	BlockedBy bool   `json:"blockedBy"`
+	Following string `json:"following"`
+}
+
+// Label represents each label object in the labels array
+type Label struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// APIResponse represents the full structure of the API response.
+type APIResponse struct {
+	Subject   *Subject   `json:"subject"`
+	Followers []Follower `json:"followers"`
+	Cursor    string     `json:"cursor"`
+}
+
+// Subject is the account whose followers a response lists.
+type Subject struct {
+	DID    string `json:"did"`
+	Handle string `json:"handle"`
+}
+
+// Main runs the command line tool with the flags in os.Args, returning its
+// exit status. Failures return rather than exit, so deferred cleanup such as
+// the WAL checkpoint, profile flush and trace export runs on every path.
+func Main() int {
+	// Parse the starting cursor from command-line arguments.
+	actor := flag.String("actor", defaultActor, "DID or handle of the account whose followers are crawled. Also read from BLUESKY_ACTOR.")
+	actors := flag.String("actors", "", "Comma-separated DIDs or handles to crawl concurrently into the actor_followers table.")
+	concurrency := flag.Int("concurrency", 4, "Number of actors crawled in parallel with -actors, or avatars downloaded in parallel.")
+	avatarDir := flag.String("download-avatars", "", "After crawling, download each follower's avatar into this directory, skipping files already present.")
+	rps := flag.Float64("rps", 0, "Maximum API requests per second across all workers. 0 means unlimited.")
+	startCursor := flag.String("cursor", "", "The starting cursor for fetching followers. If empty, starts from scratch.")
+	breakerThreshold := flag.Int("breaker-threshold", 10, "Consecutive failed requests (across pages) before the circuit breaker opens. 0 disables it.")
+	breakerCooldown := flag.Duration("breaker-cooldown", time.Minute, "How long to pause once the circuit breaker opens.")
+	breakerAbort := flag.Bool("breaker-abort", false, "Abort the crawl instead of pausing when the circuit breaker opens.")
+	exportFormat := flag.String("export", "", "Export stored followers in the given format (json, csv, parquet) instead of crawling.")
+	exportSince := flag.String("since", "", "With -export, only export followers first or last seen after this time (RFC 3339 or YYYY-MM-DD).")
+	reportName := flag.String("report", "", "Print a read-only report instead of crawling: growth, enrich-failures, anomalies, ratios or mutuals.")
+	anomalySigmaFlag := flag.Float64("anomaly-sigma", anomalySigma, "With -report anomalies, report runs whose change in followers is more than this many standard deviations from the actor's other changes.")
+	reportFormat := flag.String("report-format", "text", "Format of -report output: text, json or csv.")
+	exportStreamThreshold := flag.Int("export-stream-threshold", 100000, "Exports of more than this many followers stream them in storage order instead of sorting them by fetch order, so the full result is never materialized.")
+	exportOut := flag.String("out", "", "File to write the export to. Defaults to stdout.")
+	redact := flag.Bool("redact", false, "Blank avatar and description in exports, keeping DID and handle.")
+	redactDisplayName := flag.Bool("redact-display-name", false, "With -redact, also blank displayName in exports.")
+	enrich := flag.Bool("enrich", false, "After crawling, fetch full profiles via getProfiles and store the extra fields.")
+	enrichTTL := flag.Duration("enrich-ttl", 0, "Skip followers enriched less than this long ago, e.g. 24h. 0 re-enriches everyone.")
+	detectLang := flag.Bool("detect-language", false, "After crawling, detect the probable language of each follower's description and store it in bio_lang, unknown when empty or unclear.")
+	enrichDelay := flag.Duration("enrich-delay", 500*time.Millisecond, "Pause between getProfiles batches during enrichment.")
+	mode := flag.String("mode", "followers", "What to crawl: "+modeNames()+". See -list-modes.")
+	checkForUpdate := flag.Bool("check-update", false, "Print whether a newer release than this build is available on GitHub, then exit. Nothing is installed.")
+	listModes := flag.Bool("list-modes", false, "Print the supported modes, their endpoints and whether they need -auth-token, then exit.")
+	authToken := flag.String("auth-token", "", "Bearer access token for endpoints that require authentication. Also read from BLUESKY_AUTH_TOKEN.")
+	serviceURL := flag.String("service-url", defaultServiceURL, "AppView the API requests are sent to. Also read from BLUESKY_SERVICE_URL.")
+	maxBodySize := flag.Int64("max-body-size", defaultMaxBodySize, "Maximum response body size in bytes; larger responses are retried.")
+	otelEndpoint := flag.String("otel-endpoint", "", "OTLP/HTTP endpoint to export traces to, e.g. http://localhost:4318. Empty disables tracing.")
+	onStaleCursor := flag.String("on-stale-cursor", staleCursorRestart, "What to do when the API rejects the cursor a crawl resumes from: restart from the beginning, abort, or skip this run and start from the beginning next time.")
+	resumeStrategy := flag.String("resume-strategy", resumeAuto, "Where a crawl's starting cursor comes from: flag (-cursor), file (-cursor-file, then -checkpoint), db (the cursor recorded in the database after each page), or auto, which takes the first of -cursor, -cursor-file, -checkpoint and the database that has one.")
+	resumeValidate := flag.Bool("resume-validate", false, "Probe the starting cursor before resuming and fall back to a full recrawl if it looks stale.")
+	key := flag.String("key", "did", "Column the followers table is keyed on: did or handle.")
+	atomicRecrawl := flag.Bool("atomic-recrawl", false, "Crawl into a staging table and swap it in for the followers table only once the crawl completes, so readers never see a partial list. Not supported when resuming.")
+	storeEngine := flag.String("store-engine", storeEngineTable, "How crawled followers are stored: table upserts them into the followers table, log appends every observation to the follower_observations table, keeping each snapshot.")
+	minimal := flag.Bool("minimal", false, "Use a minimal schema storing only each follower's DID and handle.")
+	caFile := flag.String("ca-file", "", "PEM file with extra CA certificates to trust, e.g. for a corporate proxy.")
+	insecureSkipVerify := flag.Bool("insecure-skip-verify", false, "Disable TLS certificate verification. Dangerous; for debugging only.")
+	maxIdleConns := flag.Int("max-idle-conns-per-host", 4, "Idle connections kept open to the API host for reuse.")
+	idleConnTimeout := flag.Duration("idle-conn-timeout", 90*time.Second, "How long an idle connection is kept open for reuse.")
+	pinSHA256 := flag.String("pin-sha256", "", "Base64 or hex SHA-256 of the API server's public key (SubjectPublicKeyInfo); connections to the -service-url host presenting any other key are rejected. Other hosts, such as avatar CDNs and webhooks, are not pinned.")
+	http2 := flag.Bool("http2", true, "Negotiate HTTP/2 with the API over TLS.")
+	traceHTTP := flag.Bool("trace-http", false, "Log every HTTP request and response (method, URL, status, byte counts).")
+	cursorFile := flag.String("cursor-file", "", "File to resume the cursor from and to record the latest cursor in after each page. Removed on completion.")
+	allowlist := flag.String("allowlist", "", "File of DIDs and handles, one per line; only these followers are saved.")
+	denylist := flag.String("denylist", "", "File of DIDs and handles, one per line, never saved. Takes precedence over -allowlist.")
+	sampleRate := flag.Float64("sample-rate", 1, "Fraction of fetched followers to save, chosen at random, e.g. 0.1 for about 10%.")
+	sampleSeed := flag.Int64("sample-seed", 0, "Seed for -sample-rate; 0 seeds from the current time. A fixed seed makes the sample reproducible.")
+	pageDelay := flag.Duration("page-delay", 0, "Minimum pause between saving a page and fetching the next one, e.g. 500ms. Retries are not delayed.")
+	onlyNew := flag.Bool("only-new", false, "Print only the handles of followers first seen during each run, one per line, instead of the run summary. Works with -minimal.")
+	maxDescLen := flag.Int("max-description-len", 0, "Truncate stored descriptions to this many characters, ending in an ellipsis. 0 stores them whole.")
+	normalizeAvatarHost := flag.Bool("normalize-avatar-host", false, "Store avatars as the path of their URL, without the CDN host.")
+	avatarHostFlag := flag.String("avatar-host", defaultAvatarHost, "Host that avatars stored with -normalize-avatar-host are resolved against on export and download.")
+	force := flag.Bool("force", false, "Crawl into a database that was crawled for a different actor.")
+	dbTimeoutFlag := flag.Duration("db-timeout", 0, "Give up on a save that takes longer than this, such as while another process holds the database lock, e.g. 30s. 0 waits as long as SQLite does.")
+	tolerant := flag.Bool("tolerant-save", false, "Skip followers that fail to insert instead of failing the whole page.")
+	validateDID := flag.Bool("validate-did", false, "Store followers whose DID is not a well-formed did:plc or did:web in the invalid_followers table instead.")
+	jitterSeed := flag.Int64("jitter-seed", 0, "Seed for the random jitter added to retry backoff; 0 seeds from the current time. A fixed seed makes retry timing reproducible.")
+	httpCacheDir := flag.String("http-cache-dir", "", "Directory to keep ETags, Last-Modified dates and bodies of API responses in, so requests in later runs are conditional and a 304 Not Modified is answered from disk.")
+	httpCacheMaxBytes := flag.Int64("http-cache-max-bytes", 256<<20, "Evict the least recently used -http-cache-dir entries once the cache exceeds this many bytes. 0 never evicts.")
+	captureDir := flag.String("capture-dir", "", "Directory to archive the raw JSON of every followers page in, as page-<n>-<cursor>.json.")
+	fromStdin := flag.Bool("from-stdin", false, "Save followers from API responses read from stdin as one JSON object per line, without making any requests.")
+	checkpointFile := flag.String("checkpoint", "", "JSON file recording crawl progress after each page; an unfinished checkpoint for the same actor is resumed.")
+	cursorLog := flag.Bool("cursor-log", false, "Append every cursor returned by the API to the cursor_log table.")
+	dedupeHandles := flag.Bool("dedupe-handles", false, "After crawling, record DIDs that share a handle in the did_aliases table.")
+	mergeHandles := flag.Bool("merge-handles", false, "With -dedupe-handles, delete the older DID's rows and keep the newer one. Destructive.")
+	minAccountAge := flag.Duration("min-account-age", 0, "Skip followers whose account is younger than this, e.g. 720h. 0 keeps everyone.")
+	maxPages := flag.Int("max-pages", 0, "Stop after fetching this many pages, recording the cursor to resume from. 0 crawls everything.")
+	maxConsecutiveEmpty := flag.Int("max-consecutive-empty", 0, "Stop the crawl after this many pages in a row without a follower that isn't stored yet, e.g. when topping up a database. 0 crawls to the end.")
+	benchmark := flag.Int("benchmark", 0, "Time saving this many synthetic followers with the current schema and -commit-batch in a scratch database, print rows/sec, then exit. No requests are made.")
+	commitBatch := flag.Int("commit-batch", 0, "Commit followers every N rows across pages instead of once per page. 0 commits each page.")
+	includeSuffix := flag.String("include-handle-suffix", "", "Comma-separated handle suffixes; only followers whose handle ends in one are saved.")
+	excludeSuffix := flag.String("exclude-handle-suffix", "", "Comma-separated handle suffixes; followers whose handle ends in one are skipped, e.g. .bsky.social.")
+	failFast := flag.Bool("fail-fast", false, "Abort on the first failed save instead of retrying the page a few times.")
+	printSchemaOnly := flag.Bool("print-schema", false, "Print the DDL the selected mode and flags would create, then exit.")
+	reconcileOnly := flag.Bool("reconcile", false, "Recrawl and report differences between the database and the API instead of crawling.")
+	apply := flag.Bool("apply", false, "Let maintenance modes such as -reconcile and -cleanup modify the database.")
+	reset := flag.Bool("reset", false, "Delete every follower and the stored crawl state (metadata, page hashes, cursor log, -cursor-file and -checkpoint), keeping the schema and run history, then exit. Asks for confirmation unless -yes is set.")
+	yes := flag.Bool("yes", false, "Skip the confirmation -reset asks for.")
+	cleanupRows := flag.Bool("cleanup", false, "On startup, report rows with empty or duplicate DIDs left by older versions; with -apply, delete them.")
+	recoverCorrupt := flag.Bool("recover", false, "If the database is corrupt, salvage readable rows into a fresh file and keep the original aside.")
+	churnAlertThreshold := flag.Int("churn-alert", 0, "Log an error, and call -webhook-url, when a run loses more than this many followers. 0 disables.")
+	emitEvents := flag.String("emit-events", "", "Publish an NDJSON event for every new, lost and renamed follower a run finds: POSTed to an http(s) URL or appended to a file.")
+	webhookURL := flag.String("webhook-url", "", "URL that alerts such as -churn-alert are POSTed to as JSON.")
+	labelFormat := flag.String("label-format", "comma", "How the labels column is stored: comma (type:value,...), json, or table (normalized table only).")
+	cpuProfile := flag.String("cpuprofile", "", "Write a CPU profile to this file.")
+	memProfile := flag.String("memprofile", "", "Write a heap profile to this file on exit.")
+	summaryFormat := flag.String("summary-format", "text", "Format of the end-of-run summary: text or json.")
+	healthAddr := flag.String("health-addr", "", "With -interval, serve /healthz and /readyz on this address, e.g. :8080, reporting the last run. Empty disables them.")
+	interval := flag.Duration("interval", 0, "Repeat the crawl continuously, pausing this long between runs. 0 runs once.")
+
+	// Settings from .env and the environment become flag defaults, so flags
+	// given on the command line still win.
+	envFile := defaultEnvFile
+	if path, ok := os.LookupEnv("BLUESKY_ENV_FILE"); ok {
+		envFile = path
+	}
+	if n, err := loadEnvFile(envFile); err != nil {
+		log.Printf("Failed to load env file: %v", err)
+		return 1
+	} else if n > 0 {
+		log.Printf("Loaded %d variables from %s.\n", n, envFile)
+	}
+	if err := applyEnvFlags(flag.CommandLine); err != nil {
+		log.Printf("Invalid environment: %v", err)
+		return 1
+	}
+	flag.Parse()
+
+	if !summaryFormats[*summaryFormat] {
+		log.Printf("Unknown -summary-format %q, expected text or json", *summaryFormat)
+		return 1
+	}
+	serializer, ok := labelSerializers[*labelFormat]
+	if !ok {
+		log.Printf("Unknown -label-format %q, expected comma, json or table", *labelFormat)
+		return 1
+	}
+	labelSerializer = serializer
+
+	baseTransport, err := newTransport(transportOptions{
+		caFile:              *caFile,
+		insecure:            *insecureSkipVerify,
+		maxIdleConnsPerHost: *maxIdleConns,
+		idleConnTimeout:     *idleConnTimeout,
+		http2:               *http2,
+		pinSHA256:           *pinSHA256,
+		pinHost:             serviceHost(*serviceURL),
+	})
+	if err != nil {
+		log.Printf("HTTP transport setup failed: %v", err)
+		return 1
+	}
+	var transport http.RoundTripper = baseTransport
+	if *traceHTTP {
+		transport = &loggingTransport{base: transport}
+	}
+	if *httpCacheDir != "" {
+		if transport, err = newHTTPCache(*httpCacheDir, *httpCacheMaxBytes, *maxBodySize, transport); err != nil {
+			log.Printf("HTTP cache setup failed: %v", err)
+			return 1
+		}
+	}
+
+	client := &apiClient{
+		http: &http.Client{Transport: transport},
+		breaker: &circuitBreaker{
+			threshold: *breakerThreshold,
+			cooldown:  *breakerCooldown,
+			abort:     *breakerAbort,
+		},
+		serviceURL:  *serviceURL,
+		authToken:   *authToken,
+		maxBodySize: *maxBodySize,
+		limiter:     newRateLimiter(*rps),
+		conns:       &connStats{},
+	}
+	seed := *jitterSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	client.jitter = newJitter(rand.New(rand.NewSource(seed)))
+	defer client.conns.log()
+	if *captureDir != "" {
+		if client.capture, err = newPageCapture(*captureDir); err != nil {
+			log.Printf("Capture setup failed: %v", err)
+			return 1
+		}
+	}
+
+	switch *onStaleCursor {
+	case staleCursorRestart, staleCursorAbort, staleCursorSkip:
+	default:
+		log.Printf("Unknown -on-stale-cursor %q, expected restart, abort or skip", *onStaleCursor)
+		return 1
+	}
+	switch *resumeStrategy {
+	case resumeFlag, resumeFile, resumeDB, resumeAuto:
+	default:
+		log.Printf("Unknown -resume-strategy %q, expected flag, file, db or auto", *resumeStrategy)
+		return 1
+	}
+	switch *storeEngine {
+	case storeEngineTable:
+	case storeEngineLog:
+		if *actors != "" {
+			log.Printf("-store-engine log is not supported with -actors")
+			return 1
+		}
+	default:
+		log.Printf("Unknown -store-engine %q, expected table or log", *storeEngine)
+		return 1
+	}
+	if *atomicRecrawl && (*storeEngine != storeEngineTable || *startCursor != "" || *cursorFile != "" || *checkpointFile != "") {
+		log.Printf("-atomic-recrawl always crawls the whole list into the followers table; it is not supported with -store-engine log, -cursor, -cursor-file or -checkpoint")
+		return 1
+	}
+
+	if *minimal {
+		if *exportFormat != "" || *enrich || *dedupeHandles || *avatarDir != "" || *detectLang {
+			log.Printf("-export, -enrich, -dedupe-handles, -download-avatars and -detect-language are not supported with -minimal")
+			return 1
+		}
+		followerColumns = minimalColumns
+		if *onlyNew {
+			// -only-new reads first_seen, so it is kept alongside the identity.
+			for _, col := range fullColumns {
+				if col.name == "first_seen" {
+					followerColumns = append(minimalColumns[:len(minimalColumns):len(minimalColumns)], col)
+				}
+			}
+		}
+	}
+	if *key != "did" && *key != "handle" {
+		log.Printf("Unknown -key %q, expected did or handle", *key)
+		return 1
+	}
+	if followerColumns, err = keyedColumns(followerColumns, *key); err != nil {
+		log.Printf("Invalid -key: %v", err)
+		return 1
+	}
+	if *key == "handle" {
+		log.Println("Warning: keying followers on handle. Handles change and can be reused, so rows may be overwritten or duplicated across renames.")
+	}
+
+	if *checkForUpdate {
+		checkUpdate(context.Background(), client.http, latestReleaseURL, version, os.Stdout)
+		return 0
+	}
+
+	if *listModes {
+		if err := writeModes(os.Stdout); err != nil {
+			log.Printf("Failed to list modes: %v", err)
+			return 1
+		}
+		return 0
+	}
+	selectedMode, ok := findMode(*mode)
+	if !ok {
+		log.Printf("Unknown mode %q, expected one of %s", *mode, modeNames())
+		return 1
+	}
+
+	if *printSchemaOnly {
+		opts := schemaOptions{mode: *mode, cursorLog: *cursorLog, enrich: *enrich, dedupeHandles: *dedupeHandles, multiActor: *actors != "", validateDID: *validateDID, storeEngine: *storeEngine, detectLanguage: *detectLang}
+		if err := printSchema(os.Stdout, opts); err != nil {
+			log.Printf("Failed to print schema: %v", err)
+			return 1
+		}
+		return 0
+	}
+
+	// Stop cleanly between pages on SIGINT/SIGTERM so the database can be checkpointed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := setupTracing(ctx, *otelEndpoint)
+	if err != nil {
+		log.Printf("Tracing setup failed: %v", err)
+		return 1
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("Failed to flush traces: %v", err)
+		}
+	}()
+
+	// Initialize the SQLite database.
+	log.Println("Initializing the database...")
+	dbTimeout = *dbTimeoutFlag
+	db, err := initializeDB(dbFile)
+	if isCorruptDB(err) {
+		if !*recoverCorrupt {
+			log.Printf("Database %s is corrupt (%v). Re-run with -recover to salvage what is readable into a fresh file.", dbFile, err)
+			return 1
+		}
+		log.Printf("Database %s is corrupt (%v), attempting recovery...\n", dbFile, err)
+		if err := recoverDB(dbFile); err != nil {
+			log.Printf("Database recovery failed: %v", err)
+			return 1
+		}
+		db, err = initializeDB(dbFile)
+	}
+	if err != nil {
+		log.Printf("Database initialization failed: %v", err)
+		return 1
+	}
+	defer closeDB(db)
+	log.Println("Database initialized successfully.")
+
+	stopProfiling, err := startProfiling(*cpuProfile, *memProfile)
+	if err != nil {
+		log.Printf("Profiling setup failed: %v", err)
+		return 1
+	}
+	defer stopProfiling()
+
+	tolerantSave = *tolerant
+	normalizeAvatars = *normalizeAvatarHost
+	maxDescriptionLen = *maxDescLen
+	avatarHost = *avatarHostFlag
+	if *validateDID {
+		if err := initializeInvalidFollowers(db); err != nil {
+			log.Printf("Failed to initialize invalid followers table: %v", err)
+			return 1
+		}
+		validateDIDs = true
+	}
+
+	if *reset {
+		if !*yes && !confirmReset(os.Stdin, os.Stderr) {
+			log.Printf("Reset cancelled.")
+			return 1
+		}
+		if _, err := resetDB(db); err != nil {
+			log.Printf("Reset failed: %v", err)
+			return 1
+		}
+		if err := removeCrawlState(*cursorFile, *checkpointFile); err != nil {
+			log.Printf("Reset failed: %v", err)
+			return 1
+		}
+		log.Println("Reset completed, the next crawl starts from scratch.")
+		return 0
+	}
+
+	if *benchmark > 0 {
+		result, err := runBenchmark(ctx, filepath.Dir(dbFile), *benchmark, *commitBatch)
+		if err != nil {
+			log.Printf("Benchmark failed: %v", err)
+			return 1
+		}
+		if err := writeBenchmark(os.Stdout, result); err != nil {
+			log.Printf("Benchmark failed: %v", err)
+			return 1
+		}
+		return 0
+	}
+
+	if *cleanupRows {
+		if _, err := cleanup(db, *apply); err != nil {
+			log.Printf("Cleanup failed: %v", err)
+			return 1
+		}
+	}
+
+	if *exportFormat != "" {
+		opts := exportOptions{redact: *redact, redactDisplayName: *redact && *redactDisplayName, streamThreshold: *exportStreamThreshold}
+		if *exportSince != "" {
+			if opts.since, err = parseSince(*exportSince); err != nil {
+				log.Printf("Export failed: %v", err)
+				return 1
+			}
+		}
+		if err := runExport(db, *exportFormat, *exportOut, opts); err != nil {
+			log.Printf("Export failed: %v", err)
+			return 1
+		}
+		return 0
+	}
+
+	if *reportName != "" {
+		anomalySigma = *anomalySigmaFlag
+		if err := runReport(db, *reportName, *reportFormat); err != nil {
+			log.Printf("Report failed: %v", err)
+			return 1
+		}
+		return 0
+	}
+
+	if *fromStdin {
+		pages, followers, err := ingestResponses(ctx, db, os.Stdin)
+		if err != nil {
+			log.Printf("Ingest failed: %v", err)
+			return 1
+		}
+		log.Printf("Ingested %d followers from %d responses.\n", followers, pages)
+		return 0
+	}
+
+	if *actors != "" {
+		if err := crawlActors(ctx, db, client, splitActors(*actors), *concurrency, *failFast); err != nil {
+			log.Printf("Multi-actor crawl failed: %v", err)
+			return 1
+		}
+		return 0
+	}
+
+	if *mode == "followers" {
+		preflightProfile(ctx, client, *actor)
+		if err := checkActor(ctx, db, client, *actor, *force); err != nil {
+			log.Printf("Actor check failed: %v", err)
+			return 1
+		}
+	}
+
+	if *reconcileOnly {
+		if err := reconcile(ctx, db, client, *actor, *apply, os.Stdout); err != nil {
+			log.Printf("Reconciliation failed: %v", err)
+			return 1
+		}
+		return 0
+	}
+
+	if selectedMode.run != nil {
+		if err := selectedMode.run(ctx, db, client, *actor); err != nil {
+			log.Printf("Mode %s failed: %v", selectedMode.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	// An atomic recrawl always starts over, so a cursor left by an
+	// interrupted one is never resumed into the followers table.
+	var resume resumePoint
+	if !*atomicRecrawl {
+		resume, err = chooseResume(ctx, *resumeStrategy, resumeSources{
+			actor: *actor, flagCursor: *startCursor, cursorFile: *cursorFile, checkpointFile: *checkpointFile, db: db,
+		})
+		if err != nil {
+			log.Printf("Failed to load resume cursor: %v", err)
+			return 1
+		}
+	}
+	cursor := resume.cursor
+	switch {
+	case resume.checkpoint != nil:
+		log.Printf("Resuming from %s: cursor %s, %d pages and %d followers done.\n", resume.source, cursor, resume.checkpoint.PagesDone, resume.checkpoint.FollowersSaved)
+	case cursor != "":
+		log.Printf("Resuming from cursor in %s: %s\n", resume.source, cursor)
+	case !*atomicRecrawl:
+		log.Printf("No cursor found with -resume-strategy %s, starting from the beginning of the list.\n", *resumeStrategy)
+	}
+	if cursor != "" && *resumeValidate {
+		if err := validateCursor(ctx, client, *actor, cursor); err != nil {
+			log.Printf("Warning: cursor %s looks stale (%v), falling back to a full recrawl.\n", cursor, err)
+			cursor = ""
+		}
+	}
+
+	// Crawl once, or repeatedly with a pause between runs when an interval is set.
+	// Runs are only recorded against the followers table, so a log store keeps
+	// no run history.
+	var store Store = newSQLiteStore(db, tableName, followerColumns)
+	if *storeEngine == storeEngineLog {
+		store = newLogStore(db, followerColumns)
+	}
+	opts := []Option{WithActor(*actor), WithStore(store), WithLimit(*maxPages), WithCursor(cursor),
+		WithStaleCursorPolicy(*onStaleCursor), withAPIClient(client), withCheckpoint(resume.checkpoint),
+		WithCursorFile(*cursorFile), WithCursorLog(*cursorLog), WithFailFast(*failFast),
+		WithCommitBatch(*commitBatch), WithPageDelay(*pageDelay), WithMaxConsecutiveEmpty(*maxConsecutiveEmpty),
+		WithCheckpointFile(*checkpointFile), WithAtomicRecrawl(*atomicRecrawl)}
+	if *emitEvents != "" {
+		publisher, closePublisher, err := newPublisher(*emitEvents, client.http)
+		if err != nil {
+			log.Printf("Event publisher setup failed: %v", err)
+			return 1
+		}
+		defer closePublisher()
+		opts = append(opts, WithPublisher(publisher))
+	}
+	if *minAccountAge > 0 {
+		opts = append(opts, withFilters(minAccountAgeFilter(*minAccountAge)))
+	}
+	if suffixes := splitSuffixes(*includeSuffix); len(suffixes) > 0 {
+		opts = append(opts, withFilters(handleSuffixFilter(suffixes, false)))
+	}
+	if suffixes := splitSuffixes(*excludeSuffix); len(suffixes) > 0 {
+		opts = append(opts, withFilters(handleSuffixFilter(suffixes, true)))
+	}
+	// The denylist runs first, so an account on both lists is never saved.
+	for _, list := range []struct {
+		path    string
+		exclude bool
+	}{{*denylist, true}, {*allowlist, false}} {
+		if list.path == "" {
+			continue
+		}
+		accounts, err := readAccountList(list.path)
+		if err != nil {
+			log.Printf("Filter setup failed: %v", err)
+			return 1
+		}
+		opts = append(opts, withFilters(accountListFilter(list.path, accounts, list.exclude)))
+	}
+	// Sampling runs after the other filters, so the rate applies to the
+	// followers that would otherwise be saved.
+	if *sampleRate <= 0 || *sampleRate > 1 {
+		log.Printf("-sample-rate must be in (0, 1], got %g", *sampleRate)
+		return 1
+	}
+	if *sampleRate < 1 {
+		seed := *sampleSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		opts = append(opts, withFilters(sampleFilter(*sampleRate, rand.New(rand.NewSource(seed)))))
+	}
+	c, err := NewCrawler(ctx, opts...)
+	if err != nil {
+		log.Printf("Crawler setup failed: %v", err)
+		return 1
+	}
+
+	var runHealth *health
+	if *healthAddr != "" {
+		runHealth = newHealth(*interval)
+		if err := serveHealth(ctx, *healthAddr, runHealth); err != nil {
+			log.Printf("Health server setup failed: %v", err)
+			return 1
+		}
+	}
+
+	return monitor(ctx, *interval, func() (int, bool) {
+		runHealth.runStarted()
+		stats, err := c.Run(ctx)
+		runHealth.runFinished(stats)
+		// Every run after the first starts from scratch.
+		c.cursor = ""
+		if stats.Status == "" {
+			log.Printf("Crawl failed: %v", err)
+			return 1, true
+		}
+		if *onlyNew {
+			// Only a completed run has seen everyone who is new.
+			if stats.Status == "completed" {
+				handles, err := newHandles(db, stats.StartedAt)
+				if err == nil {
+					err = writeNewHandles(os.Stdout, handles)
+				}
+				if err != nil {
+					log.Printf("Failed to list new followers: %v", err)
+				}
+			}
+		} else if err := writeSummary(os.Stdout, *summaryFormat, stats); err != nil {
+			log.Printf("Failed to write run summary: %v", err)
+		}
+		checkChurn(ctx, client.http, *webhookURL, *actor, stats, *churnAlertThreshold)
+
+		if stats.Status == "failed" {
+			log.Printf("Crawl failed: %v", err)
+			return 1, true
+		}
+		if stats.Status == "interrupted" {
+			return 0, true
+		}
+
+		if *dedupeHandles {
+			if err := reconcileHandles(db, *mergeHandles); err != nil {
+				log.Printf("Handle reconciliation failed: %v", err)
+				return 1, true
+			}
+		}
+
+		if *enrich {
+			log.Println("Starting profile enrichment.")
+			if err := enrichFollowers(ctx, db, client, *enrichDelay, *enrichTTL); err != nil {
+				log.Printf("Enrichment failed: %v", err)
+				return 1, true
+			}
+			log.Println("Profile enrichment completed.")
+		}
+
+		if *detectLang {
+			if err := detectLanguages(ctx, db); err != nil {
+				log.Printf("Language detection failed: %v", err)
+				return 1, true
+			}
+		}
+
+		if *avatarDir != "" {
+			if err := downloadAvatars(ctx, db, client, *avatarDir, *concurrency); err != nil {
+				if ctx.Err() != nil {
+					log.Println("Shutdown requested, stopping avatar downloads.")
+					return 0, true
+				}
+				log.Printf("Avatar download failed: %v", err)
+				return 1, true
+			}
+		}
+		return 0, false
+	})
+}
+
+// monitor calls once, and then, while interval is positive, calls it again
+// after each pause of interval until it asks to stop or ctx is done. once
+// returns the exit status of its run and whether to stop; monitor returns the
+// status of the last run.
+func monitor(ctx context.Context, interval time.Duration, once func() (status int, stop bool)) int {
+	for {
+		status, stop := once()
+		if stop || interval <= 0 {
+			return status
+		}
+		log.Printf("Run finished, next run in %v.\n", interval)
+		if err := sleepContext(ctx, interval); err != nil {
+			log.Println("Shutdown requested, stopping continuous monitoring.")
+			return status
+		}
+	}
+}
+
+// validateCursor makes one probe request with cursor and reports whether the API
+// still honours it. An error or an empty page that still carries a cursor means
+// the position is no longer meaningful.
+func validateCursor(ctx context.Context, client *apiClient, actor, cursor string) error {
+	log.Printf("Validating resume cursor: %s\n", cursor)
+	followers, newCursor, err := fetchFollowers(ctx, client, actor, cursor)
+	if err != nil {
+		return err
+	}
+	if len(followers) == 0 && newCursor != "" {
+		return errors.New("probe returned no followers but a new cursor")
+	}
+	log.Println("Resume cursor is valid.")
+	return nil
+}
+
+// identPattern matches SQL identifiers that are safe to interpolate once quoted.
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// quoteIdent validates a SQL identifier and wraps it in double quotes so it can be
+// interpolated into a query without risking injection.
+func quoteIdent(name string) (string, error) {
+	if !identPattern.MatchString(name) {
+		return "", fmt.Errorf("invalid SQL identifier %q", name)
+	}
+	return `"` + name + `"`, nil
+}
+
+// initializeDB sets up the SQLite database.
+func initializeDB(dbFile string) (*sql.DB, error) {
+	// SQLite creates the file but not its directory, and only reports the
+	// missing directory on the first query.
+	if dir := filepath.Dir(dbFile); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory %s: %w", dir, err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", sqliteDSN(dbFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// WAL keeps readers unblocked while a crawl is writing.
+	if _, err := db.Exec(`PRAGMA journal_mode=WAL;`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to enable WAL: %w", err)
+	}
+
+	if err := runMigrations(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := initializeRuns(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := initializeLabels(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := initializeChanges(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := initializePageHashes(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := initializeMetadata(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := initializeIdentities(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// closeDB checkpoints the write-ahead log into the main database file before
+// closing, so an interrupted run doesn't leave a large -wal file behind.
+func closeDB(db *sql.DB) {
+	var busy, logFrames, checkpointed int
+	err := db.QueryRow(`PRAGMA wal_checkpoint(TRUNCATE);`).Scan(&busy, &logFrames, &checkpointed)
+	if err != nil {
+		log.Printf("WAL checkpoint failed: %v", err)
+	} else {
+		log.Printf("WAL checkpoint completed (busy=%d, log=%d, checkpointed=%d).\n", busy, logFrames, checkpointed)
+	}
+
+	if err := db.Close(); err != nil {
+		log.Printf("Failed to close database: %v", err)
+	}
+}
+
+// ensureColumns adds any of the given columns that are missing from table, so
+// databases created by earlier versions pick up new fields.
+func ensureColumns(db dbtx, table string, columns map[string]string) error {
+	quoted, err := quoteIdent(table)
+	if err != nil {
+		return err
+	}
+
+	existing, err := tableColumns(context.Background(), db, quoted)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if existing[name] {
+			continue
+		}
+		column, err := quoteIdent(name)
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s;`, quoted, column, columns[name])); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", name, err)
+		}
+		log.Printf("Added column %s to %s.\n", name, table)
+	}
+	return nil
+}
+
+// fetchFollowers makes an API request to get followers and returns them along with a cursor.
+func fetchFollowers(ctx context.Context, client *apiClient, actor, cursor string) (followers []Follower, newCursor string, err error) {
+	page, err := fetchFollowersPage(ctx, client, actor, cursor)
+	return page.Followers, page.Cursor, err
+}
+
+// fetchFollowersPage is fetchFollowers returning the whole response.
+func fetchFollowersPage(ctx context.Context, client *apiClient, actor, cursor string) (page APIResponse, err error) {
+	ctx, span := tracer.Start(ctx, "fetchFollowers", trace.WithAttributes(
+		attribute.String("actor", actor),
+		attribute.String("cursor", cursor),
+	))
+	defer func() { endSpan(span, err) }()
+
+	query := url.Values{"actor": {actor}, "limit": {strconv.Itoa(pageLimit)}}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	var apiResp APIResponse
+	if client.capture == nil {
+		if err := client.getJSON(ctx, client.xrpcURL("app.bsky.graph.getFollowers", query), &apiResp); err != nil {
+			return APIResponse{}, fmt.Errorf("%w for cursor %s", err, cursor)
+		}
+	} else {
+		// Keep the body as received so the archived copy is byte-for-byte the
+		// API's response, then parse it.
+		var raw json.RawMessage
+		if err := client.getJSON(ctx, client.xrpcURL("app.bsky.graph.getFollowers", query), &raw); err != nil {
+			return APIResponse{}, fmt.Errorf("%w for cursor %s", err, cursor)
+		}
+		if err := client.capture.save(cursor, raw); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		if err := json.Unmarshal(raw, &apiResp); err != nil {
+			return APIResponse{}, fmt.Errorf("failed to unmarshal JSON for cursor %s: %w", cursor, err)
+		}
+	}
+
+	loggerFrom(ctx).Printf("Parsed %d followers from response, new cursor: %s\n", len(apiResp.Followers), apiResp.Cursor)
+	return apiResp, nil
+}
+
+// saveFollowers inserts followers data into the database in a single transaction for batch efficiency.
+// Cancelling ctx aborts the transaction and rolls it back.
+func saveFollowers(ctx context.Context, db *sql.DB, followers []Follower) error {
+	return saveFollowersTo(ctx, db, tableName, followerColumns, followers)
+}
+
+// tolerantSave is set by -tolerant-save: rows that fail to insert are skipped
+// instead of failing the whole page.
+var tolerantSave bool
+
+// saveFollowersTo is saveFollowers for an arbitrary followers-shaped table.
+func saveFollowersTo(ctx context.Context, db *sql.DB, tableName string, columns []column, followers []Follower) error {
+	_, err := saveFollowersContext(ctx, db, tableName, columns, followers, tolerantSave)
+	return err
+}
+
+// saveFollowersContext writes followers to tableName in one transaction. By
+// default a row that fails to insert rolls back the whole batch; with tolerant
+// set it is logged and skipped, and the number of skipped rows is returned.
+// The transaction is bounded by dbTimeout, and fails with errDBTimeout when it
+// runs out.
+func saveFollowersContext(ctx context.Context, db *sql.DB, tableName string, columns []column, followers []Follower, tolerant bool) (failed int, err error) {
+	dbCtx, cancel := withDBTimeout(ctx)
+	defer cancel()
+	failed, err = saveFollowersTx(dbCtx, db, tableName, columns, followers, tolerant)
+	return failed, dbTimeoutError(ctx, dbCtx, err)
+}
+
+// saveFollowersTx is saveFollowersContext without the timeout.
+func saveFollowersTx(ctx context.Context, db *sql.DB, tableName string, columns []column, followers []Follower, tolerant bool) (failed int, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	loggerFrom(ctx).Println("Database transaction started.")
+
+	if failed, err = insertFollowers(ctx, tx, tableName, columns, followers, tolerant); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	loggerFrom(ctx).Println("Transaction committed successfully.")
+	if failed > 0 {
+		loggerFrom(ctx).Printf("Skipped %d of %d followers that failed to save.\n", failed, len(followers))
+	}
+
+	return failed, nil
+}
+
+// insertFollowers is the part of saveFollowersTx that runs inside tx, for
+// callers that make other changes in the same transaction.
+func insertFollowers(ctx context.Context, tx *sql.Tx, tableName string, columns []column, followers []Follower, tolerant bool) (failed int, err error) {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	if validateDIDs {
+		if followers, err = quarantineInvalid(ctx, tx, followers); err != nil {
+			return 0, err
+		}
+	}
+
+	// Bind only the columns the table has, in case it predates the schema.
+	if columns, err = presentColumns(ctx, tx, table, columns); err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, insertSQL(table, columns))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+	//log.Println("Prepared statement for inserting followers.")
+
+	// Number rows on from the highest sequence stored so far, so the API's
+	// ordering survives resumed and repeated crawls.
+	var seq int64
+	if hasColumn(columns, "fetch_seq") {
+		if err := tx.QueryRowContext(ctx, fmt.Sprintf(`SELECT COALESCE(MAX(fetch_seq), 0) FROM %s;`, table)).Scan(&seq); err != nil {
+			return 0, fmt.Errorf("failed to read fetch sequence: %w", err)
+		}
+	}
+
+	// A recrawl stages its labels beside its followers and leaves identities
+	// to the swap, so neither changes unless the recrawl completes.
+	staged := tableName == recrawlTable
+
+	// The minimal schema skips the normalized labels along with the inline column.
+	var labelWriter *labelWriter
+	if hasColumn(columns, "labels") {
+		labels := labelsTable
+		if staged {
+			labels = recrawlLabelsTable
+		}
+		if labelWriter, err = newLabelWriter(ctx, tx, labels); err != nil {
+			return 0, err
+		}
+		defer labelWriter.Close()
+	}
+
+	// saved holds the followers whose row was written, the only ones whose
+	// identity is recorded.
+	saved := make([]Follower, 0, len(followers))
+	for _, follower := range followers {
+		seq++
+		follower.FetchSeq = seq
+		_, err := stmt.ExecContext(ctx, columnValues(columns, follower)...)
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf("save cancelled: %w", ctx.Err())
+		}
+		if err != nil {
+			if !tolerant {
+				return 0, fmt.Errorf("failed to save follower %s: %w", follower.DID, err)
+			}
+			loggerFrom(ctx).Printf("Failed to save follower %s, skipping it: %v", follower.DID, err)
+			failed++
+			continue
+		}
+		saved = append(saved, follower)
+		if labelWriter == nil {
+			continue
+		}
+		if err := labelWriter.replace(ctx, follower); err != nil {
+			if ctx.Err() != nil {
+				return 0, fmt.Errorf("save cancelled: %w", ctx.Err())
+			}
+			if !tolerant {
+				return 0, fmt.Errorf("failed to save labels for follower %s: %w", follower.DID, err)
+			}
+			loggerFrom(ctx).Printf("Failed to save labels for follower %s, skipping them: %v", follower.DID, err)
+			continue
+		}
+		//log.Printf("Follower %s saved.", follower.DID)
+	}
+
+	if !staged {
+		if err := recordIdentities(ctx, tx, followerIdentities(saved), time.Now().UTC()); err != nil {
+			return 0, err
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,270 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestDB initializes a database in a fresh temporary directory and closes
+// it when the test ends.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := initializeDB(filepath.Join(t.TempDir(), dbFile))
+	if err != nil {
+		t.Fatalf("initializeDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// seedFollowers saves followers into the followers table of db.
+func seedFollowers(t *testing.T, db *sql.DB, followers ...Follower) {
+	t.Helper()
+	if err := saveFollowers(context.Background(), db, followers); err != nil {
+		t.Fatalf("saveFollowers: %v", err)
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	for _, name := range []string{"followers", "actor_followers", "_tmp", "T2"} {
+		quoted, err := quoteIdent(name)
+		if err != nil {
+			t.Errorf("quoteIdent(%q): %v", name, err)
+			continue
+		}
+		if want := `"` + name + `"`; quoted != want {
+			t.Errorf("quoteIdent(%q) = %s, want %s", name, quoted, want)
+		}
+	}
+}
+
+func TestQuoteIdentRejectsMalicious(t *testing.T) {
+	for _, name := range []string{
+		"",
+		"followers; DROP TABLE followers",
+		`followers" --`,
+		"followers--",
+		"1followers",
+		"follow ers",
+		"followers/*x*/",
+		"main.followers",
+		"fóllowers",
+		"followers\x00",
+	} {
+		if quoted, err := quoteIdent(name); err == nil {
+			t.Errorf("quoteIdent(%q) = %s, want an error", name, quoted)
+		}
+	}
+}
+
+func TestSaveRejectsMaliciousTable(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	err := saveFollowersTo(ctx, db, `followers"; DROP TABLE runs; --`, followerColumns, []Follower{{DID: "did:plc:a", Handle: "a.test"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid SQL identifier") {
+		t.Fatalf("saveFollowersTo = %v, want an invalid identifier error", err)
+	}
+	if err := newSQLiteStore(db, "x; DROP TABLE runs", followerColumns).Init(ctx); err == nil {
+		t.Fatal("Init of a store with a malicious table name succeeded")
+	}
+	if _, err := db.Exec(`SELECT COUNT(*) FROM runs;`); err != nil {
+		t.Fatalf("runs table is gone: %v", err)
+	}
+}
+
+func TestCloseDBCheckpointsWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), dbFile)
+	db, err := initializeDB(path)
+	if err != nil {
+		t.Fatalf("initializeDB: %v", err)
+	}
+	seedFollowers(t, db, Follower{DID: "did:plc:a", Handle: "a.test"}, Follower{DID: "did:plc:b", Handle: "b.test"})
+
+	// A crawl interrupted mid-save leaves its work in the write-ahead log.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := saveFollowers(ctx, db, []Follower{{DID: "did:plc:c", Handle: "c.test"}}); err == nil {
+		t.Fatal("save with a cancelled context succeeded")
+	}
+	if info, err := os.Stat(path + "-wal"); err != nil || info.Size() == 0 {
+		t.Fatalf("expected a non-empty -wal file before closing: %v", err)
+	}
+
+	closeDB(db)
+	if info, err := os.Stat(path + "-wal"); err == nil && info.Size() > 0 {
+		t.Fatalf("-wal file is %d bytes after closeDB, want it checkpointed", info.Size())
+	}
+
+	db, err = initializeDB(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM followers;`).Scan(&n); err != nil || n != 2 {
+		t.Fatalf("followers after reopening = %d, %v; want the 2 committed rows", n, err)
+	}
+}
+
+func TestMonitorRunsCycles(t *testing.T) {
+	db := openTestDB(t)
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 2)}}
+	c := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), api)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cycles := 0
+	status := monitor(ctx, time.Millisecond, func() (int, bool) {
+		cycles++
+		if _, err := c.Run(ctx); err != nil {
+			t.Errorf("run %d: %v", cycles, err)
+			return 1, true
+		}
+		if cycles == 2 {
+			cancel()
+		}
+		return 0, false
+	})
+
+	if status != 0 || cycles != 2 {
+		t.Fatalf("monitor = %d after %d cycles, want 0 after 2", status, cycles)
+	}
+	if api.requests() != 2 {
+		t.Errorf("mock served %d requests, want one page per cycle", api.requests())
+	}
+	var runs int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM runs WHERE status = 'completed';`).Scan(&runs); err != nil || runs != 2 {
+		t.Errorf("completed runs = %d, %v; want 2", runs, err)
+	}
+}
+
+func TestMonitorStopsWithoutInterval(t *testing.T) {
+	cycles := 0
+	status := monitor(context.Background(), 0, func() (int, bool) {
+		cycles++
+		return 3, false
+	})
+	if status != 3 || cycles != 1 {
+		t.Fatalf("monitor = %d after %d cycles, want 3 after 1", status, cycles)
+	}
+}
+
+func TestValidateCursor(t *testing.T) {
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 1), testFollowers("b", 1)}}
+	client, _ := newTestClient(t, api)
+	ctx := context.Background()
+
+	if err := validateCursor(ctx, client, "did:plc:subject", "c1"); err != nil {
+		t.Errorf("validateCursor(c1) = %v, want nil", err)
+	}
+	err := validateCursor(ctx, client, "did:plc:subject", "expired")
+	if err == nil || !isBadRequest(err) {
+		t.Errorf("validateCursor(expired) = %v, want the API's 400", err)
+	}
+}
+
+func TestValidateCursorEmptyPageWithCursor(t *testing.T) {
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 1), {}, testFollowers("c", 1)}}
+	client, _ := newTestClient(t, api)
+	if err := validateCursor(context.Background(), client, "did:plc:subject", "c1"); err == nil {
+		t.Error("validateCursor accepted an empty page that still carries a cursor")
+	}
+}
+
+func TestSaveFollowersCancelledMidTransactionRollsBack(t *testing.T) {
+	db := openTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel while the second row is being bound, after the first was written.
+	columns := append([]column(nil), followerColumns...)
+	for i, col := range columns {
+		if col.name == "displayName" {
+			value := col.value
+			columns[i].value = func(f Follower) interface{} {
+				if f.DID == "did:plc:1" {
+					cancel()
+				}
+				return value(f)
+			}
+		}
+	}
+	err := saveFollowersTo(ctx, db, tableName, columns, testFollowers("", 3))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("saveFollowersTo = %v, want context.Canceled", err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM followers;`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("followers after a cancelled save = %d, %v; want the transaction rolled back", n, err)
+	}
+
+	if err := saveFollowers(context.Background(), db, testFollowers("", 3)); err != nil {
+		t.Fatalf("saveFollowers: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM followers;`).Scan(&n); err != nil || n != 3 {
+		t.Fatalf("followers after a save = %d, %v; want 3", n, err)
+	}
+}
+
+func TestInitializeDBCreatesDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "data", dbFile)
+	db, err := initializeDB(path)
+	if err != nil {
+		t.Fatalf("initializeDB: %v", err)
+	}
+	defer db.Close()
+	seedFollowers(t, db, Follower{DID: "did:plc:a", Handle: "a.test"})
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("database file was not created: %v", err)
+	}
+}
+
+func TestTolerantSaveSkipsFailingRow(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec(`
+		CREATE TRIGGER reject_bad BEFORE INSERT ON followers WHEN NEW.did = 'did:plc:bad'
+		BEGIN SELECT RAISE(ABORT, 'constraint failed: bad row'); END;
+	`); err != nil {
+		t.Fatal(err)
+	}
+	page := []Follower{{DID: "did:plc:a", Handle: "a.test"}, {DID: "did:plc:bad", Handle: "bad.test"}, {DID: "did:plc:c", Handle: "c.test"}}
+	ctx := context.Background()
+	count := func() int {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM followers;`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	if _, err := saveFollowersContext(ctx, db, tableName, followerColumns, page, false); err == nil {
+		t.Fatal("all-or-nothing save of a page with a failing row succeeded")
+	}
+	if n := count(); n != 0 {
+		t.Errorf("all-or-nothing save stored %d followers, want none", n)
+	}
+
+	failed, err := saveFollowersContext(ctx, db, tableName, followerColumns, page, true)
+	if err != nil || failed != 1 {
+		t.Fatalf("tolerant save = %d failed, %v; want 1 and no error", failed, err)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("tolerant save stored %d followers, want the 2 good ones", n)
+	}
+	for _, f := range page {
+		_, ok, err := lookupIdentity(ctx, db, f.DID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := f.DID != "did:plc:bad"; ok != want {
+			t.Errorf("identity of %s recorded = %v, want %v", f.DID, ok, want)
+		}
+	}
+}
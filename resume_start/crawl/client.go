@@ -0,0 +1,264 @@
+package crawl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultServiceURL = "https://public.api.bsky.app"
+	defaultActor      = "did:plc:z72i7hdynmk6r22z27h6tvur"
+	pageLimit         = 30
+
+	// defaultMaxBodySize bounds how much of a response body is read; a 100-item
+	// page is a small fraction of this.
+	defaultMaxBodySize = 4 << 20
+
+	// htmlPeekSize is how much of a response body is inspected for an HTML
+	// error page before it is handed to the JSON decoder.
+	htmlPeekSize = 512
+
+	// errorSnippetSize is how much of an error response body is kept for the
+	// logs and the returned error.
+	errorSnippetSize = 500
+)
+
+// errBodyTooLarge is returned when a response body exceeds the configured limit.
+var errBodyTooLarge = errors.New("response body exceeds maximum size")
+
+// statusError is a response with a status other than 200 OK. XRPC services
+// describe the failure in a JSON body of the form {"error": ..., "message": ...};
+// when the body is anything else, the start of it is kept instead.
+type statusError struct {
+	code    int
+	status  string
+	xrpc    string // the XRPC error name, such as InvalidRequest
+	message string // the XRPC error message
+	snippet string // the start of a body that isn't an XRPC error
+}
+
+func (e *statusError) Error() string {
+	msg := "unexpected status " + e.status
+	switch {
+	case e.xrpc != "" && e.message != "":
+		return msg + ": " + e.xrpc + ": " + e.message
+	case e.xrpc != "" || e.message != "":
+		return msg + ": " + e.xrpc + e.message
+	case e.snippet != "":
+		return msg + ": " + e.snippet
+	}
+	return msg
+}
+
+// newStatusError reads the start of the body of resp, which has a status
+// other than 200 OK, into a statusError.
+func newStatusError(resp *http.Response) *statusError {
+	e := &statusError{code: resp.StatusCode, status: resp.Status}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, errorSnippetSize))
+	var xrpcErr struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &xrpcErr) == nil && (xrpcErr.Error != "" || xrpcErr.Message != "") {
+		e.xrpc, e.message = sanitizeSnippet(xrpcErr.Error), sanitizeSnippet(xrpcErr.Message)
+	} else {
+		e.snippet = sanitizeSnippet(string(body))
+	}
+	return e
+}
+
+// sanitizeSnippet makes s safe to log on one line: invalid UTF-8 and control
+// characters are dropped and runs of whitespace collapsed.
+func sanitizeSnippet(s string) string {
+	s = strings.ToValidUTF8(s, "")
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// isBadRequest reports whether err is the API rejecting a request as invalid.
+func isBadRequest(err error) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.code == http.StatusBadRequest
+}
+
+// apiClient holds the HTTP settings shared by every API call.
+type apiClient struct {
+	http        *http.Client
+	breaker     *circuitBreaker
+	limiter     *rateLimiter
+	serviceURL  string
+	authToken   string
+	maxBodySize int64
+	profiles    profileCache
+	sleeper     sleeper      // nil sleeps for real
+	conns       *connStats   // records connection reuse when set
+	capture     *pageCapture // archives raw followers pages when set
+	jitter      *jitter      // randomizes retry backoff; nil retries on a fixed schedule
+	pace        pacer        // holds requests back as long as the last Retry-After asked
+}
+
+// sleep returns the sleeper used for backoff between attempts.
+func (c *apiClient) sleep() sleeper {
+	if c.sleeper == nil {
+		return contextSleeper{}
+	}
+	return c.sleeper
+}
+
+// xrpcURL returns the URL of an XRPC method on the service with the given query.
+func (c *apiClient) xrpcURL(method string, query url.Values) string {
+	return c.serviceURL + "/xrpc/" + method + "?" + query.Encode()
+}
+
+// newAPIRequest builds a GET request for url, attaching the bearer token when one is set.
+func (c *apiClient) newAPIRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build API request: %w", err)
+	}
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+	return req, nil
+}
+
+// getJSON requests url with retries and decodes the JSON response into v.
+func (c *apiClient) getJSON(ctx context.Context, url string, v interface{}) error {
+	span := trace.SpanFromContext(ctx)
+	return retry(ctx, c.breaker, c.sleep(), c.jitter, func(attempt int) error {
+		span.SetAttributes(attribute.Int("attempt", attempt))
+		if err := c.limiter.wait(ctx); err != nil {
+			return err
+		}
+		if d := c.pace.delay(time.Now()); d > 0 {
+			loggerFrom(ctx).Printf("Rate limited by the API, waiting %v before the next request.\n", d.Round(time.Millisecond))
+			if err := c.sleep().Sleep(ctx, d); err != nil {
+				return err
+			}
+		}
+		loggerFrom(ctx).Printf("Attempt %d: Making API request to URL: %s\n", attempt, url)
+		reqCtx := ctx
+		if c.conns != nil {
+			reqCtx = c.conns.trace(ctx)
+		}
+		req, err := c.newAPIRequest(reqCtx, url)
+		if err != nil {
+			return err
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to make API request: %w", err)
+		}
+		defer resp.Body.Close()
+		span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				c.pace.holdUntil(time.Now().Add(d))
+			}
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			se := newStatusError(resp)
+			if se.xrpc != "" || se.message != "" {
+				loggerFrom(ctx).Printf("API returned %s: error=%q message=%q\n", resp.Status, se.xrpc, se.message)
+			} else {
+				loggerFrom(ctx).Printf("API returned %s: body=%q\n", resp.Status, se.snippet)
+			}
+			switch resp.StatusCode {
+			case http.StatusUnauthorized:
+				return permanent(fmt.Errorf("%s requires authentication, pass -auth-token: %w", url, se))
+			case http.StatusBadRequest:
+				// The request itself is wrong, such as a cursor the API no
+				// longer accepts; sending it again can't help.
+				return permanent(se)
+			}
+			return se
+		}
+
+		loggerFrom(ctx).Println("API request successful, parsing response body.")
+		return decodeBody(resp.Body, c.maxBodySize, v)
+	})
+}
+
+// decodeBody parses the JSON in r into v as it is read, rather than buffering
+// the whole body first. Bodies longer than maxSize fail with errBodyTooLarge; a
+// non-positive maxSize disables the limit. HTML error pages are recognized
+// from their first htmlPeekSize bytes without consuming them.
+func decodeBody(r io.Reader, maxSize int64, v interface{}) error {
+	if maxSize > 0 {
+		r = &limitReader{r: r, remaining: maxSize, max: maxSize}
+	}
+	br := bufio.NewReader(r)
+
+	// Peek returns what it could along with an error for short bodies; the
+	// decoder reports those below.
+	head, _ := br.Peek(htmlPeekSize)
+	if looksLikeHTML(head) {
+		return errors.New("received HTML response (likely an error page)")
+	}
+
+	if err := json.NewDecoder(br).Decode(v); err != nil {
+		if errors.Is(err, errBodyTooLarge) {
+			return err
+		}
+		return fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return nil
+}
+
+// looksLikeHTML reports whether a body starting with head is an HTML document
+// rather than JSON. Leading whitespace is ignored, as it is by JSON.
+func looksLikeHTML(head []byte) bool {
+	head = bytes.ToLower(bytes.TrimLeft(head, " \t\r\n"))
+	return bytes.HasPrefix(head, []byte("<!doctype")) || bytes.HasPrefix(head, []byte("<html"))
+}
+
+// limitReader reads from r until more than max bytes have been read, then
+// fails with errBodyTooLarge.
+type limitReader struct {
+	r         io.Reader
+	remaining int64
+	max       int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// Anything beyond the limit means the body is too large; only a clean
+		// end of the body is let through.
+		var probe [1]byte
+		if n, err := l.r.Read(probe[:]); n > 0 {
+			return 0, fmt.Errorf("%w of %d bytes", errBodyTooLarge, l.max)
+		} else if err != nil {
+			return 0, err
+		}
+		return 0, nil
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
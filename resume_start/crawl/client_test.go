@@ -0,0 +1,168 @@
+package crawl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetJSONRejectsOversizedBody(t *testing.T) {
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stream a valid but endless-looking array well past the limit.
+		fmt.Fprint(w, `{"followers":[`)
+		for i := 0; i < 1000; i++ {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, `{"did":"did:plc:%d","handle":"%s"}`, i, strings.Repeat("x", 40))
+		}
+		fmt.Fprint(w, `]}`)
+	}))
+	client.maxBodySize = 1024
+
+	var v APIResponse
+	err := client.getJSON(context.Background(), client.xrpcURL("app.bsky.graph.getFollowers", nil), &v)
+	if !errors.Is(err, errBodyTooLarge) {
+		t.Fatalf("getJSON = %v, want errBodyTooLarge", err)
+	}
+}
+
+func TestGetJSONAcceptsBodyWithinLimit(t *testing.T) {
+	body := `{"followers":[{"did":"did:plc:a","handle":"a.test"}],"cursor":"next"}`
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	client.maxBodySize = int64(len(body))
+
+	var v APIResponse
+	if err := client.getJSON(context.Background(), client.xrpcURL("app.bsky.graph.getFollowers", nil), &v); err != nil {
+		t.Fatalf("getJSON: %v", err)
+	}
+	if len(v.Followers) != 1 || v.Cursor != "next" {
+		t.Fatalf("decoded %+v", v)
+	}
+}
+
+func TestDecodeBodyMatchesUnmarshal(t *testing.T) {
+	var followers []string
+	for i := 0; i < 50; i++ {
+		followers = append(followers, fmt.Sprintf(`{"did":"did:plc:%d","handle":"h%d.test","labels":[{"type":"t","value":"v"}]}`, i, i))
+	}
+	for _, body := range []string{
+		`{"followers":[],"cursor":""}`,
+		"\n  " + `{"followers":[{"did":"did:plc:a","handle":"a.test"}],"cursor":"next"}`,
+		`{"followers":[` + strings.Join(followers, ",") + `],"cursor":"c9"}`,
+	} {
+		var want, got APIResponse
+		if err := json.Unmarshal([]byte(body), &want); err != nil {
+			t.Fatal(err)
+		}
+		if err := decodeBody(strings.NewReader(body), defaultMaxBodySize, &got); err != nil {
+			t.Errorf("decodeBody(%.40q): %v", body, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("decodeBody(%.40q) = %+v, want %+v", body, got, want)
+		}
+	}
+}
+
+func TestDecodeBodyDetectsHTML(t *testing.T) {
+	for _, body := range []string{
+		"<!DOCTYPE html><html><body>502 Bad Gateway</body></html>",
+		"\r\n  <html><head><title>Error</title></head></html>",
+	} {
+		var v APIResponse
+		err := decodeBody(strings.NewReader(body), defaultMaxBodySize, &v)
+		if err == nil || !strings.Contains(err.Error(), "HTML") {
+			t.Errorf("decodeBody(%q) = %v, want an HTML error", body, err)
+		}
+	}
+	var v APIResponse
+	if err := decodeBody(strings.NewReader(`{"followers":[`), defaultMaxBodySize, &v); err == nil || strings.Contains(err.Error(), "HTML") {
+		t.Errorf("decodeBody of truncated JSON = %v, want a JSON error", err)
+	}
+}
+
+func TestGetJSONPeeksForHTML(t *testing.T) {
+	for _, tc := range []struct {
+		name, body string
+		html       bool
+	}{
+		{"html", "<!DOCTYPE html>\n<html>" + strings.Repeat("x", 2*htmlPeekSize) + "</html>", true},
+		{"html after whitespace", "\n\t <HTML><body>Bad Gateway</body></HTML>", true},
+		{"json", `{"followers":[{"did":"did:plc:a","handle":"a.test"}],"cursor":"next"}`, false},
+		{"json after whitespace", "\r\n\t  " + `{"followers":[{"did":"did:plc:a","handle":"a.test"}],"cursor":"next"}`, false},
+		{"json after a peek of whitespace", strings.Repeat(" ", htmlPeekSize+1) + `{"followers":[{"did":"did:plc:a","handle":"a.test"}],"cursor":"next"}`, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tc.body)
+			}))
+			var v APIResponse
+			err := client.getJSON(context.Background(), client.xrpcURL("app.bsky.graph.getFollowers", nil), &v)
+			if tc.html {
+				if err == nil || !strings.Contains(err.Error(), "HTML") {
+					t.Errorf("getJSON = %v, want an HTML error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getJSON: %v", err)
+			}
+			if len(v.Followers) != 1 || v.Cursor != "next" {
+				t.Errorf("decoded %+v", v)
+			}
+		})
+	}
+}
+
+func TestGetJSONLogsXRPCError(t *testing.T) {
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"InvalidRequest","message":"Profile not found:\n\tno-such.test"}`)
+	}))
+	logs := captureLog(t)
+
+	var v APIResponse
+	err := client.getJSON(context.Background(), client.xrpcURL("app.bsky.graph.getFollowers", nil), &v)
+	var se *statusError
+	if !errors.As(err, &se) {
+		t.Fatalf("getJSON = %v, want a statusError", err)
+	}
+	if se.code != http.StatusBadRequest || se.xrpc != "InvalidRequest" || se.message != "Profile not found: no-such.test" {
+		t.Errorf("statusError = %+v, want the XRPC error and message", se)
+	}
+	if want := `API returned 400 Bad Request: error="InvalidRequest" message="Profile not found: no-such.test"`; !strings.Contains(logs.String(), want) {
+		t.Errorf("log lacks %s:\n%s", want, logs)
+	}
+	if want := "unexpected status 400 Bad Request: InvalidRequest: Profile not found: no-such.test"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func TestGetJSONLogsBodySnippet(t *testing.T) {
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "bad\x00 \x1b[31mgateway\r\n"+strings.Repeat("x", 2*errorSnippetSize))
+	}))
+	logs := captureLog(t)
+
+	var v APIResponse
+	err := client.getJSON(context.Background(), client.xrpcURL("app.bsky.graph.getFollowers", nil), &v)
+	var se *statusError
+	if !errors.As(err, &se) {
+		t.Fatalf("getJSON = %v, want a statusError", err)
+	}
+	if !strings.HasPrefix(se.snippet, "bad [31mgateway x") || len(se.snippet) > errorSnippetSize {
+		t.Errorf("snippet = %q, want the start of the body without control characters", se.snippet)
+	}
+	if !strings.Contains(logs.String(), `API returned 400 Bad Request: body="bad [31mgateway x`) {
+		t.Errorf("snippet not logged:\n%s", logs)
+	}
+}
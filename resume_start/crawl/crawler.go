@@ -0,0 +1,473 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// crawler holds the dependencies and settings of a followers crawl.
+type crawler struct {
+	store      Store
+	db         *sql.DB // backs the cursor log and page hashes; nil disables them
+	client     *apiClient
+	actor      string
+	writeMu    *sync.Mutex // serializes writes when crawlers share the database
+	filters    []followerFilter
+	cursorFile string
+	cursorLog  bool
+	failFast   bool
+
+	// commitBatch is the number of rows committed per transaction; 0 commits
+	// each page on its own. pending holds rows fetched but not yet committed,
+	// and pendingCursor the cursor of the page the first of them came from.
+	commitBatch   int
+	pending       []Follower
+	pendingCursor string
+
+	maxPages  int           // stop after this many pages; 0 crawls to the end
+	pageDelay time.Duration // pause after each saved page before fetching the next
+
+	// maxConsecutiveEmpty stops the crawl after this many pages in a row
+	// without a follower that isn't stored yet; 0 never stops early.
+	maxConsecutiveEmpty int
+
+	// checkpointFile, when set, receives the crawl's progress after every page.
+	// checkpoint is the progress so far; a loaded checkpoint whose cursor
+	// matches the starting cursor is continued rather than restarted.
+	checkpointFile string
+	checkpoint     *checkpoint
+
+	logger *log.Logger // nil logs through the standard logger
+	// scope is logger with the fields of the crawl and page in progress.
+	scope *log.Logger
+}
+
+// errPageCap is returned when a crawl stops at its -max-pages limit.
+var errPageCap = errors.New("page cap reached")
+
+// errStaleCursor is returned when the API rejects the cursor a crawl resumed
+// from, typically because it was stored long enough ago to have expired.
+var errStaleCursor = errors.New("stale cursor")
+
+// errNoProgress is returned when a crawl stops at its -max-consecutive-empty limit.
+var errNoProgress = errors.New("no new followers")
+
+// maxSaveAttempts bounds how often a page is saved before the crawl gives up on it.
+const maxSaveAttempts = 3
+
+// maxCursorEchoes bounds how often in a row a page may return the cursor it
+// was fetched with while still listing followers the previous page didn't.
+const maxCursorEchoes = 3
+
+// maxEmptyPageRetries bounds how often a page that came back empty but with a
+// further cursor is refetched before the crawl accepts it and moves on.
+const maxEmptyPageRetries = 3
+
+// logf logs a message about the crawl, tagged with the crawl and page in
+// progress if any.
+func (c *crawler) logf(format string, args ...interface{}) {
+	switch {
+	case c.scope != nil:
+		c.scope.Printf(format, args...)
+	case c.logger != nil:
+		c.logger.Printf(format, args...)
+	default:
+		log.Printf(format, args...)
+	}
+}
+
+// save stores a page of followers, retrying a bounded number of times unless
+// failFast is set, so a failing page is never silently skipped.
+func (c *crawler) save(ctx context.Context, followers []Follower) error {
+	attempts := maxSaveAttempts
+	if c.failFast {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		c.logf("Starting database transaction to save followers.\n")
+		saveCtx, saveSpan := tracer.Start(ctx, "saveFollowers", trace.WithAttributes(attribute.Int("rows", len(followers))))
+		err = c.saveOnce(saveCtx, followers)
+		endSpan(saveSpan, err)
+		if err == nil || ctx.Err() != nil || attempt == attempts {
+			break
+		}
+
+		if errors.Is(err, errDBTimeout) {
+			c.logf("Database is locked by another process (attempt %d/%d): %v. Retrying...\n", attempt, attempts, err)
+		} else {
+			c.logf("Error saving followers batch (attempt %d/%d): %v. Retrying...\n", attempt, attempts, err)
+		}
+		if err := c.client.sleep().Sleep(ctx, time.Duration(attempt)*time.Second); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// saveOnce writes a page in one transaction, holding the shared write lock if any.
+func (c *crawler) saveOnce(ctx context.Context, followers []Follower) error {
+	return c.locked(func() error {
+		return c.store.Save(ctx, followers)
+	})
+}
+
+// locked runs fn holding the shared write lock if any.
+func (c *crawler) locked(fn func() error) error {
+	if c.writeMu != nil {
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+	}
+	return fn()
+}
+
+// buffer queues a page fetched at cursor and commits every commitBatch rows.
+// It returns the cursor a resumed crawl must start from so that every row not
+// yet committed is fetched again.
+func (c *crawler) buffer(ctx context.Context, cursor, newCursor string, followers []Follower) (string, error) {
+	if len(c.pending) == 0 {
+		c.pendingCursor = cursor
+	}
+	c.pending = append(c.pending, followers...)
+
+	batch := c.commitBatch
+	if batch <= 0 {
+		batch = len(c.pending)
+	}
+	for len(c.pending) > 0 && len(c.pending) >= batch {
+		if err := c.save(ctx, c.pending[:batch]); err != nil {
+			return c.pendingCursor, err
+		}
+		c.logf("Committed %d followers.\n", batch)
+		c.countSaved(batch)
+		c.pending = c.pending[batch:]
+		// Anything left over came from the page just fetched.
+		c.pendingCursor = cursor
+	}
+
+	if len(c.pending) == 0 {
+		c.pending = nil
+		c.pendingCursor = newCursor
+	}
+	return c.pendingCursor, nil
+}
+
+// flush commits any buffered rows. It runs on completion and on shutdown, so
+// it ignores cancellation of ctx.
+func (c *crawler) flush(ctx context.Context) error {
+	if len(c.pending) == 0 {
+		return nil
+	}
+	if err := c.save(context.WithoutCancel(ctx), c.pending); err != nil {
+		return err
+	}
+	c.logf("Committed %d buffered followers.\n", len(c.pending))
+	c.countSaved(len(c.pending))
+	c.pending = nil
+	return nil
+}
+
+// stop flushes buffered rows before the crawl returns early while positioned
+// at cursor, and returns the cursor to resume from.
+func (c *crawler) stop(ctx context.Context, cursor string) string {
+	if err := c.flush(ctx); err != nil {
+		c.logf("Failed to commit buffered followers: %v", err)
+		return c.pendingCursor
+	}
+	c.recordProgress(cursor)
+	return cursor
+}
+
+// countSaved adds n committed followers to the checkpoint.
+func (c *crawler) countSaved(n int) {
+	if c.checkpoint != nil {
+		c.checkpoint.FollowersSaved += n
+	}
+}
+
+// recordProgress records cursor as the position to resume from in the
+// database, and in the cursor file and checkpoint, whichever are enabled.
+func (c *crawler) recordProgress(cursor string) {
+	if cursor == "" {
+		return
+	}
+	c.recordDBCursor(cursor)
+	if c.cursorFile != "" {
+		if err := writeCursorFile(c.cursorFile, cursor); err != nil {
+			c.logf("Failed to write cursor file: %v", err)
+		}
+	}
+	if c.checkpointFile != "" {
+		c.checkpoint.Cursor = cursor
+		if err := writeCheckpoint(c.checkpointFile, c.checkpoint); err != nil {
+			c.logf("Failed to write checkpoint: %v", err)
+		}
+	}
+}
+
+// recordDBCursor stores cursor as the position to resume from in the database,
+// if there is one; an empty cursor clears it.
+func (c *crawler) recordDBCursor(cursor string) {
+	if c.db == nil {
+		return
+	}
+	if err := c.locked(func() error { return setMetadata(context.Background(), c.db, cursorKey(c.actor), cursor) }); err != nil {
+		c.logf("Failed to record cursor in the database: %v", err)
+	}
+}
+
+// recordDone clears the cursor in the database, removes the cursor file and
+// marks the checkpoint done, keeping it so the final state stays visible.
+func (c *crawler) recordDone() {
+	c.recordDBCursor("")
+	if c.cursorFile != "" {
+		if err := removeCursorFile(c.cursorFile); err != nil {
+			c.logf("Failed to remove cursor file: %v", err)
+		}
+	}
+	if c.checkpointFile != "" {
+		c.checkpoint.Cursor = ""
+		c.checkpoint.Done = true
+		if err := writeCheckpoint(c.checkpointFile, c.checkpoint); err != nil {
+			c.logf("Failed to write checkpoint: %v", err)
+		}
+	}
+}
+
+// containsAll reports whether every follower's DID is in dids.
+func containsAll(dids map[string]bool, followers []Follower) bool {
+	for _, f := range followers {
+		if !dids[f.DID] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasNewFollower reports whether any of followers was neither seen earlier in
+// the crawl nor stored before it.
+func (c *crawler) hasNewFollower(ctx context.Context, seen map[string]string, followers []Follower) (bool, error) {
+	for _, f := range followers {
+		if _, ok := seen[f.DID]; ok {
+			continue
+		}
+		_, stored, err := c.store.Get(ctx, f.DID)
+		if err != nil {
+			return false, err
+		}
+		if !stored {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// crawl fetches and saves followers page by page starting at cursor until the
+// API returns no further cursor. It returns the handles seen, keyed by DID.
+func (c *crawler) crawl(ctx context.Context, cursor string) (seen map[string]string, err error) {
+	ctx, span := tracer.Start(ctx, "crawl", trace.WithAttributes(attribute.String("actor", c.actor), attribute.String("cursor", cursor)))
+	defer func() { endSpan(span, err) }()
+
+	// Every line logged during the crawl, including by the API client and the
+	// save, carries the crawl's ID and actor and the page being processed.
+	crawlLog := withFields(c.logger, "crawl", newCrawlID(), "actor", c.actor)
+	defer func() { c.scope = nil }()
+	crawlCtx := ctx
+
+	seen = make(map[string]string)
+	c.pending, c.pendingCursor = nil, cursor
+	emptyRetries, pages, noProgress, echoes := 0, 0, 0, 0
+	var lastPage map[string]bool // DIDs of the previous page fetched
+	subjectRecorded := false
+	resumed := cursor != ""
+	if c.checkpointFile != "" && (c.checkpoint == nil || c.checkpoint.Done || c.checkpoint.Cursor != cursor) {
+		c.checkpoint = &checkpoint{Actor: c.actor, Cursor: cursor, StartedAt: time.Now().UTC()}
+	}
+	for {
+		c.scope = withFields(crawlLog, "page", strconv.Itoa(pages+1))
+		ctx := withLogger(crawlCtx, c.scope)
+		if ctx.Err() != nil {
+			c.logf("Shutdown requested, stopping at cursor: %s\n", c.stop(ctx, cursor))
+			return seen, ctx.Err()
+		}
+		c.logf("Fetching followers with cursor: %s\n", cursor)
+
+		// Fetch data from API and parse the result.
+		page, err := fetchFollowersPage(ctx, c.client, c.actor, cursor)
+		followers, newCursor := page.Followers, page.Cursor
+		if errors.Is(err, errCircuitOpen) {
+			return seen, fmt.Errorf("aborting crawl at cursor %s: %w", c.stop(ctx, cursor), err)
+		}
+		if ctx.Err() != nil {
+			c.logf("Shutdown requested, stopping at cursor: %s\n", c.stop(ctx, cursor))
+			return seen, ctx.Err()
+		}
+		if err != nil && resumed && pages == 0 && isBadRequest(err) {
+			return seen, fmt.Errorf("%w: %v", errStaleCursor, err)
+		}
+		if err != nil {
+			c.logf("Error fetching followers: %v. Applying backoff and retrying...\n", err)
+			c.client.sleep().Sleep(ctx, 2*time.Second) // Short delay before retrying
+			continue
+		}
+		c.logf("Fetched %d followers with cursor: %s\n", len(followers), cursor)
+
+		// Some servers return the last page's own cursor instead of none. The
+		// page is then fetched again; once it lists nothing new it is the end.
+		if newCursor != "" && newCursor == cursor {
+			if containsAll(lastPage, followers) {
+				c.logf("Cursor %s was returned again with the same followers, treating it as the last page.\n", cursor)
+				followers, newCursor = nil, ""
+			} else {
+				echoes++
+				if echoes > maxCursorEchoes {
+					return seen, fmt.Errorf("API keeps returning cursor %s with different followers (resume with -cursor %q)", cursor, c.stop(ctx, cursor))
+				}
+			}
+		} else {
+			echoes = 0
+		}
+		lastPage = make(map[string]bool, len(followers))
+		for _, f := range followers {
+			lastPage[f.DID] = true
+		}
+
+		// An empty page that still points further is usually a transient API
+		// glitch; advancing past it could skip followers. The terminal page has
+		// no cursor and is handled below.
+		if len(followers) == 0 && newCursor != "" {
+			if emptyRetries < maxEmptyPageRetries {
+				emptyRetries++
+				c.logf("Anomaly: empty page with a further cursor at %s, refetching (%d/%d)...\n", cursor, emptyRetries, maxEmptyPageRetries)
+				c.client.sleep().Sleep(ctx, time.Duration(emptyRetries)*time.Second)
+				continue
+			}
+			c.logf("Warning: page at cursor %s stayed empty after %d retries, advancing to %s.\n", cursor, maxEmptyPageRetries, newCursor)
+		}
+		emptyRetries = 0
+		pages++
+		if !subjectRecorded && page.Subject != nil && c.db != nil {
+			if err := c.locked(func() error { return recordSubject(ctx, c.db, c.actor, *page.Subject) }); err != nil {
+				c.logf("Failed to record subject: %v", err)
+			} else {
+				subjectRecorded = true
+			}
+		}
+		if c.checkpoint != nil {
+			c.checkpoint.PagesDone++
+		}
+
+		if c.cursorLog && c.db != nil {
+			if err := appendCursorLog(ctx, c.db, newCursor, len(followers)); err != nil {
+				c.logf("Failed to record cursor log: %v", err)
+			}
+		}
+
+		followers = applyFilters(c.filters, followers)
+
+		if c.maxConsecutiveEmpty > 0 {
+			if fresh, err := c.hasNewFollower(ctx, seen, followers); err != nil {
+				c.logf("Failed to check for new followers: %v", err)
+			} else if fresh {
+				noProgress = 0
+			} else {
+				noProgress++
+			}
+		}
+
+		// A resumed crawl can refetch pages an earlier run already saved; an
+		// unchanged page is not written again.
+		hash := pageHash(followers)
+		skip := false
+		if resumed && len(c.pending) == 0 && c.db != nil {
+			if skip, err = pageSaved(ctx, c.db, c.actor, cursor, hash); err != nil {
+				c.logf("Failed to check page hash: %v", err)
+			} else if skip {
+				c.logf("Page at cursor %s was already saved, skipping.\n", cursor)
+			}
+		}
+
+		// Insert followers into the database, one transaction per page or per
+		// commitBatch rows.
+		resumeCursor := newCursor
+		if !skip {
+			resumeCursor, err = c.buffer(ctx, cursor, newCursor, followers)
+		}
+		if err == nil && !skip && len(c.pending) == 0 && c.db != nil {
+			// Only pages committed on their own are recorded; rows still
+			// buffered for a larger batch may yet be lost.
+			if err := c.locked(func() error { return recordPageHash(ctx, c.db, c.actor, cursor, hash) }); err != nil {
+				c.logf("Failed to record page hash: %v", err)
+			}
+		}
+		if err != nil {
+			// Keep the position of the page that failed, so nothing is lost
+			// even when the crawl ends here.
+			c.recordProgress(resumeCursor)
+			if ctx.Err() != nil {
+				c.logf("Shutdown requested, stopping at cursor: %s\n", resumeCursor)
+				return seen, ctx.Err()
+			}
+			return seen, fmt.Errorf("failed to save page (resume with -cursor %q): %w", resumeCursor, err)
+		}
+
+		for _, follower := range followers {
+			seen[follower.DID] = normalizeHandle(follower.Handle)
+		}
+
+		// If there is no new cursor, we reached the end of the data.
+		if newCursor == "" {
+			if err := c.flush(ctx); err != nil {
+				c.recordProgress(c.pendingCursor)
+				return seen, fmt.Errorf("failed to save page (resume with -cursor %q): %w", c.pendingCursor, err)
+			}
+			c.logf("No new cursor found, all followers processed.\n")
+			if c.db != nil {
+				if err := c.locked(func() error { return clearPageHashes(ctx, c.db, c.actor) }); err != nil {
+					c.logf("Failed to clear page hashes: %v", err)
+				}
+			}
+			c.recordDone()
+			return seen, nil
+		}
+
+		c.recordProgress(resumeCursor)
+
+		if c.maxConsecutiveEmpty > 0 && noProgress >= c.maxConsecutiveEmpty {
+			// What follows is most likely stored already, so there is
+			// nothing worth resuming from.
+			if err := c.flush(ctx); err != nil {
+				c.recordProgress(c.pendingCursor)
+				return seen, fmt.Errorf("failed to save page (resume with -cursor %q): %w", c.pendingCursor, err)
+			}
+			c.logf("Stopping: %d pages in a row brought no new followers.\n", noProgress)
+			c.recordDone()
+			return seen, errNoProgress
+		}
+
+		if c.maxPages > 0 && pages >= c.maxPages {
+			resumeCursor = c.stop(ctx, newCursor)
+			c.logf("Page cap of %d reached, stopping. Resume with -cursor %q.\n", c.maxPages, resumeCursor)
+			return seen, errPageCap
+		}
+
+		// Update cursor for the next iteration.
+		c.logf("Updating cursor to: %s\n", newCursor)
+		cursor = newCursor
+
+		// Cancellation ends the pause early and is handled at the top of the loop.
+		if c.pageDelay > 0 {
+			c.client.sleep().Sleep(ctx, c.pageDelay)
+		}
+	}
+}
@@ -0,0 +1,500 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// followersAPI is a mock getFollowers endpoint serving pages in order: the
+// first without a cursor, page i at cursor "c<i>". It records the cursor of
+// every request.
+type followersAPI struct {
+	mu      sync.Mutex
+	pages   [][]Follower
+	cursors []string
+}
+
+func (a *followersAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cursor := r.URL.Query().Get("cursor")
+	a.mu.Lock()
+	a.cursors = append(a.cursors, cursor)
+	pages := a.pages
+	a.mu.Unlock()
+
+	i := 0
+	if cursor != "" {
+		if _, err := fmt.Sscanf(cursor, "c%d", &i); err != nil || i >= len(pages) {
+			http.Error(w, `{"error":"InvalidRequest","message":"malformed cursor"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	resp := APIResponse{Followers: []Follower{}}
+	if i < len(pages) {
+		resp.Followers = pages[i]
+	}
+	if i+1 < len(pages) {
+		resp.Cursor = fmt.Sprintf("c%d", i+1)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// requests returns the number of requests served.
+func (a *followersAPI) requests() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.cursors)
+}
+
+// testFollowers returns n followers whose DIDs and handles start with prefix.
+func testFollowers(prefix string, n int) []Follower {
+	followers := make([]Follower, n)
+	for i := range followers {
+		followers[i] = Follower{DID: fmt.Sprintf("did:plc:%s%d", prefix, i), Handle: fmt.Sprintf("%s%d.test", prefix, i)}
+	}
+	return followers
+}
+
+// newTestCrawler returns a Crawler saving into the followers table of db,
+// fetching from a mock server serving h.
+func newTestCrawler(t *testing.T, db Store, h http.Handler, opts ...Option) *Crawler {
+	t.Helper()
+	client, _ := newTestClient(t, h)
+	opts = append([]Option{WithActor("did:plc:subject"), WithStore(db), withAPIClient(client)}, opts...)
+	c, err := NewCrawler(context.Background(), opts...)
+	if err != nil {
+		t.Fatalf("NewCrawler: %v", err)
+	}
+	return c
+}
+
+// flakyStore fails the first failures saves, then saves into the wrapped store.
+type flakyStore struct {
+	Store
+	failures int
+	saves    int
+}
+
+func (s *flakyStore) Save(ctx context.Context, followers []Follower) error {
+	s.saves++
+	if s.saves <= s.failures {
+		return errors.New("disk I/O error")
+	}
+	return s.Store.Save(ctx, followers)
+}
+
+func TestFailFastAbortsOnFirstSaveError(t *testing.T) {
+	mem := newMemoryStore()
+	store := &flakyStore{Store: mem, failures: 1}
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 2), testFollowers("b", 2)}}
+	c := newTestCrawler(t, store, api)
+	c.failFast = true
+
+	_, err := c.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `resume with -cursor ""`) {
+		t.Fatalf("Run = %v, want a save error naming the cursor to resume from", err)
+	}
+	if store.saves != 1 {
+		t.Errorf("saves = %d, want the crawl to stop after the first", store.saves)
+	}
+	if n, _ := mem.Count(context.Background()); n != 0 {
+		t.Errorf("stored %d followers, want none", n)
+	}
+}
+
+func TestSaveErrorRetriesPage(t *testing.T) {
+	mem := newMemoryStore()
+	store := &flakyStore{Store: mem, failures: 1}
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 2), testFollowers("b", 2)}}
+	c := newTestCrawler(t, store, api)
+	sleeps := c.client.sleeper.(*fakeSleeper)
+
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := sleeps.durations(); !reflect.DeepEqual(got, []time.Duration{time.Second}) {
+		t.Errorf("waited %v before retrying the save, want 1s through the client's sleeper", got)
+	}
+	if store.saves != 3 {
+		t.Errorf("saves = %d, want the first page saved twice and the second once", store.saves)
+	}
+	for _, f := range append(testFollowers("a", 2), testFollowers("b", 2)...) {
+		if _, ok, _ := mem.Get(context.Background(), f.DID); !ok {
+			t.Errorf("follower %s was skipped", f.DID)
+		}
+	}
+	if api.requests() != 2 {
+		t.Errorf("mock served %d requests, want the failed page retried without refetching", api.requests())
+	}
+}
+
+// batchRecorder records the size of every batch saved into the wrapped store.
+type batchRecorder struct {
+	Store
+	batches []int
+}
+
+func (s *batchRecorder) Save(ctx context.Context, followers []Follower) error {
+	s.batches = append(s.batches, len(followers))
+	return s.Store.Save(ctx, followers)
+}
+
+func TestCommitBatchBoundaries(t *testing.T) {
+	store := &batchRecorder{Store: newMemoryStore()}
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 100), testFollowers("b", 100), testFollowers("c", 100)}}
+	c := newTestCrawler(t, store, api)
+	c.commitBatch = 250
+
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if want := []int{250, 50}; !reflect.DeepEqual(store.batches, want) {
+		t.Errorf("committed batches = %v, want %v", store.batches, want)
+	}
+	if n, _ := store.Count(context.Background()); n != 300 {
+		t.Errorf("stored %d followers, want 300", n)
+	}
+}
+
+func TestCommitBatchResumesBeforeUncommittedRows(t *testing.T) {
+	store := &batchRecorder{Store: newMemoryStore()}
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 100), testFollowers("b", 100), testFollowers("c", 100)}}
+	c := newTestCrawler(t, store, api)
+	c.commitBatch = 250
+
+	cursor, err := c.buffer(context.Background(), "", "c1", api.pages[0])
+	if err != nil || cursor != "" || len(store.batches) != 0 {
+		t.Fatalf("after page 1: cursor %q, batches %v, err %v; want nothing committed, resume from the start", cursor, store.batches, err)
+	}
+	cursor, err = c.buffer(context.Background(), "c1", "c2", api.pages[1])
+	if err != nil || cursor != "" || len(store.batches) != 0 {
+		t.Fatalf("after page 2: cursor %q, batches %v, err %v; want nothing committed, resume from the start", cursor, store.batches, err)
+	}
+	cursor, err = c.buffer(context.Background(), "c2", "", api.pages[2])
+	if err != nil || cursor != "c2" || !reflect.DeepEqual(store.batches, []int{250}) {
+		t.Fatalf("after page 3: cursor %q, batches %v, err %v; want 250 committed, resume at the third page", cursor, store.batches, err)
+	}
+}
+
+// fetchOrder returns the stored DIDs and their sequence numbers in fetch_seq order.
+func fetchOrder(t *testing.T, db *sql.DB) (dids []string, seqs []int64) {
+	t.Helper()
+	rows, err := db.Query(`SELECT did, fetch_seq FROM followers ORDER BY fetch_seq;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var did string
+		var seq int64
+		if err := rows.Scan(&did, &seq); err != nil {
+			t.Fatal(err)
+		}
+		dids = append(dids, did)
+		seqs = append(seqs, seq)
+	}
+	return dids, seqs
+}
+
+func TestFetchSeqMonotonicAcrossPages(t *testing.T) {
+	db := openTestDB(t)
+	// DIDs sort the other way round from the API's order.
+	pages := [][]Follower{
+		{{DID: "did:plc:z", Handle: "z.test"}, {DID: "did:plc:y", Handle: "y.test"}},
+		{{DID: "did:plc:x", Handle: "x.test"}, {DID: "did:plc:w", Handle: "w.test"}},
+	}
+	c := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), &followersAPI{pages: pages})
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	dids, seqs := fetchOrder(t, db)
+	if want := []string{"did:plc:z", "did:plc:y", "did:plc:x", "did:plc:w"}; !reflect.DeepEqual(dids, want) {
+		t.Errorf("followers by fetch_seq = %v, want the API's order %v", dids, want)
+	}
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] <= seqs[i-1] {
+			t.Errorf("fetch_seq %v is not strictly increasing", seqs)
+		}
+	}
+
+	// A resumed crawl numbers the rows it saves on from the highest so far.
+	resumed := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), &followersAPI{pages: pages}, WithCursor("c1"))
+	if _, err := resumed.Run(context.Background()); err != nil {
+		t.Fatalf("resumed Run: %v", err)
+	}
+	if _, after := fetchOrder(t, db); after[len(after)-1] != seqs[len(seqs)-1]+2 {
+		t.Errorf("fetch_seq after resuming = %v, want it continued from %d", after, seqs[len(seqs)-1])
+	}
+}
+
+// glitchyAPI serves pages like followersAPI, but answers the first empty
+// requests for cursor "c1" with no followers and a further cursor.
+type glitchyAPI struct {
+	followersAPI
+	empty int
+}
+
+func (a *glitchyAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("cursor") == "c1" && a.empty > 0 {
+		a.empty--
+		a.mu.Lock()
+		a.cursors = append(a.cursors, "c1")
+		a.mu.Unlock()
+		json.NewEncoder(w).Encode(APIResponse{Followers: []Follower{}, Cursor: "c2"})
+		return
+	}
+	a.followersAPI.ServeHTTP(w, r)
+}
+
+func TestEmptyPageWithCursorIsRefetched(t *testing.T) {
+	api := &glitchyAPI{followersAPI: followersAPI{pages: [][]Follower{testFollowers("a", 1), testFollowers("b", 2), testFollowers("c", 1)}}, empty: 1}
+	store := newMemoryStore()
+	c := newTestCrawler(t, store, api)
+	logs := captureLog(t)
+
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if n, _ := store.Count(context.Background()); n != 4 {
+		t.Errorf("stored %d followers, want all 4 including the refetched page", n)
+	}
+	if want := []string{"", "c1", "c1", "c2"}; !reflect.DeepEqual(api.cursors, want) {
+		t.Errorf("requested cursors %v, want %v", api.cursors, want)
+	}
+	if !strings.Contains(logs.String(), "Anomaly: empty page with a further cursor at c1") {
+		t.Errorf("anomaly not logged:\n%s", logs)
+	}
+}
+
+func TestEmptyLastPageEndsCrawl(t *testing.T) {
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 1), {}}}
+	c := newTestCrawler(t, newMemoryStore(), api)
+	logs := captureLog(t)
+
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if api.requests() != 2 {
+		t.Errorf("mock served %d requests, want the empty terminal page fetched once", api.requests())
+	}
+	if strings.Contains(logs.String(), "Anomaly") {
+		t.Errorf("terminal empty page reported as an anomaly:\n%s", logs)
+	}
+}
+
+func TestMaxPagesStopsAfterExactlyN(t *testing.T) {
+	db := openTestDB(t)
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 1), testFollowers("b", 1), testFollowers("c", 1), testFollowers("d", 1)}}
+	c := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), api, WithLimit(2))
+	logs := captureLog(t)
+
+	stats, err := c.Run(context.Background())
+	if !errors.Is(err, errPageCap) {
+		t.Fatalf("Run = %v, want errPageCap", err)
+	}
+	if api.requests() != 2 || stats.FollowersSeen != 2 {
+		t.Errorf("fetched %d pages and saw %d followers, want 2 of each", api.requests(), stats.FollowersSeen)
+	}
+	if cursor, err := getMetadata(context.Background(), db, cursorKey("did:plc:subject")); err != nil || cursor != "c2" {
+		t.Errorf("checkpointed cursor = %q, %v; want c2", cursor, err)
+	}
+	if !strings.Contains(logs.String(), "Page cap of 2 reached") {
+		t.Errorf("page cap not logged:\n%s", logs)
+	}
+}
+
+func TestResumeSkipsAlreadySavedPage(t *testing.T) {
+	db := openTestDB(t)
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 2), testFollowers("b", 2), testFollowers("c", 2)}}
+	first := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), api, WithLimit(2))
+	if _, err := first.Run(context.Background()); !errors.Is(err, errPageCap) {
+		t.Fatalf("first Run = %v, want errPageCap", err)
+	}
+
+	// Resume one page earlier than needed, so the second page is fetched again.
+	resumed := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), api, WithCursor("c1"))
+	store := &batchRecorder{Store: resumed.store}
+	resumed.store = store
+	logs := captureLog(t)
+	if _, err := resumed.Run(context.Background()); err != nil {
+		t.Fatalf("resumed Run: %v", err)
+	}
+	if want := []int{2}; !reflect.DeepEqual(store.batches, want) {
+		t.Errorf("resumed crawl saved batches %v, want only the third page", store.batches)
+	}
+	if !strings.Contains(logs.String(), "Page at cursor c1 was already saved, skipping.") {
+		t.Errorf("skip not logged:\n%s", logs)
+	}
+	var hashes int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM page_hashes;`).Scan(&hashes); err != nil || hashes != 0 {
+		t.Errorf("page hashes after a completed crawl = %d, %v; want them cleared", hashes, err)
+	}
+}
+
+func TestPageDelayBetweenPagesOnly(t *testing.T) {
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 1), testFollowers("b", 1), testFollowers("c", 1)}}
+	failed := false
+	client, s := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The second page fails once, which is retried without the page delay.
+		if r.URL.Query().Get("cursor") == "c1" && !failed {
+			failed = true
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		api.ServeHTTP(w, r)
+	}))
+	c := &crawler{store: newMemoryStore(), client: client, actor: "did:plc:subject", pageDelay: 2 * time.Minute}
+
+	if _, err := c.crawl(context.Background(), ""); err != nil {
+		t.Fatalf("crawl: %v", err)
+	}
+	want := []time.Duration{2 * time.Minute, time.Second, 2 * time.Minute}
+	if got := s.durations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("slept %v, want %v", got, want)
+	}
+}
+
+// cancellingStore cancels the crawl once it has saved a page.
+type cancellingStore struct {
+	Store
+	cancel context.CancelFunc
+}
+
+func (s *cancellingStore) Save(ctx context.Context, followers []Follower) error {
+	defer s.cancel()
+	return s.Store.Save(ctx, followers)
+}
+
+func TestPageDelayEndsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 1), testFollowers("b", 1)}}
+	client, _ := newTestClient(t, api)
+	client.sleeper = nil
+	c := &crawler{store: &cancellingStore{Store: newMemoryStore(), cancel: cancel}, client: client, actor: "did:plc:subject", pageDelay: time.Hour}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.crawl(ctx, "")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("crawl = %v, want context.Canceled", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("crawl still waiting out the page delay after cancellation")
+	}
+	if api.requests() != 1 {
+		t.Errorf("served %d requests, want the second page never fetched", api.requests())
+	}
+}
+
+func TestMaxConsecutiveEmptyStopsOnDuplicatePages(t *testing.T) {
+	store := newMemoryStore()
+	stored := testFollowers("old", 6)
+	if err := store.Save(context.Background(), stored); err != nil {
+		t.Fatal(err)
+	}
+	api := &followersAPI{pages: [][]Follower{
+		testFollowers("new", 2),
+		stored[0:2], stored[2:4], stored[4:6],
+		testFollowers("later", 2),
+	}}
+	c := newTestCrawler(t, store, api)
+	c.maxConsecutiveEmpty = 2
+	logs := captureLog(t)
+
+	stats, err := c.Run(context.Background())
+	if !errors.Is(err, errNoProgress) {
+		t.Fatalf("Run = %v, want errNoProgress", err)
+	}
+	if stats.Status != "stopped" {
+		t.Errorf("run status = %q, want stopped", stats.Status)
+	}
+	if n := api.requests(); n != 3 {
+		t.Errorf("served %d pages, want the crawl stopped after 2 duplicate pages", n)
+	}
+	if !strings.Contains(logs.String(), "2 pages in a row brought no new followers") {
+		t.Errorf("stop reason not logged:\n%s", logs)
+	}
+	if _, ok, _ := store.Get(context.Background(), "did:plc:new0"); !ok {
+		t.Error("follower of the first page was not saved")
+	}
+}
+
+// echoingAPI serves the pages of a followersAPI but answers the last page with
+// its own cursor instead of none, as some servers do. With vary set, every
+// repeat of the last page lists a follower not listed before.
+type echoingAPI struct {
+	followersAPI
+	vary    bool
+	repeats int
+}
+
+func (a *echoingAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cursor := r.URL.Query().Get("cursor")
+	a.mu.Lock()
+	a.cursors = append(a.cursors, cursor)
+	last := fmt.Sprintf("c%d", len(a.pages)-1)
+	i := 0
+	fmt.Sscanf(cursor, "c%d", &i)
+	resp := APIResponse{Followers: a.pages[i], Cursor: fmt.Sprintf("c%d", i+1)}
+	if cursor == last {
+		resp.Cursor = last
+		if a.vary {
+			a.repeats++
+			resp.Followers = testFollowers(fmt.Sprintf("repeat%d-", a.repeats), 1)
+		}
+	}
+	a.mu.Unlock()
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestEchoedCursorEndsCrawl(t *testing.T) {
+	store := newMemoryStore()
+	api := &echoingAPI{followersAPI: followersAPI{pages: [][]Follower{testFollowers("a", 3), testFollowers("b", 2)}}}
+	c := newTestCrawler(t, store, api)
+	logs := captureLog(t)
+
+	stats, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stats.Status != "completed" || stats.FollowersSeen != 5 {
+		t.Errorf("run %s with %d followers, want completed with 5", stats.Status, stats.FollowersSeen)
+	}
+	if want := []string{"", "c1", "c1"}; !reflect.DeepEqual(api.cursors, want) {
+		t.Errorf("requested cursors %q, want %q", api.cursors, want)
+	}
+	if n, _ := store.Count(context.Background()); n != 5 {
+		t.Errorf("stored %d followers, want 5", n)
+	}
+	if !strings.Contains(logs.String(), "treating it as the last page") {
+		t.Errorf("echoed cursor not logged:\n%s", logs)
+	}
+}
+
+func TestEchoedCursorWithNewFollowersFails(t *testing.T) {
+	api := &echoingAPI{followersAPI: followersAPI{pages: [][]Follower{testFollowers("a", 3), testFollowers("b", 2)}}, vary: true}
+	c := newTestCrawler(t, newMemoryStore(), api)
+	captureLog(t)
+
+	_, err := c.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "keeps returning cursor c1") {
+		t.Fatalf("Run = %v, want the echoing cursor reported", err)
+	}
+	if n := api.requests(); n != 2+maxCursorEchoes {
+		t.Errorf("served %d pages, want %d before giving up", n, 2+maxCursorEchoes)
+	}
+}
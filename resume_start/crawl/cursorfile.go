@@ -0,0 +1,61 @@
+package crawl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readCursorFile returns the cursor stored at path, or an empty cursor if the file doesn't exist.
+func readCursorFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read cursor file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeCursorFile atomically replaces the cursor stored at path.
+func writeCursorFile(path, cursor string) error {
+	return writeFileAtomic(path, []byte(cursor+"\n"))
+}
+
+// writeFileAtomic replaces path with data by writing a temporary file in the
+// same directory and renaming it into place.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// removeCursorFile deletes the cursor file once a crawl has completed.
+func removeCursorFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove cursor file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCursorFileUpdatedPerPageAndRemoved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cursor")
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 1), testFollowers("b", 1), testFollowers("c", 1)}}
+	var seen []string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Each page is requested once the previous one is recorded.
+		data, err := os.ReadFile(path)
+		if err == nil {
+			seen = append(seen, strings.TrimSpace(string(data)))
+		}
+		api.ServeHTTP(w, r)
+	})
+
+	db := openTestDB(t)
+	c := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), h)
+	c.cursorFile = path
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if want := []string{"c1", "c2"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("cursor file held %q before each page, want %q", seen, want)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("cursor file still exists after completion: %v", err)
+	}
+}
+
+func TestReadCursorFile(t *testing.T) {
+	dir := t.TempDir()
+	if cursor, err := readCursorFile(filepath.Join(dir, "missing")); err != nil || cursor != "" {
+		t.Errorf("missing file = %q, %v; want empty", cursor, err)
+	}
+	path := filepath.Join(dir, "cursor")
+	if err := writeCursorFile(path, "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if cursor, err := readCursorFile(path); err != nil || cursor != "abc" {
+		t.Errorf("readCursorFile = %q, %v; want abc", cursor, err)
+	}
+}
@@ -0,0 +1,55 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const cursorLogTable = "cursor_log"
+
+// initializeCursorLog creates the table recording every cursor returned by the API.
+func initializeCursorLog(db *sql.DB) error {
+	ddl, err := cursorLogDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create cursor log table: %w", err)
+	}
+	return nil
+}
+
+// cursorLogDDL returns the statements creating the cursor log table.
+func cursorLogDDL() (string, error) {
+	table, err := quoteIdent(cursorLogTable)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			cursor TEXT,
+			fetched_at DATETIME,
+			follower_count INTEGER
+		);
+	`, table), nil
+}
+
+// appendCursorLog records the cursor returned for a fetched page and how many followers it held.
+func appendCursorLog(ctx context.Context, db *sql.DB, cursor string, followerCount int) error {
+	table, err := quoteIdent(cursorLogTable)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s (cursor, fetched_at, follower_count) VALUES (?, ?, ?);
+	`, table), cursor, time.Now().UTC(), followerCount)
+	if err != nil {
+		return fmt.Errorf("failed to append cursor log: %w", err)
+	}
+	return nil
+}
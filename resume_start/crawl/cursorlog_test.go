@@ -0,0 +1,43 @@
+package crawl
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCursorLogOneRowPerPage(t *testing.T) {
+	db := openTestDB(t)
+	if err := initializeCursorLog(db); err != nil {
+		t.Fatal(err)
+	}
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 2), testFollowers("b", 3), testFollowers("c", 1)}}
+	c := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), api)
+	c.cursorLog = true
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	rows, err := db.Query(`SELECT cursor, follower_count FROM cursor_log ORDER BY seq;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var cursors []string
+	var counts []int
+	for rows.Next() {
+		var cursor string
+		var n int
+		if err := rows.Scan(&cursor, &n); err != nil {
+			t.Fatal(err)
+		}
+		cursors = append(cursors, cursor)
+		counts = append(counts, n)
+	}
+	if want := []string{"c1", "c2", ""}; !reflect.DeepEqual(cursors, want) {
+		t.Errorf("logged cursors = %q, want %q", cursors, want)
+	}
+	if want := []int{2, 3, 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("logged follower counts = %v, want %v", counts, want)
+	}
+}
@@ -0,0 +1,68 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// dbTimeout bounds how long a save waits on a database another process has
+// locked, from -db-timeout. 0 leaves it to the driver's default busy timeout.
+var dbTimeout time.Duration
+
+// errDBTimeout is returned when a save gives up after dbTimeout, so lock
+// contention can be told apart from API and constraint errors.
+var errDBTimeout = errors.New("database busy")
+
+// sqliteDSN returns the data source name dbFile is opened with. With a
+// dbTimeout, transactions take the write lock as they begin, where SQLite
+// waits for it for up to dbTimeout; a deferred transaction that reads before
+// writing fails at once when another writer got there first.
+func sqliteDSN(dbFile string) string {
+	if dbTimeout <= 0 {
+		return dbFile
+	}
+	params := url.Values{}
+	params.Set("_txlock", "immediate")
+	params.Set("_busy_timeout", fmt.Sprint(dbTimeout.Milliseconds()))
+	sep := "?"
+	if strings.Contains(dbFile, "?") {
+		sep = "&"
+	}
+	return dbFile + sep + params.Encode()
+}
+
+// withDBTimeout returns the context a transaction runs in: ctx bounded by
+// dbTimeout, as a backstop for waits SQLite's busy timeout doesn't cover.
+func withDBTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if dbTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, dbTimeout)
+}
+
+// dbTimeoutError wraps err in errDBTimeout when it means the database stayed
+// locked for dbTimeout, rather than ctx being cancelled or the save failing.
+func dbTimeoutError(ctx, dbCtx context.Context, err error) error {
+	if err == nil || dbTimeout <= 0 || ctx.Err() != nil {
+		return err
+	}
+	if !isBusyDB(err) && !errors.Is(dbCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: gave up after %s: %v", errDBTimeout, dbTimeout, err)
+}
+
+// isBusyDB reports whether err is SQLite refusing a lock another connection holds.
+func isBusyDB(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
@@ -0,0 +1,72 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveTimesOutOnHeldLock(t *testing.T) {
+	saved := dbTimeout
+	dbTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { dbTimeout = saved })
+
+	path := filepath.Join(t.TempDir(), dbFile)
+	db, err := initializeDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Another process holds the write lock.
+	other, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	ctx := context.Background()
+	conn, err := other.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE;`); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	err = saveFollowers(ctx, db, []Follower{{DID: "did:plc:a", Handle: "a.test"}})
+	if !errors.Is(err, errDBTimeout) {
+		t.Fatalf("saveFollowers = %v, want errDBTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("save gave up after %v, want about %v", elapsed, dbTimeout)
+	}
+
+	if _, err := conn.ExecContext(ctx, `ROLLBACK;`); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveFollowers(ctx, db, []Follower{{DID: "did:plc:a", Handle: "a.test"}}); err != nil {
+		t.Errorf("saveFollowers after the lock was released: %v", err)
+	}
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	saved := dbTimeout
+	t.Cleanup(func() { dbTimeout = saved })
+
+	dbTimeout = 0
+	if got := sqliteDSN("followers.db"); got != "followers.db" {
+		t.Errorf("sqliteDSN without a timeout = %q, want the file unchanged", got)
+	}
+	dbTimeout = 1500 * time.Millisecond
+	if got, want := sqliteDSN("followers.db"), "followers.db?_busy_timeout=1500&_txlock=immediate"; got != want {
+		t.Errorf("sqliteDSN = %q, want %q", got, want)
+	}
+	if got, want := sqliteDSN("file:f.db?mode=rwc"), "file:f.db?mode=rwc&_busy_timeout=1500&_txlock=immediate"; got != want {
+		t.Errorf("sqliteDSN = %q, want %q", got, want)
+	}
+}
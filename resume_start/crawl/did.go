@@ -0,0 +1,114 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const invalidFollowersTable = "invalid_followers"
+
+// validateDIDs is set by -validate-did. Followers whose DID is malformed are
+// then stored in the invalid_followers table instead of the followers table.
+var validateDIDs bool
+
+var (
+	// plcDID matches did:plc identifiers: 24 characters of base32.
+	plcDID = regexp.MustCompile(`^did:plc:[a-z2-7]{24}$`)
+	// webDID matches did:web identifiers: a host name, optionally with a
+	// percent-encoded port and colon-separated path.
+	webDID = regexp.MustCompile(`^did:web:[a-zA-Z0-9.\-]+(%3[aA][0-9]+)?(:[a-zA-Z0-9._\-]+)*$`)
+)
+
+// didProblem returns why did is not a valid did:plc or did:web identifier, or
+// an empty string if it is.
+func didProblem(did string) string {
+	switch {
+	case strings.TrimSpace(did) == "":
+		return "empty DID"
+	case strings.HasPrefix(did, "did:plc:"):
+		if !plcDID.MatchString(did) {
+			return "malformed did:plc identifier"
+		}
+	case strings.HasPrefix(did, "did:web:"):
+		if !webDID.MatchString(did) {
+			return "malformed did:web identifier"
+		}
+	case strings.HasPrefix(did, "did:"):
+		return "unsupported DID method"
+	default:
+		return "not a DID"
+	}
+	return ""
+}
+
+// initializeInvalidFollowers creates the table quarantining followers with malformed DIDs.
+func initializeInvalidFollowers(db *sql.DB) error {
+	ddl, err := invalidFollowersDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create invalid followers table: %w", err)
+	}
+	return nil
+}
+
+// invalidFollowersDDL returns the statements creating the invalid followers table.
+func invalidFollowersDDL() (string, error) {
+	table, err := quoteIdent(invalidFollowersTable)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			did TEXT,
+			handle TEXT,
+			reason TEXT,
+			follower TEXT,
+			seen_at DATETIME,
+			PRIMARY KEY (did, handle)
+		);
+	`, table), nil
+}
+
+// quarantineInvalid stores the followers with a malformed DID in the invalid
+// followers table, with the reason and the follower as JSON, and returns the
+// rest.
+func quarantineInvalid(ctx context.Context, tx *sql.Tx, followers []Follower) ([]Follower, error) {
+	table, err := quoteIdent(invalidFollowersTable)
+	if err != nil {
+		return nil, err
+	}
+
+	valid := followers[:0:0]
+	invalid := 0
+	for _, follower := range followers {
+		reason := didProblem(follower.DID)
+		if reason == "" {
+			valid = append(valid, follower)
+			continue
+		}
+		data, err := json.Marshal(follower)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode follower %q: %w", follower.DID, err)
+		}
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+			INSERT OR REPLACE INTO %s (did, handle, reason, follower, seen_at) VALUES (?, ?, ?, ?, ?);
+		`, table), follower.DID, follower.Handle, reason, string(data), time.Now().UTC())
+		if err != nil {
+			return nil, fmt.Errorf("failed to quarantine follower %q: %w", follower.DID, err)
+		}
+		invalid++
+	}
+	if invalid > 0 {
+		log.Printf("Quarantined %d of %d followers with an invalid DID.\n", invalid, len(followers))
+	}
+	return valid, nil
+}
@@ -0,0 +1,54 @@
+package crawl
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDIDProblem(t *testing.T) {
+	for did, want := range map[string]string{
+		"did:plc:abcdefghijklmnopqrstuvwx":  "",
+		"did:web:example.com":               "",
+		"did:web:localhost%3A8080:u:alice":  "",
+		"":                                  "empty DID",
+		"notadid":                           "not a DID",
+		"did:plc:short":                     "malformed did:plc identifier",
+		"did:plc:ABCDEFGHIJKLMNOPQRSTUVWX":  "malformed did:plc identifier",
+		"did:web:exa mple.com":              "malformed did:web identifier",
+		"did:key:z6MkhaXgBZDvotDkL5257faiz": "unsupported DID method",
+	} {
+		if got := didProblem(did); got != want {
+			t.Errorf("didProblem(%q) = %q, want %q", did, got, want)
+		}
+	}
+}
+
+func TestValidateDIDQuarantinesMalformed(t *testing.T) {
+	db := openTestDB(t)
+	if err := initializeInvalidFollowers(db); err != nil {
+		t.Fatal(err)
+	}
+	validateDIDs = true
+	t.Cleanup(func() { validateDIDs = false })
+
+	good := Follower{DID: "did:plc:abcdefghijklmnopqrstuvwx", Handle: "good.test"}
+	bad := Follower{DID: "notadid", Handle: "bad.test"}
+	if err := saveFollowers(context.Background(), db, []Follower{good, bad}); err != nil {
+		t.Fatalf("saveFollowers: %v", err)
+	}
+
+	var stored int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM followers WHERE did = ?;`, bad.DID).Scan(&stored); err != nil || stored != 0 {
+		t.Errorf("followers holds %d rows of %s, %v; want it quarantined", stored, bad.DID, err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM followers WHERE did = ?;`, good.DID).Scan(&stored); err != nil || stored != 1 {
+		t.Errorf("followers holds %d rows of %s, %v; want it saved", stored, good.DID, err)
+	}
+	var handle, reason string
+	if err := db.QueryRow(`SELECT handle, reason FROM invalid_followers WHERE did = ?;`, bad.DID).Scan(&handle, &reason); err != nil {
+		t.Fatalf("quarantined follower: %v", err)
+	}
+	if handle != bad.Handle || reason != "not a DID" {
+		t.Errorf("quarantined %q with reason %q, want %q and \"not a DID\"", handle, reason, bad.Handle)
+	}
+}
@@ -0,0 +1,296 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+const (
+	profilesBatchCap = 25 // getProfiles accepts at most 25 actors per call
+
+	// enrichFailuresTable records followers the enrich pass could not fetch a
+	// profile for, telling them apart from followers not enriched yet.
+	enrichFailuresTable = "enrich_failures"
+)
+
+// reasonNotReturned is recorded for actors getProfiles silently leaves out of
+// its response, which it does for deactivated, deleted and taken down accounts.
+const reasonNotReturned = "profile not returned by getProfiles"
+
+// enrichColumns are the columns populated by the enrich pass.
+var enrichColumns = map[string]string{
+	"followsCount":   "INTEGER",
+	"followersCount": "INTEGER",
+	"postsCount":     "INTEGER",
+	"pinnedPost":     "TEXT",
+	"enriched_at":    "DATETIME",
+}
+
+// Profile represents the detailed profile view returned by getProfile and getProfiles.
+type Profile struct {
+	DID            string     `json:"did"`
+	Handle         string     `json:"handle"`
+	FollowsCount   int        `json:"followsCount"`
+	FollowersCount int        `json:"followersCount"`
+	PostsCount     int        `json:"postsCount"`
+	PinnedPost     *StrongRef `json:"pinnedPost"`
+}
+
+// StrongRef is a reference to a specific version of a record.
+type StrongRef struct {
+	URI string `json:"uri"`
+	CID string `json:"cid"`
+}
+
+// ProfilesResponse represents the full structure of the getProfiles response.
+type ProfilesResponse struct {
+	Profiles []Profile `json:"profiles"`
+}
+
+// batchDIDs splits dids into consecutive batches of at most size elements.
+func batchDIDs(dids []string, size int) [][]string {
+	var batches [][]string
+	for start := 0; start < len(dids); start += size {
+		end := start + size
+		if end > len(dids) {
+			end = len(dids)
+		}
+		batches = append(batches, dids[start:end])
+	}
+	return batches
+}
+
+// enrichFollowers fetches full profiles for stored followers in batches and
+// updates their rows with the richer fields, pausing between batches to stay
+// within rate limits. Followers enriched less than ttl ago are skipped; a zero
+// ttl enriches everyone.
+func enrichFollowers(ctx context.Context, db *sql.DB, client *apiClient, delay, ttl time.Duration) error {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return err
+	}
+	if err := ensureColumns(db, tableName, enrichColumns); err != nil {
+		return err
+	}
+	if err := initializeEnrichFailures(db); err != nil {
+		return err
+	}
+
+	var staleBefore time.Time
+	if ttl > 0 {
+		staleBefore = time.Now().UTC().Add(-ttl)
+	}
+	dids, err := loadDIDs(db, staleBefore)
+	if err != nil {
+		return err
+	}
+
+	if len(dids) == 0 {
+		log.Println("No followers need enrichment.")
+		return nil
+	}
+	batches := batchDIDs(dids, profilesBatchCap)
+	log.Printf("Enriching %d followers in %d batches.\n", len(dids), len(batches))
+
+	for i, batch := range batches {
+		if i > 0 {
+			if err := sleepContext(ctx, delay); err != nil {
+				return err
+			}
+		}
+
+		profiles, failures, err := fetchBatchProfiles(ctx, client, batch)
+		if err != nil {
+			return fmt.Errorf("failed to fetch profiles for batch %d: %w", i+1, err)
+		}
+		if err := saveProfiles(ctx, db, table, profiles, failures); err != nil {
+			return fmt.Errorf("failed to save profiles for batch %d: %w", i+1, err)
+		}
+		if len(failures) > 0 {
+			log.Printf("Enriched batch %d/%d with %d profiles, %d failed.\n", i+1, len(batches), len(profiles), len(failures))
+			continue
+		}
+		log.Printf("Enriched batch %d/%d with %d profiles.\n", i+1, len(batches), len(profiles))
+	}
+
+	return nil
+}
+
+// loadDIDs returns the DIDs of stored followers, all of them when staleBefore is
+// zero, otherwise only those never enriched, enriched before staleBefore or
+// enriched before follow counts were stored.
+func loadDIDs(db *sql.DB, staleBefore time.Time) ([]string, error) {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT did FROM %s ORDER BY did;`, table)
+	var args []interface{}
+	if !staleBefore.IsZero() {
+		query = fmt.Sprintf(`SELECT did FROM %s WHERE enriched_at IS NULL OR enriched_at < ? OR followsCount IS NULL ORDER BY did;`, table)
+		args = append(args, staleBefore)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DIDs: %w", err)
+	}
+	defer rows.Close()
+
+	var dids []string
+	for rows.Next() {
+		var did string
+		if err := rows.Scan(&did); err != nil {
+			return nil, fmt.Errorf("failed to scan DID: %w", err)
+		}
+		dids = append(dids, did)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate DIDs: %w", err)
+	}
+	return dids, nil
+}
+
+// fetchProfiles calls getProfiles for a batch of at most profilesBatchCap actors.
+func fetchProfiles(ctx context.Context, client *apiClient, dids []string) ([]Profile, error) {
+	if len(dids) > profilesBatchCap {
+		return nil, fmt.Errorf("batch of %d actors exceeds getProfiles cap of %d", len(dids), profilesBatchCap)
+	}
+
+	query := url.Values{}
+	for _, did := range dids {
+		query.Add("actors", did)
+	}
+	reqURL := client.xrpcURL("app.bsky.actor.getProfiles", query)
+
+	var profilesResp ProfilesResponse
+	if err := client.getJSON(ctx, reqURL, &profilesResp); err != nil {
+		return nil, err
+	}
+
+	return profilesResp.Profiles, nil
+}
+
+// fetchBatchProfiles fetches the profiles of a batch, returning the reason
+// each actor without one failed, keyed by DID. When the batch request fails
+// its actors are fetched one at a time, so one bad actor doesn't fail the
+// rest. It only returns an error when ctx is done.
+func fetchBatchProfiles(ctx context.Context, client *apiClient, dids []string) ([]Profile, map[string]string, error) {
+	failures := make(map[string]string)
+	profiles, err := fetchProfiles(ctx, client, dids)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, err
+		}
+		log.Printf("Failed to fetch profiles for %d actors, fetching them one at a time: %v", len(dids), err)
+		profiles = nil
+		for _, did := range dids {
+			single, err := fetchProfiles(ctx, client, []string{did})
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			if err != nil {
+				failures[did] = err.Error()
+				continue
+			}
+			profiles = append(profiles, single...)
+		}
+	}
+
+	returned := make(map[string]bool, len(profiles))
+	for _, profile := range profiles {
+		returned[profile.DID] = true
+	}
+	for _, did := range dids {
+		if _, failed := failures[did]; !failed && !returned[did] {
+			failures[did] = reasonNotReturned
+		}
+	}
+	return profiles, failures, nil
+}
+
+// saveProfiles updates follower rows with the enriched profile fields and
+// records the failures in a single transaction. Followers enriched now have
+// their earlier failures cleared.
+func saveProfiles(ctx context.Context, db *sql.DB, table string, profiles []Profile, failures map[string]string) error {
+	failuresTable, err := quoteIdent(enrichFailuresTable)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+		UPDATE %s SET followsCount = ?, followersCount = ?, postsCount = ?, pinnedPost = ?, enriched_at = ? WHERE did = ?;
+	`, table))
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	enrichedAt := time.Now().UTC()
+	for _, profile := range profiles {
+		var pinnedPost sql.NullString
+		if profile.PinnedPost != nil {
+			pinnedPost = sql.NullString{String: profile.PinnedPost.URI, Valid: true}
+		}
+
+		if _, err := stmt.Exec(profile.FollowsCount, profile.FollowersCount, profile.PostsCount, pinnedPost, enrichedAt, profile.DID); err != nil {
+			return fmt.Errorf("failed to update profile %s: %w", profile.DID, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE did = ?;`, failuresTable), profile.DID); err != nil {
+			return fmt.Errorf("failed to clear enrich failure of %s: %w", profile.DID, err)
+		}
+		if err := recordIdentities(ctx, tx, []identity{{did: profile.DID, handle: profile.Handle}}, enrichedAt); err != nil {
+			return err
+		}
+	}
+
+	for did, reason := range failures {
+		if _, err := tx.Exec(fmt.Sprintf(`INSERT OR REPLACE INTO %s (did, reason, attempted_at) VALUES (?, ?, ?);`, failuresTable),
+			did, reason, enrichedAt); err != nil {
+			return fmt.Errorf("failed to record enrich failure of %s: %w", did, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// initializeEnrichFailures creates the table of followers enrichment failed for.
+func initializeEnrichFailures(db *sql.DB) error {
+	ddl, err := enrichFailuresDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create enrich failures table: %w", err)
+	}
+	return nil
+}
+
+// enrichFailuresDDL returns the statements creating the enrich failures table.
+func enrichFailuresDDL() (string, error) {
+	table, err := quoteIdent(enrichFailuresTable)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			did TEXT PRIMARY KEY,
+			reason TEXT,
+			attempted_at DATETIME
+		);
+	`, table), nil
+}
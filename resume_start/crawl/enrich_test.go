@@ -0,0 +1,223 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatchDIDs(t *testing.T) {
+	dids := func(n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = fmt.Sprintf("did:plc:%d", i)
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		n     int
+		sizes []int
+	}{
+		{0, nil},
+		{1, []int{1}},
+		{25, []int{25}},
+		{26, []int{25, 1}},
+		{51, []int{25, 25, 1}},
+	} {
+		in := dids(tc.n)
+		batches := batchDIDs(in, profilesBatchCap)
+		var sizes []int
+		var joined []string
+		for _, b := range batches {
+			sizes = append(sizes, len(b))
+			joined = append(joined, b...)
+		}
+		if !reflect.DeepEqual(sizes, tc.sizes) {
+			t.Errorf("batchDIDs(%d) sizes = %v, want %v", tc.n, sizes, tc.sizes)
+		}
+		if tc.n > 0 && !reflect.DeepEqual(joined, in) {
+			t.Errorf("batchDIDs(%d) reordered or lost DIDs", tc.n)
+		}
+	}
+}
+
+// profilesServer is a mock getProfiles endpoint answering with a profile for
+// every requested actor except those in missing. It records the actors of
+// each call.
+type profilesServer struct {
+	mu      sync.Mutex
+	calls   [][]string
+	missing map[string]bool
+}
+
+func (s *profilesServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	actors := r.URL.Query()["actors"]
+	s.mu.Lock()
+	s.calls = append(s.calls, actors)
+	s.mu.Unlock()
+
+	var resp ProfilesResponse
+	for _, did := range actors {
+		if s.missing[did] {
+			continue
+		}
+		resp.Profiles = append(resp.Profiles, Profile{
+			DID: did, Handle: did[len("did:plc:"):] + ".test",
+			FollowsCount: 1, FollowersCount: len(did), PostsCount: 7,
+			PinnedPost: &StrongRef{URI: "at://" + did + "/app.bsky.feed.post/1"},
+		})
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestEnrichFollowersBatches(t *testing.T) {
+	db := openTestDB(t)
+	var followers []Follower
+	for i := 0; i < 30; i++ {
+		followers = append(followers, Follower{DID: fmt.Sprintf("did:plc:f%02d", i), Handle: fmt.Sprintf("f%02d.test", i)})
+	}
+	seedFollowers(t, db, followers...)
+
+	srv := &profilesServer{}
+	client, _ := newTestClient(t, srv)
+	if err := enrichFollowers(context.Background(), db, client, 0, 0); err != nil {
+		t.Fatalf("enrichFollowers: %v", err)
+	}
+
+	if len(srv.calls) != 2 || len(srv.calls[0]) != 25 || len(srv.calls[1]) != 5 {
+		t.Fatalf("getProfiles calls = %d, want batches of 25 and 5", len(srv.calls))
+	}
+	var followersCount, postsCount int
+	var pinned string
+	if err := db.QueryRow(`SELECT followersCount, postsCount, pinnedPost FROM followers WHERE did = 'did:plc:f29';`).
+		Scan(&followersCount, &postsCount, &pinned); err != nil {
+		t.Fatalf("reading enriched row: %v", err)
+	}
+	if followersCount != len("did:plc:f29") || postsCount != 7 || pinned != "at://did:plc:f29/app.bsky.feed.post/1" {
+		t.Errorf("enriched row = %d, %d, %q", followersCount, postsCount, pinned)
+	}
+}
+
+func TestEnrichSkipsFollowersWithinTTL(t *testing.T) {
+	db := openTestDB(t)
+	seedFollowers(t, db, testFollowers("a", 3)...)
+	srv := &profilesServer{}
+	client, _ := newTestClient(t, srv)
+	ctx := context.Background()
+
+	if err := enrichFollowers(ctx, db, client, 0, time.Hour); err != nil {
+		t.Fatalf("first enrich: %v", err)
+	}
+	if len(srv.calls) != 1 {
+		t.Fatalf("first enrich made %d getProfiles calls, want 1", len(srv.calls))
+	}
+	if err := enrichFollowers(ctx, db, client, 0, time.Hour); err != nil {
+		t.Fatalf("second enrich: %v", err)
+	}
+	if len(srv.calls) != 1 {
+		t.Errorf("second enrich within the TTL made %d more getProfiles calls, want none", len(srv.calls)-1)
+	}
+
+	// -enrich runs after a crawl, which saves the same followers again.
+	seedFollowers(t, db, testFollowers("a", 3)...)
+	if err := enrichFollowers(ctx, db, client, 0, time.Hour); err != nil {
+		t.Fatalf("enrich after a crawl: %v", err)
+	}
+	if len(srv.calls) != 1 {
+		t.Errorf("enrich after a crawl within the TTL made %d more getProfiles calls, want none", len(srv.calls)-1)
+	}
+
+	// A follower saved since then is enriched on its own.
+	seedFollowers(t, db, Follower{DID: "did:plc:late", Handle: "late.test"})
+	if err := enrichFollowers(ctx, db, client, 0, time.Hour); err != nil {
+		t.Fatalf("enrich of a new follower: %v", err)
+	}
+	if len(srv.calls) != 2 || !reflect.DeepEqual(srv.calls[1], []string{"did:plc:late"}) {
+		t.Errorf("getProfiles calls = %v, want only the new follower fetched", srv.calls)
+	}
+}
+
+func TestEnrichRecordsFailures(t *testing.T) {
+	db := openTestDB(t)
+	seedFollowers(t, db, Follower{DID: "did:plc:ok", Handle: "ok.test"}, Follower{DID: "did:plc:bad", Handle: "bad.test"}, Follower{DID: "did:plc:gone", Handle: "gone.test"})
+	profiles := &profilesServer{missing: map[string]bool{"did:plc:gone": true}}
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, did := range r.URL.Query()["actors"] {
+			if did == "did:plc:bad" {
+				http.Error(w, `{"error":"InvalidRequest","message":"Profile not found"}`, http.StatusBadRequest)
+				return
+			}
+		}
+		profiles.ServeHTTP(w, r)
+	}))
+
+	if err := enrichFollowers(context.Background(), db, client, 0, 0); err != nil {
+		t.Fatalf("enrichFollowers: %v", err)
+	}
+	report, err := enrichFailuresReport(db)
+	if err != nil {
+		t.Fatalf("enrichFailuresReport: %v", err)
+	}
+	reasons := make(map[string]string)
+	for _, row := range report.rows {
+		reasons[row[0]] = row[1]
+	}
+	if len(reasons) != 2 || reasons["did:plc:gone"] != reasonNotReturned || !strings.Contains(reasons["did:plc:bad"], "Profile not found") {
+		t.Errorf("recorded failures %v, want did:plc:bad with the API error and did:plc:gone not returned", reasons)
+	}
+	var enriched int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM followers WHERE enriched_at IS NOT NULL;`).Scan(&enriched); err != nil || enriched != 1 {
+		t.Errorf("enriched %d followers, %v; want only did:plc:ok", enriched, err)
+	}
+
+	// A later successful enrichment clears the failure.
+	profiles.missing = nil
+	if err := enrichFollowers(context.Background(), db, client, 0, 0); err != nil {
+		t.Fatalf("second enrichFollowers: %v", err)
+	}
+	if report, _ := enrichFailuresReport(db); len(report.rows) != 1 || report.rows[0][0] != "did:plc:bad" {
+		t.Errorf("failures after the second pass = %v, want only did:plc:bad", report.rows)
+	}
+}
+
+func TestRecrawlKeepsEnrichedColumns(t *testing.T) {
+	db := openTestDB(t)
+	seedFollowers(t, db, Follower{DID: "did:plc:a", Handle: "a.test", DisplayName: "Before"})
+	client, _ := newTestClient(t, &profilesServer{})
+	captureLog(t)
+	if err := enrichFollowers(context.Background(), db, client, 0, 0); err != nil {
+		t.Fatalf("enrichFollowers: %v", err)
+	}
+	var firstSeen time.Time
+	if err := db.QueryRow(`SELECT first_seen FROM followers WHERE did = 'did:plc:a';`).Scan(&firstSeen); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next crawl saves the follower again.
+	seedFollowers(t, db, Follower{DID: "did:plc:a", Handle: "renamed.test", DisplayName: "After"})
+
+	var handle, displayName string
+	var followersCount, postsCount sql.NullInt64
+	var enrichedAt sql.NullTime
+	var stillFirstSeen time.Time
+	if err := db.QueryRow(`SELECT handle, displayName, followersCount, postsCount, enriched_at, first_seen FROM followers WHERE did = 'did:plc:a';`).
+		Scan(&handle, &displayName, &followersCount, &postsCount, &enrichedAt, &stillFirstSeen); err != nil {
+		t.Fatal(err)
+	}
+	if handle != "renamed.test" || displayName != "After" {
+		t.Errorf("crawled columns = %q, %q; want the new values", handle, displayName)
+	}
+	if followersCount.Int64 != int64(len("did:plc:a")) || postsCount.Int64 != 7 || !enrichedAt.Valid {
+		t.Errorf("enriched columns = %+v, %+v, %+v; want them kept", followersCount, postsCount, enrichedAt)
+	}
+	if !stillFirstSeen.Equal(firstSeen) {
+		t.Errorf("first_seen = %v, want it kept at %v", stillFirstSeen, firstSeen)
+	}
+}
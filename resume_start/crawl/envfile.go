@@ -0,0 +1,105 @@
+package crawl
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Settings come from three places, each overriding the one before:
+//
+//  1. a .env file of KEY=VALUE lines, read from the working directory or from
+//     the file named by BLUESKY_ENV_FILE;
+//  2. the process environment, so a variable that is already set is never
+//     replaced by the .env file;
+//  3. command-line flags.
+//
+// The variables in envFlags set their flag's value; others in the .env file,
+// such as HTTPS_PROXY, are only exported to the environment.
+
+// defaultEnvFile is read when BLUESKY_ENV_FILE is not set. It is optional.
+const defaultEnvFile = ".env"
+
+// envFlags maps environment variables to the flags they set.
+var envFlags = map[string]string{
+	"BLUESKY_ACTOR":       "actor",
+	"BLUESKY_SERVICE_URL": "service-url",
+	"BLUESKY_AUTH_TOKEN":  "auth-token",
+}
+
+// loadEnvFile exports the variables of the .env file at path that are not set
+// in the environment yet, and returns how many it exported. A missing file is
+// not an error.
+func loadEnvFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open env file: %w", err)
+	}
+	defer f.Close()
+
+	vars, err := parseEnv(f.Name(), bufio.NewScanner(f))
+	if err != nil {
+		return 0, err
+	}
+	loaded := 0
+	for _, kv := range vars {
+		if _, ok := os.LookupEnv(kv[0]); ok {
+			continue
+		}
+		if err := os.Setenv(kv[0], kv[1]); err != nil {
+			return loaded, fmt.Errorf("failed to set %s: %w", kv[0], err)
+		}
+		loaded++
+	}
+	return loaded, nil
+}
+
+// parseEnv reads KEY=VALUE lines, skipping blank lines and # comments. A line
+// may start with "export ", and a value may be wrapped in single or double
+// quotes, which are removed. There is no escaping or variable expansion.
+func parseEnv(name string, sc *bufio.Scanner) ([][2]string, error) {
+	var vars [][2]string
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", name, n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars = append(vars, [2]string{key, value})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return vars, nil
+}
+
+// applyEnvFlags sets the flags of fs whose variable in envFlags is set, ahead
+// of parsing the command line so flags still take precedence.
+func applyEnvFlags(fs *flag.FlagSet) error {
+	for env, name := range envFlags {
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", env, err)
+		}
+	}
+	return nil
+}
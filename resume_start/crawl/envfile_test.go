@@ -0,0 +1,76 @@
+package crawl
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// unsetEnv unsets keys for the test, restoring them when it ends.
+func unsetEnv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func TestEnvFilePrecedence(t *testing.T) {
+	unsetEnv(t, "BLUESKY_SERVICE_URL", "BLUESKY_AUTH_TOKEN", "BLUESKY_TEST_PROXY")
+	t.Setenv("BLUESKY_ACTOR", "from-env.test")
+	path := filepath.Join(t.TempDir(), ".env")
+	data := `# local settings
+
+export BLUESKY_SERVICE_URL="https://appview.example"
+BLUESKY_ACTOR=from-file.test
+BLUESKY_AUTH_TOKEN='file-token'
+BLUESKY_TEST_PROXY = http://proxy.example:3128
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := loadEnvFile(path)
+	if err != nil {
+		t.Fatalf("loadEnvFile: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("loaded %d variables, want 3 as BLUESKY_ACTOR is already set", n)
+	}
+	if got := os.Getenv("BLUESKY_TEST_PROXY"); got != "http://proxy.example:3128" {
+		t.Errorf("BLUESKY_TEST_PROXY = %q, want it exported", got)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	actor := fs.String("actor", defaultActor, "")
+	serviceURL := fs.String("service-url", defaultServiceURL, "")
+	authToken := fs.String("auth-token", "", "")
+	if err := applyEnvFlags(fs); err != nil {
+		t.Fatalf("applyEnvFlags: %v", err)
+	}
+	if err := fs.Parse([]string{"-auth-token", "flag-token"}); err != nil {
+		t.Fatal(err)
+	}
+	// .env < environment < flags.
+	if *serviceURL != "https://appview.example" {
+		t.Errorf("service URL = %q, want the .env value", *serviceURL)
+	}
+	if *actor != "from-env.test" {
+		t.Errorf("actor = %q, want the environment over the .env file", *actor)
+	}
+	if *authToken != "flag-token" {
+		t.Errorf("auth token = %q, want the flag over the .env file", *authToken)
+	}
+}
+
+func TestEnvFileMissingOrMalformed(t *testing.T) {
+	if n, err := loadEnvFile(filepath.Join(t.TempDir(), ".env")); n != 0 || err != nil {
+		t.Errorf("loadEnvFile of a missing file = %d, %v; want 0 and no error", n, err)
+	}
+	path := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(path, []byte("BLUESKY_ACTOR\n"), 0o600)
+	if _, err := loadEnvFile(path); err == nil {
+		t.Error("loadEnvFile accepted a line without =")
+	}
+}
@@ -0,0 +1,145 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of Event.
+const (
+	eventNewFollower  = "new_follower"
+	eventUnfollow     = "unfollow"
+	eventHandleChange = "handle_change"
+)
+
+// eventTypes maps each kind of followerChange to the type of its event.
+var eventTypes = map[string]string{
+	changeNew:    eventNewFollower,
+	changeLost:   eventUnfollow,
+	changeHandle: eventHandleChange,
+}
+
+// Event is a change to the followers found by a run, as published to -emit-events.
+type Event struct {
+	Type     string         `json:"type"`
+	Actor    string         `json:"actor"`
+	Follower followerChange `json:"follower"`
+	TS       time.Time      `json:"ts"`
+}
+
+// Publisher sends the events of a run somewhere, such as a message queue.
+type Publisher interface {
+	Publish(ctx context.Context, events []Event) error
+}
+
+// changeEvents returns the events of a run's changes, all stamped with at.
+func changeEvents(actor string, changes []followerChange, at time.Time) []Event {
+	events := make([]Event, 0, len(changes))
+	for _, change := range changes {
+		events = append(events, Event{Type: eventTypes[change.Kind], Actor: actor, Follower: change, TS: at.UTC()})
+	}
+	return events
+}
+
+// encodeEvents returns events as NDJSON, one event per line.
+func encodeEvents(events []Event) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return nil, fmt.Errorf("failed to encode event: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// ndjsonPublisher appends events as NDJSON to w.
+type ndjsonPublisher struct {
+	mu sync.Mutex // keeps the lines of concurrent runs apart
+	w  io.Writer
+}
+
+func (p *ndjsonPublisher) Publish(_ context.Context, events []Event) error {
+	data, err := encodeEvents(events)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.w.Write(data); err != nil {
+		return fmt.Errorf("failed to write events: %w", err)
+	}
+	return nil
+}
+
+// httpPublisher POSTs each run's events to url as one NDJSON body.
+type httpPublisher struct {
+	client *http.Client
+	url    string
+}
+
+func (p *httpPublisher) Publish(ctx context.Context, events []Event) error {
+	data, err := encodeEvents(events)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to build events request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post events: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("events sink returned %s", resp.Status)
+	}
+	return nil
+}
+
+// newPublisher returns the publisher for an -emit-events sink: an http or
+// https URL is posted to, and anything else is a file appended to. Stdout is
+// refused, as the run summary and -only-new handles are printed there. The
+// returned function closes the sink.
+func newPublisher(sink string, client *http.Client) (Publisher, func() error, error) {
+	switch {
+	case strings.HasPrefix(sink, "http://") || strings.HasPrefix(sink, "https://"):
+		return &httpPublisher{client: client, url: sink}, func() error { return nil }, nil
+	case sink == "-":
+		return nil, nil, errors.New("events can't be written to stdout, which carries the run summary; use a file or URL")
+	}
+
+	file, err := os.OpenFile(sink, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open events file: %w", err)
+	}
+	return &ndjsonPublisher{w: file}, file.Close, nil
+}
+
+// memoryPublisher keeps published events, for embedding programs and tests.
+type memoryPublisher struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (p *memoryPublisher) Publish(_ context.Context, events []Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, events...)
+	return nil
+}
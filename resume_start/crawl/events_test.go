@@ -0,0 +1,124 @@
+package crawl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// eventSummaries returns the type, DID and handles of each event.
+func eventSummaries(events []Event) []string {
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Type+" "+e.Actor+" "+e.Follower.DID+" "+e.Follower.OldHandle+" "+e.Follower.Handle)
+	}
+	return got
+}
+
+func TestPublisherReceivesRunDelta(t *testing.T) {
+	publisher := &memoryPublisher{}
+	api := &followersAPI{pages: [][]Follower{{{DID: "did:plc:a", Handle: "a.test"}, {DID: "did:plc:b", Handle: "b.test"}}}}
+	c := newTestCrawler(t, newSQLiteStore(openTestDB(t), tableName, followerColumns), api, WithPublisher(publisher))
+	captureLog(t)
+	ctx := context.Background()
+
+	if _, err := c.Run(ctx); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if len(publisher.events) != 0 {
+		t.Fatalf("first run published %v, want nothing", eventSummaries(publisher.events))
+	}
+
+	// a is renamed, b leaves and c arrives.
+	api.mu.Lock()
+	api.pages = [][]Follower{{{DID: "did:plc:a", Handle: "renamed.test"}, {DID: "did:plc:c", Handle: "c.test"}}}
+	api.mu.Unlock()
+	before := time.Now().UTC()
+	if _, err := c.Run(ctx); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	want := []string{
+		"new_follower did:plc:subject did:plc:c  c.test",
+		"unfollow did:plc:subject did:plc:b  b.test",
+		"handle_change did:plc:subject did:plc:a a.test renamed.test",
+	}
+	if got := eventSummaries(publisher.events); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+	for _, e := range publisher.events {
+		if e.TS.Before(before.Add(-time.Second)) || e.TS.Location() != time.UTC {
+			t.Errorf("event %s stamped %v, want the time of the run in UTC", e.Type, e.TS)
+		}
+	}
+}
+
+func TestPublisherSkipsChangesOfEarlierRuns(t *testing.T) {
+	publisher := &memoryPublisher{}
+	db := openTestDB(t)
+	captureLog(t)
+	a := Follower{DID: "did:plc:a", Handle: "a.test"}
+	b := Follower{DID: "did:plc:b", Handle: "b.test"}
+
+	// b leaves in the second run and stays away; each run is a new process.
+	for i, followers := range [][]Follower{{a, b}, {a}, {a}, {a}} {
+		api := &followersAPI{pages: [][]Follower{followers}}
+		c := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), api, WithPublisher(publisher))
+		if _, err := c.Run(context.Background()); err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+	}
+	want := []string{"unfollow did:plc:subject did:plc:b  b.test"}
+	if got := eventSummaries(publisher.events); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestFilePublisherAppendsNDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	events := changeEvents("did:plc:subject", []followerChange{
+		{Kind: changeNew, DID: "did:plc:c", Handle: "c.test"},
+		{Kind: changeLost, DID: "did:plc:b", Handle: "b.test"},
+	}, time.Now())
+	for i := 0; i < 2; i++ {
+		publisher, closePublisher, err := newPublisher(path, http.DefaultClient)
+		if err != nil {
+			t.Fatalf("newPublisher: %v", err)
+		}
+		if err := publisher.Publish(context.Background(), events[i:i+1]); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		if err := closePublisher(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var got []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, e)
+	}
+	if want := eventSummaries(events); !reflect.DeepEqual(eventSummaries(got), want) {
+		t.Errorf("file holds %q, want %q", eventSummaries(got), want)
+	}
+}
+
+func TestPublisherRefusesStdout(t *testing.T) {
+	if _, _, err := newPublisher("-", http.DefaultClient); err == nil {
+		t.Error("newPublisher accepted stdout, where the run summary is printed")
+	}
+}
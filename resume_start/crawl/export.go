@@ -0,0 +1,277 @@
+package crawl
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// exportOptions adjusts what exported followers contain.
+type exportOptions struct {
+	redact            bool // blank avatar and description
+	redactDisplayName bool // also blank displayName
+
+	// since, when set, limits the export to followers first or last seen after it.
+	since time.Time
+
+	// streamThreshold is the number of followers above which the export
+	// streams them in storage order rather than sorting them, which would
+	// have the database materialize the whole result first. Below it they are
+	// exported in fetch order.
+	streamThreshold int
+	streaming       bool // set by runExport when the threshold is exceeded
+}
+
+// apply returns follower with the redacted fields blanked. DID and handle are always kept.
+func (o exportOptions) apply(follower Follower) Follower {
+	if o.redact {
+		follower.Avatar = ""
+		follower.Description = ""
+	}
+	if o.redactDisplayName {
+		follower.DisplayName = ""
+	}
+	return follower
+}
+
+// runExport writes the stored followers in the given format to outPath, or to
+// stdout when outPath is empty.
+func runExport(db *sql.DB, format, outPath string, opts exportOptions) error {
+	var out io.Writer = os.Stdout
+	if outPath != "" {
+		file, err := os.Create(outPath)
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	n, err := countExported(db, opts.since)
+	if err != nil {
+		return err
+	}
+	if n > opts.streamThreshold {
+		opts.streaming = true
+		log.Printf("Exporting %d followers, more than the stream threshold of %d: streaming them in storage order instead of sorting them by fetch order.\n", n, opts.streamThreshold)
+	}
+
+	w := bufio.NewWriter(out)
+	if err := exportFollowers(db, format, w, opts); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush export: %w", err)
+	}
+
+	log.Printf("Exported followers as %s to %s\n", format, exportTarget(outPath))
+	return nil
+}
+
+// exportTarget describes where an export is written for logging.
+func exportTarget(outPath string) string {
+	if outPath == "" {
+		return "stdout"
+	}
+	return outPath
+}
+
+// exportFollowers streams every stored follower to w in the requested format.
+func exportFollowers(db *sql.DB, format string, w io.Writer, opts exportOptions) error {
+	switch format {
+	case "json":
+		return exportJSON(db, w, opts)
+	case "csv":
+		return exportCSV(db, w, opts)
+	case "parquet":
+		return exportParquet(db, w, opts)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// exportJSON writes followers as a single JSON array, encoding one element at a
+// time so the full result set is never held in memory.
+func exportJSON(db *sql.DB, w io.Writer, opts exportOptions) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+
+	enc := json.NewEncoder(w)
+	first := true
+	err := scanFollowers(db, opts, func(follower Follower) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return fmt.Errorf("failed to write export: %w", err)
+			}
+		}
+		first = false
+		return enc.Encode(opts.apply(follower))
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, "]\n"); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
+
+// csvHeader is the header row of a CSV export.
+var csvHeader = []string{
+	"did", "handle", "displayName", "avatar", "description", "createdAt", "indexedAt",
+	"viewer_muted", "viewer_blockedBy", "viewer_following", "labels",
+}
+
+// exportCSV writes followers as CSV with a header row, one follower at a time.
+// Times are RFC 3339 and labels a JSON array.
+func exportCSV(db *sql.DB, w io.Writer, opts exportOptions) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	err := scanFollowers(db, opts, func(follower Follower) error {
+		f := opts.apply(follower)
+		return cw.Write([]string{
+			f.DID, f.Handle, f.DisplayName, f.Avatar, f.Description,
+			csvTime(f.CreatedAt.Time), csvTime(f.IndexedAt.Time),
+			strconv.FormatBool(f.Viewer.Muted), strconv.FormatBool(f.Viewer.BlockedBy), f.Viewer.Following,
+			jsonLabels{}.Serialize(f.Labels),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
+
+// csvTime formats t for CSV, leaving unknown times empty.
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseSince parses the -since time, either RFC 3339 or a UTC date.
+func parseSince(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -since %q, expected RFC 3339 or YYYY-MM-DD", value)
+	}
+	return t, nil
+}
+
+// sinceFilter returns the WHERE clause and arguments selecting followers first
+// or last seen after since, or nothing when since is zero.
+func sinceFilter(since time.Time) (string, []interface{}) {
+	if since.IsZero() {
+		return "", nil
+	}
+	// Times are stored in UTC, so comparing them as text orders them.
+	return `WHERE f.first_seen > ? OR f.last_seen > ?`, []interface{}{since.UTC(), since.UTC()}
+}
+
+// countExported returns the number of followers an export with since writes.
+func countExported(db *sql.DB, since time.Time) (int, error) {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return 0, err
+	}
+	where, args := sinceFilter(since)
+	var n int
+	if err := db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %s f %s;`, table, where), args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count followers: %w", err)
+	}
+	return n, nil
+}
+
+// scanFollowers reconstructs each stored follower and passes it to fn, stopping
+// at the first error. A non-zero opts.since skips followers neither first nor
+// last seen after it; followers stored before those times were recorded have
+// neither and are skipped too. Followers come in fetch order, or in storage
+// order when opts.streaming is set, which SQLite reads without sorting.
+func scanFollowers(db *sql.DB, opts exportOptions, fn func(Follower) error) error {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return err
+	}
+	labelTable, err := quoteIdent(labelsTable)
+	if err != nil {
+		return err
+	}
+
+	where, args := sinceFilter(opts.since)
+	order := "f.fetch_seq, f.did"
+	if opts.streaming {
+		order = "f.rowid"
+	}
+
+	// Rows saved with -label-format table have an empty labels column; their
+	// labels are read back from the normalized table as JSON instead.
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT f.did, f.handle, f.displayName, f.avatar, f.viewer_muted, f.viewer_blockedBy, f.viewer_following,
+			COALESCE(NULLIF(f.labels, ''), (SELECT json_group_array(json_object('type', l.type, 'value', l.value)) FROM %s l WHERE l.did = f.did), ''),
+			f.createdAt, f.description, f.indexedAt
+		FROM %s f %s ORDER BY %s;
+	`, labelTable, table, where, order), args...)
+	if err != nil {
+		return fmt.Errorf("failed to query followers: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var follower Follower
+		var labels string
+		var displayName, avatar, description sql.NullString
+		if err := rows.Scan(
+			&follower.DID,
+			&follower.Handle,
+			&displayName,
+			&avatar,
+			&follower.Viewer.Muted,
+			&follower.Viewer.BlockedBy,
+			&follower.Viewer.Following,
+			&labels,
+			&follower.CreatedAt.Time,
+			&description,
+			&follower.IndexedAt.Time,
+		); err != nil {
+			return fmt.Errorf("failed to scan follower: %w", err)
+		}
+		// Fields the API left out are stored as NULL and exported empty.
+		follower.DisplayName = follower.setOptional(fieldDisplayName, displayName)
+		follower.Avatar = resolveAvatar(follower.setOptional(fieldAvatar, avatar))
+		follower.Description = follower.setOptional(fieldDescription, description)
+		if follower.Labels, err = decodeLabels(labels); err != nil {
+			return fmt.Errorf("failed to read labels of %s: %w", follower.DID, err)
+		}
+		if len(follower.Labels) == 0 {
+			follower.Labels = nil
+		}
+
+		if err := fn(follower); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate followers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package crawl
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestExportParquetReadsBack(t *testing.T) {
+	db := openTestDB(t)
+	created := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+	seedFollowers(t, db,
+		Follower{DID: "did:plc:a", Handle: "a.test", DisplayName: "Alice", CreatedAt: bskyTime{created}, Labels: []Label{{Type: "t", Value: "spam"}}},
+		Follower{DID: "did:plc:b", Handle: "b.test"},
+		Follower{DID: "did:plc:c", Handle: "c.test"},
+	)
+
+	var buf bytes.Buffer
+	if err := exportFollowers(db, "parquet", &buf, exportOptions{}); err != nil {
+		t.Fatalf("exportFollowers: %v", err)
+	}
+	file, err := buffer.NewBufferFile(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetReader(file, new(parquetFollower), 1)
+	if err != nil {
+		t.Fatalf("export is not a Parquet file: %v", err)
+	}
+	defer pr.ReadStop()
+	if n := pr.GetNumRows(); n != 3 {
+		t.Fatalf("Parquet file has %d rows, want 3", n)
+	}
+	rows := make([]parquetFollower, 3)
+	if err := pr.Read(&rows); err != nil {
+		t.Fatalf("reading rows: %v", err)
+	}
+
+	var alice *parquetFollower
+	for i := range rows {
+		if rows[i].DID == "did:plc:a" {
+			alice = &rows[i]
+		}
+	}
+	if alice == nil {
+		t.Fatalf("did:plc:a not exported: %+v", rows)
+	}
+	if alice.Handle != "a.test" || alice.DisplayName != "Alice" || alice.Labels != `[{"type":"t","value":"spam"}]` {
+		t.Errorf("exported row = %+v", alice)
+	}
+	if alice.CreatedAt == nil || *alice.CreatedAt != created.UnixMilli() {
+		t.Errorf("createdAt = %v, want %d", alice.CreatedAt, created.UnixMilli())
+	}
+	if alice.IndexedAt != nil {
+		t.Errorf("unknown indexedAt = %d, want null", *alice.IndexedAt)
+	}
+}
@@ -0,0 +1,81 @@
+package crawl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+// heapWriter discards what is written to it, sampling the live heap every
+// sampleEvery writes to record its peak.
+type heapWriter struct {
+	writes, sampleEvery int
+	peak                uint64
+	bytes               int64
+}
+
+func (w *heapWriter) Write(p []byte) (int, error) {
+	w.writes++
+	w.bytes += int64(len(p))
+	if w.writes%w.sampleEvery == 0 {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		if m.HeapAlloc > w.peak {
+			w.peak = m.HeapAlloc
+		}
+	}
+	return len(p), nil
+}
+
+func TestLargeExportStreams(t *testing.T) {
+	db := openTestDB(t)
+	const n = 10000
+	description := strings.Repeat("x", 2048)
+	batch := make([]Follower, 0, 1000)
+	for i := 0; i < n; i++ {
+		batch = append(batch, Follower{DID: fmt.Sprintf("did:plc:%05d", i), Handle: fmt.Sprintf("h%05d.test", i), Description: description})
+		if len(batch) == cap(batch) {
+			seedFollowers(t, db, batch...)
+			batch = batch[:0]
+		}
+	}
+	logs := captureLog(t)
+
+	// The threshold switches the export to streaming and says so.
+	path := filepath.Join(t.TempDir(), "followers.csv")
+	if err := runExport(db, "csv", path, exportOptions{streamThreshold: n - 1}); err != nil {
+		t.Fatalf("runExport: %v", err)
+	}
+	if !strings.Contains(logs.String(), fmt.Sprintf("Exporting %d followers, more than the stream threshold of %d: streaming", n, n-1)) {
+		t.Errorf("streaming not logged:\n%s", logs)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != n+1 {
+		t.Errorf("exported %d lines, want a header and %d rows", lines, n)
+	}
+
+	// The whole set is over 20 MB; streaming it never holds more than a
+	// fraction of that.
+	defer debug.SetGCPercent(debug.SetGCPercent(10))
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	w := &heapWriter{sampleEvery: 100}
+	if err := exportFollowers(db, "json", w, exportOptions{streaming: true}); err != nil {
+		t.Fatalf("exportFollowers: %v", err)
+	}
+	if w.bytes < n*int64(len(description)) {
+		t.Fatalf("exported %d bytes, want every description", w.bytes)
+	}
+	const bound = 8 << 20
+	if w.peak > before.HeapAlloc+bound {
+		t.Errorf("heap peaked %d MB above its size before the export, want under %d MB", (w.peak-before.HeapAlloc)>>20, bound>>20)
+	}
+}
@@ -0,0 +1,130 @@
+package crawl
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportJSONIsOneDocument(t *testing.T) {
+	db := openTestDB(t)
+	seedFollowers(t, db,
+		Follower{DID: "did:plc:a", Handle: "a.test", DisplayName: "A", Labels: []Label{{Type: "t", Value: "spam"}}},
+		Follower{DID: "did:plc:b", Handle: "b.test", Description: "hello, \"world\""},
+	)
+
+	var buf bytes.Buffer
+	if err := exportFollowers(db, "json", &buf, exportOptions{}); err != nil {
+		t.Fatalf("exportFollowers: %v", err)
+	}
+	var got []Follower
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("export is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if len(got) != 2 {
+		t.Fatalf("exported %d followers, want 2", len(got))
+	}
+	if got[0].DID != "did:plc:a" || got[0].DisplayName != "A" || len(got[0].Labels) != 1 || got[0].Labels[0].Value != "spam" {
+		t.Errorf("first follower = %+v", got[0])
+	}
+	if got[1].Description != "hello, \"world\"" {
+		t.Errorf("second description = %q", got[1].Description)
+	}
+}
+
+func TestExportJSONEmpty(t *testing.T) {
+	db := openTestDB(t)
+	var buf bytes.Buffer
+	if err := exportFollowers(db, "json", &buf, exportOptions{}); err != nil {
+		t.Fatalf("exportFollowers: %v", err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Fatalf("empty export = %q, want []", got)
+	}
+	var got []Follower
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || len(got) != 0 {
+		t.Fatalf("json.Unmarshal = %v, %d followers", err, len(got))
+	}
+}
+
+func TestExportRedactsProfileFields(t *testing.T) {
+	db := openTestDB(t)
+	seedFollowers(t, db, Follower{DID: "did:plc:a", Handle: "a.test", DisplayName: "Alice", Avatar: "https://cdn.test/a.jpg", Description: "lives in Lisbon"})
+
+	var buf bytes.Buffer
+	if err := exportFollowers(db, "json", &buf, exportOptions{redact: true}); err != nil {
+		t.Fatalf("exportFollowers: %v", err)
+	}
+	var got []Follower
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || len(got) != 1 {
+		t.Fatalf("json.Unmarshal = %v, %d followers", err, len(got))
+	}
+	if f := got[0]; f.DID != "did:plc:a" || f.Handle != "a.test" || f.DisplayName != "Alice" || f.Avatar != "" || f.Description != "" {
+		t.Errorf("redacted JSON follower = %+v, want avatar and description blank", f)
+	}
+
+	buf.Reset()
+	if err := exportFollowers(db, "csv", &buf, exportOptions{redact: true, redactDisplayName: true}); err != nil {
+		t.Fatalf("exportFollowers: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("csv = %v, %v", records, err)
+	}
+	row := make(map[string]string)
+	for i, name := range records[0] {
+		row[name] = records[1][i]
+	}
+	if row["did"] != "did:plc:a" || row["handle"] != "a.test" {
+		t.Errorf("redacted CSV row lost its identity: %v", row)
+	}
+	for _, name := range []string{"displayName", "avatar", "description"} {
+		if v, ok := row[name]; !ok || v != "" {
+			t.Errorf("redacted CSV %s = %q (present %v), want an empty column", name, v, ok)
+		}
+	}
+}
+
+func TestExportCSVSince(t *testing.T) {
+	db := openTestDB(t)
+	seedFollowers(t, db,
+		Follower{DID: "did:plc:old", Handle: "old.test"},
+		Follower{DID: "did:plc:seen", Handle: "seen.test"},
+		Follower{DID: "did:plc:new", Handle: "new.test"},
+	)
+	cutoff := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	for did, seen := range map[string][2]time.Time{
+		"did:plc:old":  {cutoff.AddDate(0, -2, 0), cutoff.AddDate(0, -1, 0)},
+		"did:plc:seen": {cutoff.AddDate(0, -2, 0), cutoff.AddDate(0, 0, 3)},
+		"did:plc:new":  {cutoff.AddDate(0, 0, 1), cutoff.AddDate(0, 0, 1)},
+	} {
+		if _, err := db.Exec(`UPDATE followers SET first_seen = ?, last_seen = ? WHERE did = ?;`, seen[0], seen[1], did); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := exportFollowers(db, "csv", &buf, exportOptions{since: cutoff}); err != nil {
+		t.Fatalf("exportFollowers: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if len(records) == 0 || !reflect.DeepEqual(records[0], csvHeader) {
+		t.Fatalf("export lacks the header row: %q", records)
+	}
+	var dids []string
+	for _, record := range records[1:] {
+		dids = append(dids, record[0])
+	}
+	sort.Strings(dids)
+	if want := []string{"did:plc:new", "did:plc:seen"}; !reflect.DeepEqual(dids, want) {
+		t.Errorf("exported %v, want %v", dids, want)
+	}
+}
@@ -0,0 +1,130 @@
+package crawl
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+)
+
+// followerFilter decides which fetched followers are saved. Filters run on each
+// page before it is written.
+type followerFilter struct {
+	name string
+	keep func(Follower) bool
+}
+
+// applyFilters returns the followers that pass every filter, logging how many
+// each filter removed.
+func applyFilters(filters []followerFilter, followers []Follower) []Follower {
+	for _, filter := range filters {
+		kept := followers[:0:0]
+		for _, follower := range followers {
+			if filter.keep(follower) {
+				kept = append(kept, follower)
+			}
+		}
+		if removed := len(followers) - len(kept); removed > 0 {
+			log.Printf("Filter %s removed %d of %d followers.\n", filter.name, removed, len(followers))
+		}
+		followers = kept
+	}
+	return followers
+}
+
+// minAccountAgeFilter drops accounts created less than minAge ago. Accounts
+// without a creation time are kept.
+func minAccountAgeFilter(minAge time.Duration) followerFilter {
+	return followerFilter{
+		name: fmt.Sprintf("min-account-age(%v)", minAge),
+		keep: func(f Follower) bool {
+			return f.CreatedAt.IsZero() || time.Since(f.CreatedAt.Time) >= minAge
+		},
+	}
+}
+
+// handleSuffixFilter keeps followers whose handle ends in one of suffixes, or,
+// with exclude, those whose handle ends in none of them. Matching ignores case.
+func handleSuffixFilter(suffixes []string, exclude bool) followerFilter {
+	name := "include-handle-suffix"
+	if exclude {
+		name = "exclude-handle-suffix"
+	}
+	return followerFilter{
+		name: fmt.Sprintf("%s(%s)", name, strings.Join(suffixes, ",")),
+		keep: func(f Follower) bool {
+			handle := strings.ToLower(f.Handle)
+			for _, suffix := range suffixes {
+				if strings.HasSuffix(handle, strings.ToLower(suffix)) {
+					return !exclude
+				}
+			}
+			return exclude
+		},
+	}
+}
+
+// splitSuffixes parses a comma-separated suffix list, dropping blanks.
+func splitSuffixes(list string) []string {
+	var suffixes []string
+	for _, suffix := range strings.Split(list, ",") {
+		if suffix = strings.TrimSpace(suffix); suffix != "" {
+			suffixes = append(suffixes, suffix)
+		}
+	}
+	return suffixes
+}
+
+// accountListFilter keeps followers whose DID or handle is in accounts, or,
+// with exclude, those in neither. Handles match ignoring case.
+func accountListFilter(path string, accounts map[string]bool, exclude bool) followerFilter {
+	name := "allowlist"
+	if exclude {
+		name = "denylist"
+	}
+	return followerFilter{
+		name: fmt.Sprintf("%s(%s)", name, path),
+		keep: func(f Follower) bool {
+			listed := accounts[f.DID] || (f.Handle != "" && accounts[strings.ToLower(f.Handle)])
+			return listed != exclude
+		},
+	}
+}
+
+// readAccountList reads a file of DIDs and handles, one per line. Blank lines
+// and lines starting with # are skipped, as is anything after a # on a line.
+// Handles are lowercased and may carry a leading @.
+func readAccountList(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account list: %w", err)
+	}
+	accounts := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		entry := strings.TrimPrefix(strings.TrimSpace(line), "@")
+		if entry == "" {
+			continue
+		}
+		if !strings.HasPrefix(entry, "did:") {
+			entry = strings.ToLower(entry)
+		}
+		accounts[entry] = true
+	}
+	return accounts, nil
+}
+
+// sampleFilter keeps each follower with probability rate, drawing from rng so a
+// fixed seed selects the same sample from the same pages.
+func sampleFilter(rate float64, rng *rand.Rand) followerFilter {
+	return followerFilter{
+		name: fmt.Sprintf("sample(%g)", rate),
+		keep: func(Follower) bool {
+			return rng.Float64() < rate
+		},
+	}
+}
@@ -0,0 +1,155 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMinAccountAgeExcludesNewAccounts(t *testing.T) {
+	now := time.Now().UTC()
+	api := &followersAPI{pages: [][]Follower{{
+		{DID: "did:plc:old", Handle: "old.test", CreatedAt: bskyTime{now.Add(-365 * 24 * time.Hour)}},
+		{DID: "did:plc:new", Handle: "new.test", CreatedAt: bskyTime{now.Add(-time.Hour)}},
+		{DID: "did:plc:unknown", Handle: "unknown.test"},
+	}}}
+	store := newMemoryStore()
+	c := newTestCrawler(t, store, api)
+	c.filters = []followerFilter{minAccountAgeFilter(7 * 24 * time.Hour)}
+	logs := captureLog(t)
+
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	ctx := context.Background()
+	if _, ok, _ := store.Get(ctx, "did:plc:new"); ok {
+		t.Error("follower created an hour ago was saved")
+	}
+	for _, did := range []string{"did:plc:old", "did:plc:unknown"} {
+		if _, ok, _ := store.Get(ctx, did); !ok {
+			t.Errorf("follower %s was filtered out", did)
+		}
+	}
+	if !strings.Contains(logs.String(), "removed 1 of 3 followers") {
+		t.Errorf("filtered count not logged:\n%s", logs)
+	}
+}
+
+func TestExcludeHandleSuffixKeepsCustomDomains(t *testing.T) {
+	api := &followersAPI{pages: [][]Follower{{
+		{DID: "did:plc:a", Handle: "alice.bsky.social"},
+		{DID: "did:plc:b", Handle: "bob.example.com"},
+		{DID: "did:plc:c", Handle: "Carol.BSKY.Social"},
+		{DID: "did:plc:d", Handle: "dave.dev"},
+	}}}
+	store := newMemoryStore()
+	c := newTestCrawler(t, store, api)
+	c.filters = []followerFilter{handleSuffixFilter(splitSuffixes(" .bsky.social, "), true)}
+	logs := captureLog(t)
+
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	ctx := context.Background()
+	if n, _ := store.Count(ctx); n != 2 {
+		t.Errorf("stored %d followers, want the 2 custom-domain ones", n)
+	}
+	for _, did := range []string{"did:plc:b", "did:plc:d"} {
+		if _, ok, _ := store.Get(ctx, did); !ok {
+			t.Errorf("custom-domain follower %s was filtered out", did)
+		}
+	}
+	if !strings.Contains(logs.String(), "exclude-handle-suffix(.bsky.social) removed 2 of 4 followers") {
+		t.Errorf("filtered count not logged:\n%s", logs)
+	}
+}
+
+func TestIncludeHandleSuffix(t *testing.T) {
+	filter := handleSuffixFilter([]string{".bsky.social"}, false)
+	kept := applyFilters([]followerFilter{filter}, []Follower{{Handle: "alice.bsky.social"}, {Handle: "bob.example.com"}})
+	if len(kept) != 1 || kept[0].Handle != "alice.bsky.social" {
+		t.Errorf("kept %v, want only alice.bsky.social", kept)
+	}
+}
+
+// writeAccountList writes lines to a list file and returns its path.
+func writeAccountList(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "accounts.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDenylistedFollowerNotSaved(t *testing.T) {
+	path := writeAccountList(t, "# spam accounts", "", "@Spammer.Test  # reported twice", "did:plc:bot")
+	accounts, err := readAccountList(path)
+	if err != nil {
+		t.Fatalf("readAccountList: %v", err)
+	}
+	api := &followersAPI{pages: [][]Follower{{
+		{DID: "did:plc:a", Handle: "alice.test"},
+		{DID: "did:plc:s", Handle: "spammer.test"},
+		{DID: "did:plc:bot", Handle: "renamed-bot.test"},
+	}}}
+	store := newMemoryStore()
+	c := newTestCrawler(t, store, api)
+	c.filters = []followerFilter{accountListFilter(path, accounts, true)}
+
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	ctx := context.Background()
+	if n, _ := store.Count(ctx); n != 1 {
+		t.Errorf("stored %d followers, want only alice", n)
+	}
+	for _, did := range []string{"did:plc:s", "did:plc:bot"} {
+		if _, ok, _ := store.Get(ctx, did); ok {
+			t.Errorf("denylisted follower %s was saved", did)
+		}
+	}
+}
+
+func TestDenylistTakesPrecedenceOverAllowlist(t *testing.T) {
+	accounts := map[string]bool{"did:plc:a": true, "b.test": true}
+	filters := []followerFilter{
+		accountListFilter("deny", map[string]bool{"b.test": true}, true),
+		accountListFilter("allow", accounts, false),
+	}
+	kept := applyFilters(filters, []Follower{{DID: "did:plc:a", Handle: "a.test"}, {DID: "did:plc:b", Handle: "B.test"}, {DID: "did:plc:c", Handle: "c.test"}})
+	if len(kept) != 1 || kept[0].DID != "did:plc:a" {
+		t.Errorf("kept %v, want only did:plc:a", kept)
+	}
+}
+
+func TestSampleRateKeepsFraction(t *testing.T) {
+	sample := func(seed int64) map[string]Follower {
+		var pages [][]Follower
+		for i := 0; i < 20; i++ {
+			pages = append(pages, testFollowers(fmt.Sprintf("p%d-", i), 100))
+		}
+		store := newMemoryStore()
+		c := newTestCrawler(t, store, &followersAPI{pages: pages})
+		c.filters = []followerFilter{sampleFilter(0.1, rand.New(rand.NewSource(seed)))}
+		if _, err := c.Run(context.Background()); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		return store.followers
+	}
+
+	first := sample(7)
+	// 10% of 2000 has a standard deviation of about 13.
+	if n := len(first); n < 150 || n > 250 {
+		t.Errorf("sampled %d of 2000 followers, want about 200", n)
+	}
+	if second := sample(7); !reflect.DeepEqual(first, second) {
+		t.Errorf("seed 7 sampled %d, then %d different followers; want the same sample", len(first), len(second))
+	}
+}
@@ -0,0 +1,133 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// followingTable holds the accounts the actor follows, as of the last
+// -mode following run.
+const followingTable = "following"
+
+// FollowsResponse represents the structure of the getFollows response.
+type FollowsResponse struct {
+	Follows []Follower `json:"follows"`
+	Cursor  string     `json:"cursor"`
+}
+
+// initializeFollowing creates the table of accounts the actor follows.
+func initializeFollowing(db *sql.DB) error {
+	ddl, err := followingDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create following table: %w", err)
+	}
+	return nil
+}
+
+// followingDDL returns the statements creating the following table.
+func followingDDL() (string, error) {
+	table, err := quoteIdent(followingTable)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			did TEXT PRIMARY KEY,
+			handle TEXT,
+			displayName TEXT,
+			observed_at DATETIME
+		);
+	`, table), nil
+}
+
+// runFollowing crawls every account the actor follows and replaces the
+// following table with them, so it always holds one complete list.
+func runFollowing(ctx context.Context, db *sql.DB, client *apiClient, actor string) error {
+	if err := initializeFollowing(db); err != nil {
+		return err
+	}
+
+	var follows []Follower
+	cursor := ""
+	for {
+		page, err := fetchFollows(ctx, client, actor, cursor)
+		if err != nil {
+			return err
+		}
+		follows = append(follows, page.Follows...)
+		log.Printf("Fetched %d follows, %d so far.\n", len(page.Follows), len(follows))
+		if page.Cursor == "" {
+			break
+		}
+		cursor = page.Cursor
+	}
+
+	observedAt := time.Now().UTC()
+	if err := saveFollowing(ctx, db, follows, observedAt); err != nil {
+		return err
+	}
+	log.Printf("Saved %d accounts followed by %s.\n", len(follows), actor)
+	return nil
+}
+
+// fetchFollows requests one page of the accounts the actor follows.
+func fetchFollows(ctx context.Context, client *apiClient, actor, cursor string) (FollowsResponse, error) {
+	query := url.Values{"actor": {actor}, "limit": {strconv.Itoa(pageLimit)}}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	var followsResp FollowsResponse
+	if err := client.getJSON(ctx, client.xrpcURL("app.bsky.graph.getFollows", query), &followsResp); err != nil {
+		return FollowsResponse{}, fmt.Errorf("%w for cursor %s", err, cursor)
+	}
+	return followsResp, nil
+}
+
+// saveFollowing replaces the stored follows with follows in a single transaction.
+func saveFollowing(ctx context.Context, db *sql.DB, follows []Follower, observedAt time.Time) error {
+	table, err := quoteIdent(followingTable)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s;`, table)); err != nil {
+		return fmt.Errorf("failed to clear following: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`
+		INSERT OR REPLACE INTO %s (did, handle, displayName, observed_at) VALUES (?, ?, ?, ?);
+	`, table))
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, f := range follows {
+		if _, err := stmt.ExecContext(ctx, f.DID, normalizeHandle(f.Handle), f.DisplayName, observedAt); err != nil {
+			return fmt.Errorf("failed to save follow %s: %w", f.DID, err)
+		}
+	}
+	if err := recordIdentities(ctx, tx, followerIdentities(follows), observedAt); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package crawl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// health tracks the runs of a long-lived crawl for -health-addr. A nil health
+// records nothing, so the crawl loop can report to it unconditionally.
+type health struct {
+	interval time.Duration
+	now      func() time.Time
+
+	mu          sync.Mutex
+	since       time.Time // when monitoring started, until the first run finishes
+	running     bool
+	lastRun     Stats
+	lastSuccess time.Time
+}
+
+// newHealth returns the health of a crawl repeated every interval.
+func newHealth(interval time.Duration) *health {
+	return &health{interval: interval, now: time.Now, since: time.Now()}
+}
+
+// runStarted records that a run is in progress.
+func (h *health) runStarted() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.running = true
+}
+
+// runFinished records the outcome of a run.
+func (h *health) runFinished(stats Stats) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.running = false
+	h.lastRun = stats
+	if runSucceeded(stats.Status) {
+		h.lastSuccess = stats.FinishedAt
+	}
+}
+
+// runSucceeded reports whether a run with status ended as intended, including
+// when it stopped early at a configured limit.
+func runSucceeded(status string) bool {
+	switch status {
+	case "completed", "capped", "stopped", "skipped":
+		return true
+	}
+	return false
+}
+
+// healthReport is the body of the health endpoints.
+type healthReport struct {
+	State         string `json:"state"` // running or idle
+	Healthy       bool   `json:"healthy"`
+	Reason        string `json:"reason,omitempty"`
+	LastStatus    string `json:"last_status,omitempty"`
+	LastRunAt     string `json:"last_run_at,omitempty"`
+	LastSuccessAt string `json:"last_success_at,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+}
+
+// report returns the current state. The crawl is unhealthy when its last run
+// failed, or when no run has finished for two intervals, which leaves a whole
+// interval for the run itself.
+func (h *health) report() healthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := healthReport{State: "idle", Healthy: true, LastStatus: h.lastRun.Status}
+	if h.running {
+		r.State = "running"
+	}
+	last := h.since
+	if !h.lastRun.FinishedAt.IsZero() {
+		last = h.lastRun.FinishedAt
+		r.LastRunAt = last.UTC().Format(time.RFC3339)
+	}
+	if !h.lastSuccess.IsZero() {
+		r.LastSuccessAt = h.lastSuccess.UTC().Format(time.RFC3339)
+	}
+	if h.lastRun.Err != nil {
+		r.LastError = h.lastRun.Err.Error()
+	}
+
+	switch {
+	case h.lastRun.Status != "" && !runSucceeded(h.lastRun.Status):
+		r.Healthy, r.Reason = false, "last run "+h.lastRun.Status
+	case h.interval > 0 && h.now().Sub(last) > 2*h.interval:
+		r.Healthy, r.Reason = false, fmt.Sprintf("no run finished since %s", last.UTC().Format(time.RFC3339))
+	}
+	return r
+}
+
+// handler serves /healthz, which fails while the crawl is unhealthy, and
+// /readyz, which also fails until a run has succeeded.
+func (h *health) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		report := h.report()
+		writeHealth(w, report, report.Healthy)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		report := h.report()
+		if report.Healthy && report.LastSuccessAt == "" {
+			report.Reason = "no run has succeeded yet"
+		}
+		writeHealth(w, report, report.Healthy && report.LastSuccessAt != "")
+	})
+	return mux
+}
+
+// writeHealth writes report as JSON, with 503 Service Unavailable unless ok.
+func writeHealth(w http.ResponseWriter, report healthReport, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// serveHealth serves the health endpoints on addr until ctx is done. It only
+// returns an error when addr can't be listened on.
+func serveHealth(ctx context.Context, addr string, h *health) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	srv := &http.Server{Handler: h.handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Health server failed: %v", err)
+		}
+	}()
+	log.Printf("Serving health checks on http://%s/healthz and /readyz.\n", ln.Addr())
+	return nil
+}
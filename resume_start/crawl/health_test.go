@@ -0,0 +1,106 @@
+package crawl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// getHealth fetches path from srv and returns its status code and report.
+func getHealth(t *testing.T, srv *httptest.Server, path string) (int, healthReport) {
+	t.Helper()
+	resp, err := srv.Client().Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var report healthReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("decoding %s: %v", path, err)
+	}
+	return resp.StatusCode, report
+}
+
+func TestHealthAfterRun(t *testing.T) {
+	h := newHealth(time.Minute)
+	srv := httptest.NewServer(h.handler())
+	t.Cleanup(srv.Close)
+
+	if code, _ := getHealth(t, srv, "/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz before the first run = %d, want 200", code)
+	}
+	if code, report := getHealth(t, srv, "/readyz"); code != http.StatusServiceUnavailable || report.Reason != "no run has succeeded yet" {
+		t.Errorf("/readyz before the first run = %d %+v, want 503", code, report)
+	}
+
+	c := newTestCrawler(t, newSQLiteStore(openTestDB(t), tableName, followerColumns), &followersAPI{pages: [][]Follower{testFollowers("a", 2)}})
+	h.runStarted()
+	if _, report := getHealth(t, srv, "/healthz"); report.State != "running" {
+		t.Errorf("state during the run = %q, want running", report.State)
+	}
+	stats, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	h.runFinished(stats)
+	for _, path := range []string{"/healthz", "/readyz"} {
+		code, report := getHealth(t, srv, path)
+		if code != http.StatusOK || report.State != "idle" || report.LastStatus != "completed" || report.LastSuccessAt == "" {
+			t.Errorf("%s after a completed run = %d %+v, want 200", path, code, report)
+		}
+	}
+
+	// Two intervals without a run are overdue.
+	h.now = func() time.Time { return stats.FinishedAt.Add(3 * time.Minute) }
+	if code, report := getHealth(t, srv, "/healthz"); code != http.StatusServiceUnavailable || report.Healthy {
+		t.Errorf("/healthz of an overdue run = %d %+v, want 503", code, report)
+	}
+	h.now = time.Now
+
+	h.runFinished(Stats{Status: "failed", FinishedAt: time.Now(), Err: errors.New("boom")})
+	code, report := getHealth(t, srv, "/healthz")
+	if code != http.StatusServiceUnavailable || report.Reason != "last run failed" || report.LastError != "boom" || report.LastSuccessAt == "" {
+		t.Errorf("/healthz after a failed run = %d %+v, want 503 keeping the last success", code, report)
+	}
+}
+
+func TestServeHealthStopsWithContext(t *testing.T) {
+	logs := captureLog(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := serveHealth(ctx, "127.0.0.1:0", newHealth(0)); err != nil {
+		t.Fatalf("serveHealth: %v", err)
+	}
+	m := regexp.MustCompile(`http://(\S+)/healthz`).FindStringSubmatch(logs.String())
+	if m == nil {
+		t.Fatalf("address not logged:\n%s", logs)
+	}
+	url := "http://" + m[1] + "/healthz"
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET /healthz: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/healthz = %d, want 200", resp.StatusCode)
+	}
+
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(url)
+		if err != nil {
+			break
+		}
+		resp.Body.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("health server still serving after the context ended")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,294 @@
+package crawl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpCache is a transport that keeps the validators and bodies of GET
+// responses in dir, so requests made by later processes are sent as
+// conditional GETs and a 304 Not Modified is answered from disk. It lets
+// frequent short runs skip downloading pages that haven't changed.
+type httpCache struct {
+	base     http.RoundTripper
+	dir      string
+	maxBytes int64 // 0 keeps everything
+	// maxBodySize is the largest body read into memory to be cached, the
+	// client's -max-body-size; 0 has no limit.
+	maxBodySize int64
+
+	mu sync.Mutex // serializes stores and eviction
+}
+
+// cacheEntry is the metadata stored next to a cached body.
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	ContentType  string    `json:"content_type,omitempty"`
+	Size         int64     `json:"size"`
+	StoredAt     time.Time `json:"stored_at"`
+}
+
+// newHTTPCache returns a cache in dir in front of base, evicting the least
+// recently used entries once they take up more than maxBytes. Bodies over
+// maxBodySize are passed through uncached.
+func newHTTPCache(dir string, maxBytes, maxBodySize int64, base http.RoundTripper) (*httpCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create HTTP cache directory: %w", err)
+	}
+	return &httpCache{base: base, dir: dir, maxBytes: maxBytes, maxBodySize: maxBodySize}, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *httpCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return c.base.RoundTrip(req)
+	}
+
+	key := cacheKey(req)
+	entry, err := c.load(key)
+	if err != nil {
+		log.Printf("Warning: ignoring HTTP cache entry for %s: %v\n", req.URL, err)
+	}
+	if entry == nil {
+		return c.fetch(req, key)
+	}
+
+	conditional := req.Clone(req.Context())
+	if entry.ETag != "" {
+		conditional.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		conditional.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+	resp, err := c.base.RoundTrip(conditional)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		resp.Body.Close()
+		body, err := c.body(key, entry)
+		if err != nil {
+			// The entry went missing since it was loaded; ask for the body again.
+			log.Printf("Warning: HTTP cache body for %s unreadable, refetching: %v\n", req.URL, err)
+			return c.fetch(req, key)
+		}
+		return cachedResponse(req, resp, entry, body), nil
+	case http.StatusOK:
+		return c.store(req, resp, key)
+	}
+	return resp, nil
+}
+
+// fetch sends req unconditionally and stores the response.
+func (c *httpCache) fetch(req *http.Request, key string) (*http.Response, error) {
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	return c.store(req, resp, key)
+}
+
+// store saves a 200 response carrying an ETag or Last-Modified and returns it
+// with its body still readable. Responses without validators, or larger than
+// the whole cache or maxBodySize, pass through untouched, so the client's own
+// limit rejects an oversized body before it is buffered. A failure to save is logged, not
+// returned, since the response itself is fine.
+func (c *httpCache) store(req *http.Request, resp *http.Response, key string) (*http.Response, error) {
+	entry := &cacheEntry{
+		URL:          req.URL.String(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  resp.Header.Get("Content-Type"),
+		StoredAt:     time.Now().UTC(),
+	}
+	if entry.ETag == "" && entry.LastModified == "" {
+		return resp, nil
+	}
+
+	limit := int64(-1)
+	for _, max := range []int64{c.maxBytes, c.maxBodySize} {
+		if max > 0 && (limit < 0 || max < limit) {
+			limit = max
+		}
+	}
+	var body []byte
+	var err error
+	if limit < 0 {
+		body, err = io.ReadAll(resp.Body)
+	} else {
+		body, err = io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	}
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if limit >= 0 && int64(len(body)) > limit {
+		// Too large to cache: hand back what was read followed by the rest.
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry.Size = int64(len(body))
+	if err := c.save(key, entry, body); err != nil {
+		log.Printf("Warning: failed to cache response for %s: %v\n", req.URL, err)
+	}
+	return resp, nil
+}
+
+// cachedResponse turns the 304 answering req into a 200 carrying the cached body.
+func cachedResponse(req *http.Request, notModified *http.Response, entry *cacheEntry, body []byte) *http.Response {
+	header := notModified.Header.Clone()
+	if entry.ContentType != "" && header.Get("Content-Type") == "" {
+		header.Set("Content-Type", entry.ContentType)
+	}
+	resp := *notModified
+	resp.Status = "200 OK"
+	resp.StatusCode = http.StatusOK
+	resp.Header = header
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Request = req
+	return &resp
+}
+
+// cacheKey names the cache files of req. The Authorization header is part of
+// the key so responses fetched with one token are never served to another.
+func cacheKey(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.URL.String() + "\n" + req.Header.Get("Authorization")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *httpCache) metaPath(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *httpCache) bodyPath(key string) string {
+	return filepath.Join(c.dir, key+".body")
+}
+
+// load returns the entry stored under key, or nil if there is none.
+func (c *httpCache) load(key string) (*cacheEntry, error) {
+	data, err := os.ReadFile(c.metaPath(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse cache entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// body reads the cached body of entry and marks it as recently used.
+func (c *httpCache) body(key string, entry *cacheEntry) ([]byte, error) {
+	path := c.bodyPath(key)
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached body: %w", err)
+	}
+	if int64(len(body)) != entry.Size {
+		return nil, fmt.Errorf("cached body is %d bytes, expected %d", len(body), entry.Size)
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return body, nil
+}
+
+// save writes the body before the metadata, so an entry is only found once
+// it is complete, then evicts down to the size limit.
+func (c *httpCache) save(key string, entry *cacheEntry, body []byte) error {
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := writeFileAtomic(c.bodyPath(key), body); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.metaPath(key), meta); err != nil {
+		return err
+	}
+	return c.evict()
+}
+
+// evict removes the least recently used entries until the cache fits in
+// maxBytes. Entries are ordered by the modification time of their body,
+// which is refreshed each time a 304 is answered from it.
+func (c *httpCache) evict() error {
+	if c.maxBytes <= 0 {
+		return nil
+	}
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list HTTP cache: %w", err)
+	}
+
+	type cached struct {
+		key  string
+		size int64
+		used time.Time
+	}
+	var entries []cached
+	var total int64
+	for _, file := range files {
+		key, ok := strings.CutSuffix(file.Name(), ".body")
+		if !ok {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		size := info.Size()
+		if meta, err := os.Stat(c.metaPath(key)); err == nil {
+			size += meta.Size()
+		}
+		entries = append(entries, cached{key: key, size: size, used: info.ModTime()})
+		total += size
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].used.Before(entries[j].used) })
+
+	for _, e := range entries {
+		if total <= c.maxBytes {
+			break
+		}
+		// The metadata goes first so a half-removed entry is never loaded.
+		if err := os.Remove(c.metaPath(e.key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to evict HTTP cache entry: %w", err)
+		}
+		if err := os.Remove(c.bodyPath(e.key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to evict HTTP cache entry: %w", err)
+		}
+		total -= e.size
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// etagAPI serves body with an ETag, answering a matching If-None-Match with a
+// 304, and counts both.
+type etagAPI struct {
+	body              string
+	full, notModified atomic.Int32
+}
+
+func (a *etagAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("ETag", `"v1"`)
+	if r.Header.Get("If-None-Match") == `"v1"` {
+		a.notModified.Add(1)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	a.full.Add(1)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, a.body)
+}
+
+// cachedClient returns a client like client whose requests go through a new
+// cache in dir, as a new process using -http-cache-dir would.
+func cachedClient(t *testing.T, client *apiClient, dir string) *apiClient {
+	t.Helper()
+	cache, err := newHTTPCache(dir, 0, client.maxBodySize, client.http.Transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &apiClient{
+		http:        &http.Client{Transport: cache},
+		serviceURL:  client.serviceURL,
+		maxBodySize: client.maxBodySize,
+		limiter:     newRateLimiter(0),
+		sleeper:     client.sleeper,
+	}
+}
+
+func TestHTTPCacheSurvivesRestart(t *testing.T) {
+	api := &etagAPI{body: `{"followers":[{"did":"did:plc:a","handle":"a.test"}],"cursor":"next"}`}
+	client, _ := newTestClient(t, api)
+	dir := t.TempDir()
+	url := client.xrpcURL("app.bsky.graph.getFollowers", nil)
+
+	for run := 1; run <= 2; run++ {
+		var v APIResponse
+		if err := cachedClient(t, client, dir).getJSON(context.Background(), url, &v); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		if len(v.Followers) != 1 || v.Cursor != "next" {
+			t.Errorf("run %d decoded %+v", run, v)
+		}
+	}
+	if full, notModified := api.full.Load(), api.notModified.Load(); full != 1 || notModified != 1 {
+		t.Errorf("served %d full responses and %d 304s, want the second run answered from disk", full, notModified)
+	}
+}
+
+// countingTransport counts the bytes read from response bodies.
+type countingTransport struct {
+	base http.RoundTripper
+	read atomic.Int64
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(resp.Body, writerFunc(func(p []byte) (int, error) {
+		t.read.Add(int64(len(p)))
+		return len(p), nil
+	})), resp.Body}
+	return resp, nil
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestHTTPCacheStopsReadingAtMaxBodySize(t *testing.T) {
+	api := &etagAPI{body: `{"followers":[` + strings.Repeat(`{"did":"did:plc:x","handle":"x.test"},`, 500) + `{"did":"did:plc:y"}]}`}
+	client, _ := newTestClient(t, api)
+	client.maxBodySize = 1024
+	counter := &countingTransport{base: client.http.Transport}
+	dir := t.TempDir()
+	cache, err := newHTTPCache(dir, 0, client.maxBodySize, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, client.xrpcURL("app.bsky.graph.getFollowers", nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := cache.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	if n := counter.read.Load(); n > client.maxBodySize+1 {
+		t.Errorf("cache read %d bytes of a %d byte body, want at most %d", n, len(api.body), client.maxBodySize+1)
+	}
+	// The caller still gets the whole body, for the client's limit to reject.
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != api.body {
+		t.Errorf("passed through %d bytes, %v; want the %d byte body", len(body), err, len(api.body))
+	}
+	if entries, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(entries) != 0 {
+		t.Errorf("cached %v, want an oversized body left out", entries)
+	}
+
+	var v APIResponse
+	client.http = &http.Client{Transport: cache}
+	if err := client.getJSON(context.Background(), req.URL.String(), &v); !errors.Is(err, errBodyTooLarge) {
+		t.Errorf("getJSON through the cache = %v, want errBodyTooLarge", err)
+	}
+}
+
+func TestHTTPCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	api := &etagAPI{body: strings.Repeat("x", 600)}
+	client, _ := newTestClient(t, api)
+	dir := t.TempDir()
+	cache, err := newHTTPCache(dir, 1000, 0, client.http.Transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/first", "/second"} {
+		req, err := http.NewRequest(http.MethodGet, client.serviceURL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := cache.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		// Entries are ordered by modification time, which may not tick
+		// between two quick stores.
+		if path == "/first" {
+			used := time.Now().Add(-time.Hour)
+			os.Chtimes(cache.bodyPath(cacheKey(req)), used, used)
+		}
+	}
+
+	bodies, _ := filepath.Glob(filepath.Join(dir, "*.body"))
+	if len(bodies) != 1 {
+		t.Fatalf("cache holds %d bodies, want only the latest within 1000 bytes", len(bodies))
+	}
+	second, _ := http.NewRequest(http.MethodGet, client.serviceURL+"/second", nil)
+	if _, err := os.Stat(cache.bodyPath(cacheKey(second))); err != nil {
+		t.Errorf("latest entry was evicted: %v", err)
+	}
+}
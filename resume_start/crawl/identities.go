@@ -0,0 +1,127 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// identitiesTable maps every DID any mode has come across to its latest
+// handle, so handles can be looked up without asking the API again.
+const identitiesTable = "identities"
+
+// identity is an account's DID and handle.
+type identity struct {
+	did    string
+	handle string
+}
+
+// initializeIdentities creates the table of DIDs and their handles.
+func initializeIdentities(db *sql.DB) error {
+	ddl, err := identitiesDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create identities table: %w", err)
+	}
+	return nil
+}
+
+// identitiesDDL returns the statements creating the identities table.
+func identitiesDDL() (string, error) {
+	table, err := quoteIdent(identitiesTable)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			did TEXT PRIMARY KEY,
+			handle TEXT,
+			resolved_at DATETIME
+		);
+		CREATE INDEX IF NOT EXISTS identities_handle ON %s (handle);
+	`, table, table), nil
+}
+
+// followerIdentities returns the identities of followers.
+func followerIdentities(followers []Follower) []identity {
+	ids := make([]identity, 0, len(followers))
+	for _, f := range followers {
+		ids = append(ids, identity{did: f.DID, handle: f.Handle})
+	}
+	return ids
+}
+
+// recordIdentities stores the handle of each DID as of resolvedAt, replacing
+// what was stored before. Identities without both a DID and a handle are skipped.
+func recordIdentities(ctx context.Context, tx dbtx, ids []identity, resolvedAt time.Time) error {
+	table, err := quoteIdent(identitiesTable)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if id.did == "" || id.handle == "" {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(`
+			INSERT INTO %s (did, handle, resolved_at) VALUES (?, ?, ?)
+			ON CONFLICT (did) DO UPDATE SET handle = excluded.handle, resolved_at = excluded.resolved_at;
+		`, table), id.did, normalizeHandle(id.handle), resolvedAt)
+		if err != nil {
+			return fmt.Errorf("failed to record identity of %s: %w", id.did, err)
+		}
+	}
+	return nil
+}
+
+// lookupIdentity returns the stored identity of actor, a DID or handle, and
+// whether there is one.
+func lookupIdentity(ctx context.Context, db *sql.DB, actor string) (identity, bool, error) {
+	table, err := quoteIdent(identitiesTable)
+	if err != nil {
+		return identity{}, false, err
+	}
+
+	column, key := "handle", normalizeHandle(strings.TrimPrefix(actor, "@"))
+	if strings.HasPrefix(actor, "did:") {
+		column, key = "did", actor
+	}
+	var id identity
+	err = db.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT did, handle FROM %s WHERE %s = ? ORDER BY resolved_at DESC LIMIT 1;
+	`, table, column), key).Scan(&id.did, &id.handle)
+	if errors.Is(err, sql.ErrNoRows) {
+		return identity{}, false, nil
+	}
+	if err != nil {
+		return identity{}, false, fmt.Errorf("failed to look up identity of %s: %w", actor, err)
+	}
+	return id, true, nil
+}
+
+// resolveIdentity returns the identity of actor, a DID or handle, from the
+// identities table, or resolves it with getProfile and stores it there. Modes
+// that are only given DIDs can find the handles through it.
+func resolveIdentity(ctx context.Context, db *sql.DB, client *apiClient, actor string) (identity, error) {
+	if id, ok, err := lookupIdentity(ctx, db, actor); err != nil || ok {
+		return id, err
+	}
+	profile, err := client.getProfile(ctx, actor)
+	if err != nil {
+		return identity{}, err
+	}
+	if profile.DID == "" {
+		return identity{}, fmt.Errorf("profile of %s has no DID", actor)
+	}
+	id := identity{did: profile.DID, handle: normalizeHandle(profile.Handle)}
+	if err := recordIdentities(ctx, db, []identity{id}, time.Now().UTC()); err != nil {
+		return identity{}, err
+	}
+	return id, nil
+}
@@ -0,0 +1,49 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestModesShareIdentities(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	api := &followersAPI{pages: [][]Follower{{{DID: "did:plc:s1", Handle: "Old-S1.test"}, {DID: "did:plc:f", Handle: "f.test"}}}}
+	if _, err := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), api).Run(ctx); err != nil {
+		t.Fatalf("followers Run: %v", err)
+	}
+	if id, ok, err := lookupIdentity(ctx, db, "old-s1.test"); err != nil || !ok || id.did != "did:plc:s1" {
+		t.Fatalf("lookupIdentity after the followers crawl = %+v, %v, %v", id, ok, err)
+	}
+
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"suggestions":[{"did":"did:plc:s1","handle":"s1.test"},{"did":"did:plc:s2","handle":"s2.test"}]}`)
+	}))
+	if err := runSuggestions(ctx, db, client, "alice.test"); err != nil {
+		t.Fatalf("runSuggestions: %v", err)
+	}
+
+	handles := make(map[string]string)
+	rows, err := db.Query(`SELECT did, handle FROM identities;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var did, handle string
+		if err := rows.Scan(&did, &handle); err != nil {
+			t.Fatal(err)
+		}
+		handles[did] = handle
+	}
+	want := map[string]string{"did:plc:s1": "s1.test", "did:plc:s2": "s2.test", "did:plc:f": "f.test"}
+	if !reflect.DeepEqual(handles, want) {
+		t.Errorf("identities = %v, want %v with s1 renamed by the suggestions mode", handles, want)
+	}
+	if _, ok, _ := lookupIdentity(ctx, db, "old-s1.test"); ok {
+		t.Error("the old handle still resolves after the suggestions mode updated it")
+	}
+}
@@ -0,0 +1,38 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+)
+
+// ingestResponses saves the followers of every APIResponse read from r, which
+// holds JSON objects one after another (typically one per line, as captured
+// pages are). No API requests are made. It returns the number of pages and
+// followers saved.
+func ingestResponses(ctx context.Context, db *sql.DB, r io.Reader) (pages, followers int, err error) {
+	dec := json.NewDecoder(r)
+	for {
+		if ctx.Err() != nil {
+			return pages, followers, ctx.Err()
+		}
+		var page APIResponse
+		err := dec.Decode(&page)
+		if errors.Is(err, io.EOF) {
+			return pages, followers, nil
+		}
+		if err != nil {
+			return pages, followers, fmt.Errorf("failed to decode response %d: %w", pages+1, err)
+		}
+		if err := saveFollowers(ctx, db, page.Followers); err != nil {
+			return pages, followers, fmt.Errorf("failed to save response %d: %w", pages+1, err)
+		}
+		pages++
+		followers += len(page.Followers)
+		log.Printf("Ingested response %d with %d followers (cursor %q).\n", pages, len(page.Followers), page.Cursor)
+	}
+}
@@ -0,0 +1,42 @@
+package crawl
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestIngestResponsesFromNDJSON(t *testing.T) {
+	db := openTestDB(t)
+	input := `{"followers":[{"did":"did:plc:a","handle":"a.test"},{"did":"did:plc:b","handle":"b.test"}],"cursor":"c1"}
+{"followers":[{"did":"did:plc:c","handle":"c.test","createdAt":"2024-01-02T03:04:05Z"}]}
+`
+	pages, followers, err := ingestResponses(context.Background(), db, strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ingestResponses: %v", err)
+	}
+	if pages != 2 || followers != 3 {
+		t.Errorf("ingested %d pages and %d followers, want 2 and 3", pages, followers)
+	}
+	handles, err := loadHandles(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handles) != 3 || handles["did:plc:c"] != "c.test" {
+		t.Errorf("stored followers = %v", handles)
+	}
+}
+
+func TestIngestResponsesRejectsGarbage(t *testing.T) {
+	db := openTestDB(t)
+	input := `{"followers":[{"did":"did:plc:a","handle":"a.test"}]}
+not json
+`
+	pages, _, err := ingestResponses(context.Background(), db, strings.NewReader(input))
+	if err == nil || !strings.Contains(err.Error(), "response 2") {
+		t.Fatalf("ingestResponses = %v, want an error naming response 2", err)
+	}
+	if pages != 1 {
+		t.Errorf("ingested %d pages before the error, want 1", pages)
+	}
+}
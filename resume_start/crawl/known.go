@@ -0,0 +1,142 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// knownFollowersTable holds the followers of an actor that the authenticated
+// account follows, as of the last -mode known-followers run for that actor.
+const knownFollowersTable = "known_followers"
+
+// initializeKnownFollowers creates the known followers table.
+func initializeKnownFollowers(db *sql.DB) error {
+	ddl, err := knownFollowersDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create known followers table: %w", err)
+	}
+	return nil
+}
+
+// knownFollowersDDL returns the statements creating the known followers table.
+func knownFollowersDDL() (string, error) {
+	table, err := quoteIdent(knownFollowersTable)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			subject_did TEXT,
+			subject_handle TEXT,
+			did TEXT,
+			handle TEXT,
+			displayName TEXT,
+			avatar TEXT,
+			observed_at DATETIME,
+			PRIMARY KEY (subject_did, did)
+		);
+	`, table), nil
+}
+
+// runKnownFollowers crawls the followers of actor that the authenticated
+// account follows and replaces the stored ones for that subject.
+func runKnownFollowers(ctx context.Context, db *sql.DB, client *apiClient, actor string) error {
+	if err := initializeKnownFollowers(db); err != nil {
+		return err
+	}
+
+	var subject *Subject
+	var followers []Follower
+	cursor := ""
+	for {
+		page, err := fetchKnownFollowers(ctx, client, actor, cursor)
+		if err != nil {
+			return err
+		}
+		if subject == nil {
+			subject = page.Subject
+		}
+		followers = append(followers, page.Followers...)
+		if page.Cursor == "" {
+			break
+		}
+		cursor = page.Cursor
+	}
+	if subject == nil || subject.DID == "" {
+		return errors.New("getKnownFollowers response has no subject")
+	}
+
+	observedAt := time.Now().UTC()
+	if err := saveKnownFollowers(ctx, db, *subject, followers, observedAt); err != nil {
+		return err
+	}
+	log.Printf("Saved %d known followers of %s.\n", len(followers), subject.DID)
+	return nil
+}
+
+// fetchKnownFollowers requests one page of the known followers of actor. The
+// response has the shape of getFollowers.
+func fetchKnownFollowers(ctx context.Context, client *apiClient, actor, cursor string) (APIResponse, error) {
+	query := url.Values{"actor": {actor}, "limit": {strconv.Itoa(pageLimit)}}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	var knownResp APIResponse
+	if err := client.getJSON(ctx, client.xrpcURL("app.bsky.graph.getKnownFollowers", query), &knownResp); err != nil {
+		return APIResponse{}, fmt.Errorf("%w for cursor %s", err, cursor)
+	}
+	return knownResp, nil
+}
+
+// saveKnownFollowers replaces the known followers of subject in a single transaction.
+func saveKnownFollowers(ctx context.Context, db *sql.DB, subject Subject, followers []Follower, observedAt time.Time) error {
+	table, err := quoteIdent(knownFollowersTable)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE subject_did = ?;`, table), subject.DID); err != nil {
+		return fmt.Errorf("failed to clear known followers of %s: %w", subject.DID, err)
+	}
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`
+		INSERT OR REPLACE INTO %s (subject_did, subject_handle, did, handle, displayName, avatar, observed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?);
+	`, table))
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, f := range followers {
+		if _, err := stmt.ExecContext(ctx, subject.DID, normalizeHandle(subject.Handle), f.DID, normalizeHandle(f.Handle),
+			f.DisplayName, storedAvatar(f.Avatar), observedAt); err != nil {
+			return fmt.Errorf("failed to save known follower %s: %w", f.DID, err)
+		}
+	}
+	ids := append(followerIdentities(followers), identity{did: subject.DID, handle: subject.Handle})
+	if err := recordIdentities(ctx, tx, ids, observedAt); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
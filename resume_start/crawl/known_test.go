@@ -0,0 +1,68 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKnownFollowersStoresRowsAndSubject(t *testing.T) {
+	db := openTestDB(t)
+	var paths []string
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		subject := `"subject":{"did":"did:plc:subject","handle":"Subject.test"}`
+		if r.URL.Query().Get("cursor") == "" {
+			fmt.Fprintf(w, `{%s,"followers":[{"did":"did:plc:a","handle":"A.test","displayName":"A"}],"cursor":"next"}`, subject)
+			return
+		}
+		fmt.Fprintf(w, `{%s,"followers":[{"did":"did:plc:b","handle":"b.test"}]}`, subject)
+	}))
+	captureLog(t)
+
+	if err := runKnownFollowers(context.Background(), db, client, "subject.test"); err != nil {
+		t.Fatalf("runKnownFollowers: %v", err)
+	}
+	if want := []string{"/xrpc/app.bsky.graph.getKnownFollowers", "/xrpc/app.bsky.graph.getKnownFollowers"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("requested %v, want %v", paths, want)
+	}
+	rows, err := db.Query(`SELECT subject_did, subject_handle, did, handle, displayName FROM known_followers ORDER BY did;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var subjectDID, subjectHandle, did, handle, displayName string
+		if err := rows.Scan(&subjectDID, &subjectHandle, &did, &handle, &displayName); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strings.Join([]string{subjectDID, subjectHandle, did, handle, displayName}, " "))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"did:plc:subject subject.test did:plc:a a.test A",
+		"did:plc:subject subject.test did:plc:b b.test ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("known followers = %q, want %q", got, want)
+	}
+	if id, ok, err := lookupIdentity(context.Background(), db, "subject.test"); err != nil || !ok || id.did != "did:plc:subject" {
+		t.Errorf("subject identity = %+v, %v, %v", id, ok, err)
+	}
+}
+
+func TestKnownFollowersNeedsSubject(t *testing.T) {
+	db := openTestDB(t)
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"followers":[{"did":"did:plc:a","handle":"a.test"}]}`)
+	}))
+	if err := runKnownFollowers(context.Background(), db, client, "subject.test"); err == nil || !strings.Contains(err.Error(), "no subject") {
+		t.Fatalf("runKnownFollowers = %v, want a missing subject error", err)
+	}
+}
@@ -0,0 +1,168 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const labelsTable = "follower_labels"
+
+// initializeLabels creates the normalized labels table, one row per follower label,
+// so followers can be joined and filtered by label type or value.
+func initializeLabels(db *sql.DB) error {
+	ddl, err := labelsDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create labels table: %w", err)
+	}
+	return nil
+}
+
+// labelsDDL returns the statements creating the normalized labels table.
+func labelsDDL() (string, error) {
+	return labelsTableDDL(labelsTable)
+}
+
+// labelsTableDDL returns the statements creating a labels table named name.
+func labelsTableDDL(name string) (string, error) {
+	table, err := quoteIdent(name)
+	if err != nil {
+		return "", err
+	}
+	index, err := quoteIdent(name + "_value")
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			did TEXT,
+			type TEXT,
+			value TEXT,
+			PRIMARY KEY (did, type, value)
+		);
+		CREATE INDEX IF NOT EXISTS %s ON %s (value);
+	`, table, index, table), nil
+}
+
+// labelWriter replaces a follower's normalized label rows within a transaction.
+type labelWriter struct {
+	del *sql.Stmt
+	ins *sql.Stmt
+}
+
+// newLabelWriter prepares the statements of the labels table name on tx.
+func newLabelWriter(ctx context.Context, tx *sql.Tx, name string) (*labelWriter, error) {
+	table, err := quoteIdent(name)
+	if err != nil {
+		return nil, err
+	}
+
+	del, err := tx.PrepareContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE did = ?;`, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare label delete: %w", err)
+	}
+	ins, err := tx.PrepareContext(ctx, fmt.Sprintf(`INSERT OR IGNORE INTO %s (did, type, value) VALUES (?, ?, ?);`, table))
+	if err != nil {
+		del.Close()
+		return nil, fmt.Errorf("failed to prepare label insert: %w", err)
+	}
+	return &labelWriter{del: del, ins: ins}, nil
+}
+
+// replace deletes the follower's existing labels and inserts the current ones.
+func (w *labelWriter) replace(ctx context.Context, follower Follower) error {
+	if _, err := w.del.ExecContext(ctx, follower.DID); err != nil {
+		return fmt.Errorf("failed to delete labels: %w", err)
+	}
+	for _, label := range follower.Labels {
+		if _, err := w.ins.ExecContext(ctx, follower.DID, label.Type, label.Value); err != nil {
+			return fmt.Errorf("failed to insert label: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close releases the prepared statements.
+func (w *labelWriter) Close() {
+	w.del.Close()
+	w.ins.Close()
+}
+
+// LabelSerializer converts a follower's labels to and from the inline labels
+// column. The normalized follower_labels table is written regardless.
+type LabelSerializer interface {
+	Serialize(labels []Label) string
+	Deserialize(s string) ([]Label, error)
+}
+
+// commaLabels is the legacy comma-separated "type:value" encoding.
+type commaLabels struct{}
+
+func (commaLabels) Serialize(labels []Label) string { return formatLabels(labels) }
+
+func (commaLabels) Deserialize(s string) ([]Label, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var labels []Label
+	for _, part := range strings.Split(s, ",") {
+		typ, value, _ := strings.Cut(part, ":")
+		labels = append(labels, Label{Type: typ, Value: value})
+	}
+	return labels, nil
+}
+
+// jsonLabels stores labels as a JSON array, which survives commas and colons
+// in label values.
+type jsonLabels struct{}
+
+func (jsonLabels) Serialize(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(labels) // Label only holds strings
+	return string(data)
+}
+
+func (jsonLabels) Deserialize(s string) ([]Label, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var labels []Label
+	if err := json.Unmarshal([]byte(s), &labels); err != nil {
+		return nil, fmt.Errorf("failed to decode labels: %w", err)
+	}
+	return labels, nil
+}
+
+// tableLabels leaves the inline column empty and keeps labels only in the
+// normalized table.
+type tableLabels struct{}
+
+func (tableLabels) Serialize([]Label) string            { return "" }
+func (tableLabels) Deserialize(string) ([]Label, error) { return nil, nil }
+
+// labelSerializers maps each -label-format to its serializer.
+var labelSerializers = map[string]LabelSerializer{
+	"comma": commaLabels{},
+	"json":  jsonLabels{},
+	"table": tableLabels{},
+}
+
+// labelSerializer is the serializer used for the labels column.
+var labelSerializer LabelSerializer = commaLabels{}
+
+// decodeLabels reads a stored labels column whatever format wrote it, as a
+// database can hold rows saved with different -label-format settings.
+func decodeLabels(s string) ([]Label, error) {
+	if strings.HasPrefix(s, "[") {
+		return jsonLabels{}.Deserialize(s)
+	}
+	return commaLabels{}.Deserialize(s)
+}
@@ -0,0 +1,98 @@
+package crawl
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestLabelsQueryableByValue(t *testing.T) {
+	db := openTestDB(t)
+	seedFollowers(t, db,
+		Follower{DID: "did:plc:a", Handle: "a.test", Labels: []Label{{Type: "self", Value: "porn"}, {Type: "mod", Value: "spam"}}},
+		Follower{DID: "did:plc:b", Handle: "b.test", Labels: []Label{{Type: "mod", Value: "!warn"}}},
+	)
+
+	var handle string
+	if err := db.QueryRow(`
+		SELECT f.handle FROM followers f JOIN follower_labels l ON l.did = f.did WHERE l.value = 'spam';
+	`).Scan(&handle); err != nil || handle != "a.test" {
+		t.Fatalf("follower labelled spam = %q, %v; want a.test", handle, err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM follower_labels WHERE did = 'did:plc:a';`).Scan(&n); err != nil || n != 2 {
+		t.Fatalf("labels of did:plc:a = %d, %v; want 2", n, err)
+	}
+
+	// Saving again replaces the labels rather than adding to them.
+	seedFollowers(t, db, Follower{DID: "did:plc:a", Handle: "a.test", Labels: []Label{{Type: "mod", Value: "spam"}}})
+	if err := db.QueryRow(`SELECT COUNT(*) FROM follower_labels WHERE did = 'did:plc:a';`).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("labels of did:plc:a after resaving = %d, %v; want 1", n, err)
+	}
+}
+
+// useLabelSerializer switches the labels column format for the rest of the test.
+func useLabelSerializer(t *testing.T, s LabelSerializer) {
+	t.Helper()
+	saved := labelSerializer
+	labelSerializer = s
+	t.Cleanup(func() { labelSerializer = saved })
+}
+
+// storedLabels returns the labels of did from the normalized table, by value.
+func storedLabels(t *testing.T, db *sql.DB, did string) []Label {
+	t.Helper()
+	rows, err := db.Query(`SELECT type, value FROM follower_labels WHERE did = ? ORDER BY value;`, did)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var labels []Label
+	for rows.Next() {
+		var l Label
+		if err := rows.Scan(&l.Type, &l.Value); err != nil {
+			t.Fatal(err)
+		}
+		labels = append(labels, l)
+	}
+	return labels
+}
+
+func TestLabelSerializersRoundTrip(t *testing.T) {
+	labels := []Label{{Type: "mod", Value: "!warn"}, {Type: "self", Value: "porn"}}
+	for name, s := range labelSerializers {
+		t.Run(name, func(t *testing.T) {
+			useLabelSerializer(t, s)
+			db := openTestDB(t)
+			seedFollowers(t, db, Follower{DID: "did:plc:a", Handle: "a.test", Labels: labels})
+
+			var column string
+			if err := db.QueryRow(`SELECT COALESCE(labels, '') FROM followers WHERE did = 'did:plc:a';`).Scan(&column); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Deserialize(column)
+			if err != nil {
+				t.Fatalf("Deserialize(%q): %v", column, err)
+			}
+			if name == "table" {
+				if column != "" {
+					t.Errorf("labels column = %q, want it left empty", column)
+				}
+				got = storedLabels(t, db, "did:plc:a")
+			} else if decoded, err := decodeLabels(column); err != nil || !reflect.DeepEqual(decoded, labels) {
+				t.Errorf("decodeLabels(%q) = %v, %v; want %v", column, decoded, err, labels)
+			}
+			if !reflect.DeepEqual(got, labels) {
+				t.Errorf("labels stored as %q read back as %v, want %v", column, got, labels)
+			}
+		})
+	}
+}
+
+func TestJSONLabelsKeepSeparators(t *testing.T) {
+	labels := []Label{{Type: "a:b", Value: "x,y"}}
+	got, err := jsonLabels{}.Deserialize(jsonLabels{}.Serialize(labels))
+	if err != nil || !reflect.DeepEqual(got, labels) {
+		t.Errorf("round trip = %v, %v; want %v", got, err, labels)
+	}
+}
@@ -0,0 +1,99 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/abadojack/whatlanggo"
+)
+
+// languageUnknown is stored as the language of an empty bio, or of one the
+// detector isn't confident about.
+const languageUnknown = "unknown"
+
+// minLanguageConfidence is the detector confidence below which a bio's
+// language is recorded as unknown. Bios are short, so the detector's own
+// reliability threshold would reject most of them.
+const minLanguageConfidence = 0.5
+
+// languageColumns are the columns populated by -detect-language.
+var languageColumns = map[string]string{"bio_lang": "TEXT"}
+
+// detectLanguage returns the probable ISO 639 code of the language text is
+// written in, or languageUnknown. The detector is only used here, so it can be
+// replaced without touching the rest of the crawler.
+func detectLanguage(text string) string {
+	if strings.TrimSpace(text) == "" {
+		return languageUnknown
+	}
+	info := whatlanggo.Detect(text)
+	if info.Confidence < minLanguageConfidence {
+		return languageUnknown
+	}
+	if code := info.Lang.Iso6391(); code != "" {
+		return code
+	}
+	if code := info.Lang.Iso6393(); code != "" {
+		return code
+	}
+	return languageUnknown
+}
+
+// detectLanguages sets bio_lang of every stored follower from its description
+// in a single transaction, so a changed bio is re-detected on the next run.
+func detectLanguages(ctx context.Context, db *sql.DB) error {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return err
+	}
+	if err := ensureColumns(db, tableName, languageColumns); err != nil {
+		return err
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`SELECT did, COALESCE(description, '') FROM %s;`, table))
+	if err != nil {
+		return fmt.Errorf("failed to query descriptions: %w", err)
+	}
+	langs := make(map[string]string)
+	for rows.Next() {
+		var did, description string
+		if err := rows.Scan(&did, &description); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan description: %w", err)
+		}
+		langs[did] = detectLanguage(description)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate descriptions: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`UPDATE %s SET bio_lang = ? WHERE did = ?;`, table))
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	counts := make(map[string]int)
+	for did, lang := range langs {
+		if _, err := stmt.ExecContext(ctx, lang, did); err != nil {
+			return fmt.Errorf("failed to update language of %s: %w", did, err)
+		}
+		counts[lang]++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	log.Printf("Detected bio languages of %d followers, %d unknown.\n", len(langs), counts[languageUnknown])
+	return nil
+}
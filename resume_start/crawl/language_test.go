@@ -0,0 +1,42 @@
+package crawl
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	for text, want := range map[string]string{
+		"Software engineer who writes about distributed systems, coffee and the weather in my city.":    "en",
+		"Ingeniera de software, escribo sobre sistemas distribuidos, el café y el tiempo en mi ciudad.": "es",
+		"":     languageUnknown,
+		" \n ": languageUnknown,
+	} {
+		if got := detectLanguage(text); got != want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestDetectLanguagesStoresBioLang(t *testing.T) {
+	db := openTestDB(t)
+	seedFollowers(t, db,
+		Follower{DID: "did:plc:en", Handle: "en.test", Description: "I take photographs of birds and write about the mountains where I live."},
+		Follower{DID: "did:plc:de", Handle: "de.test", Description: "Ich fotografiere Vögel und schreibe über die Berge, in denen ich wohne."},
+		Follower{DID: "did:plc:none", Handle: "none.test"},
+	)
+	captureLog(t)
+
+	if err := detectLanguages(context.Background(), db); err != nil {
+		t.Fatalf("detectLanguages: %v", err)
+	}
+	for did, want := range map[string]string{"did:plc:en": "en", "did:plc:de": "de", "did:plc:none": languageUnknown} {
+		var lang string
+		if err := db.QueryRow(`SELECT bio_lang FROM followers WHERE did = ?;`, did).Scan(&lang); err != nil {
+			t.Fatal(err)
+		}
+		if lang != want {
+			t.Errorf("bio_lang of %s = %q, want %q", did, lang, want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const listSubscriptionsTable = "list_subscriptions"
+
+// listEndpoints maps the list modes to the XRPC method listing the moderation
+// lists the authenticated account subscribes to.
+var listEndpoints = map[string]string{
+	"listblocks": "app.bsky.graph.getListBlocks",
+	"mutelists":  "app.bsky.graph.getListMutes",
+}
+
+// ListView represents a list as returned by getListBlocks and getListMutes.
+type ListView struct {
+	URI           string   `json:"uri"`
+	CID           string   `json:"cid"`
+	Creator       Follower `json:"creator"`
+	Name          string   `json:"name"`
+	Purpose       string   `json:"purpose"`
+	Description   string   `json:"description"`
+	Avatar        string   `json:"avatar"`
+	ListItemCount int      `json:"listItemCount"`
+	Labels        []Label  `json:"labels"`
+	IndexedAt     bskyTime `json:"indexedAt"`
+}
+
+// ListsResponse represents a page of getListBlocks or getListMutes.
+type ListsResponse struct {
+	Lists  []ListView `json:"lists"`
+	Cursor string     `json:"cursor"`
+}
+
+// initializeListSubscriptions creates the table of subscribed moderation lists.
+func initializeListSubscriptions(db *sql.DB) error {
+	ddl, err := listSubscriptionsDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create list subscriptions table: %w", err)
+	}
+	return nil
+}
+
+// listSubscriptionsDDL returns the statements creating the list subscriptions
+// table. A list can be subscribed to both as a block list and as a mute list.
+func listSubscriptionsDDL() (string, error) {
+	table, err := quoteIdent(listSubscriptionsTable)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			uri TEXT,
+			kind TEXT,
+			cid TEXT,
+			name TEXT,
+			purpose TEXT,
+			description TEXT,
+			avatar TEXT,
+			list_item_count INTEGER,
+			labels TEXT,
+			creator_did TEXT,
+			creator_handle TEXT,
+			indexedAt DATETIME,
+			observed_at DATETIME,
+			PRIMARY KEY (uri, kind)
+		);
+	`, table), nil
+}
+
+// runListSubscriptions snapshots the block or mute lists the authenticated
+// account subscribes to, replacing the previous snapshot of that kind.
+func runListSubscriptions(ctx context.Context, db *sql.DB, client *apiClient, mode string) error {
+	method, ok := listEndpoints[mode]
+	if !ok {
+		return fmt.Errorf("unknown list mode %q", mode)
+	}
+	if client.authToken == "" {
+		return errors.New("-mode " + mode + " lists the authenticated account's subscriptions and requires -auth-token")
+	}
+	if err := initializeListSubscriptions(db); err != nil {
+		return err
+	}
+
+	var lists []ListView
+	cursor := ""
+	for {
+		query := url.Values{"limit": {strconv.Itoa(pageLimit)}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		var page ListsResponse
+		if err := client.getJSON(ctx, client.xrpcURL(method, query), &page); err != nil {
+			return err
+		}
+		lists = append(lists, page.Lists...)
+		if page.Cursor == "" {
+			break
+		}
+		cursor = page.Cursor
+	}
+
+	observedAt := time.Now().UTC()
+	if err := saveListSubscriptions(ctx, db, mode, lists, observedAt); err != nil {
+		return err
+	}
+	log.Printf("Saved %d subscribed lists for %s.\n", len(lists), mode)
+	return nil
+}
+
+// saveListSubscriptions replaces the stored lists of kind with lists in a
+// single transaction, so unsubscribed lists disappear.
+func saveListSubscriptions(ctx context.Context, db *sql.DB, kind string, lists []ListView, observedAt time.Time) error {
+	table, err := quoteIdent(listSubscriptionsTable)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE kind = ?;`, table), kind); err != nil {
+		return fmt.Errorf("failed to clear previous %s snapshot: %w", kind, err)
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+		INSERT OR REPLACE INTO %s (uri, kind, cid, name, purpose, description, avatar, list_item_count, labels, creator_did, creator_handle, indexedAt, observed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+	`, table))
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, list := range lists {
+		_, err := stmt.Exec(
+			list.URI,
+			kind,
+			list.CID,
+			list.Name,
+			list.Purpose,
+			list.Description,
+			list.Avatar,
+			list.ListItemCount,
+			formatLabels(list.Labels),
+			list.Creator.DID,
+			list.Creator.Handle,
+			list.IndexedAt.Time,
+			observedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save list %s: %w", list.URI, err)
+		}
+		creator := identity{did: list.Creator.DID, handle: list.Creator.Handle}
+		if err := recordIdentities(ctx, tx, []identity{creator}, observedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// listsAPI is a mock getListBlocks/getListMutes endpoint serving two pages of
+// list views to requests carrying the test token.
+func listsAPI(t *testing.T) http.Handler {
+	pages := map[string]string{
+		"": `{"cursor":"p2","lists":[{"uri":"at://did:plc:mod/app.bsky.graph.list/1","cid":"cid1",
+			"creator":{"did":"did:plc:mod","handle":"mod.test"},"name":"Spammers","purpose":"app.bsky.graph.defs#modlist",
+			"listItemCount":12,"labels":[{"type":"moderation","value":"spam"}],"indexedAt":"2024-01-02T03:04:05.000Z"}]}`,
+		"p2": `{"lists":[{"uri":"at://did:plc:mod/app.bsky.graph.list/2","cid":"cid2",
+			"creator":{"did":"did:plc:mod","handle":"mod.test"},"name":"Trolls","purpose":"app.bsky.graph.defs#modlist",
+			"description":"reported","indexedAt":"2024-01-03T03:04:05+00:00"}]}`,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, `{"error":"AuthMissing","message":"Authentication Required"}`, http.StatusUnauthorized)
+			return
+		}
+		body, ok := pages[r.URL.Query().Get("cursor")]
+		if !ok {
+			t.Errorf("unexpected cursor %q", r.URL.Query().Get("cursor"))
+		}
+		fmt.Fprint(w, body)
+	})
+}
+
+func TestListSubscriptionsSnapshot(t *testing.T) {
+	db := openTestDB(t)
+	client, _ := newTestClient(t, listsAPI(t))
+	client.authToken = "token"
+	ctx := context.Background()
+
+	for _, mode := range []string{"listblocks", "mutelists"} {
+		if err := runListSubscriptions(ctx, db, client, mode); err != nil {
+			t.Fatalf("runListSubscriptions(%s): %v", mode, err)
+		}
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM list_subscriptions WHERE kind = 'listblocks';`).Scan(&n); err != nil || n != 2 {
+		t.Errorf("block lists = %d, %v; want 2", n, err)
+	}
+	var name, creator, labels string
+	var items int
+	if err := db.QueryRow(`SELECT name, creator_handle, list_item_count, labels FROM list_subscriptions
+		WHERE uri = 'at://did:plc:mod/app.bsky.graph.list/1' AND kind = 'mutelists';`).Scan(&name, &creator, &items, &labels); err != nil {
+		t.Fatalf("reading mute list: %v", err)
+	}
+	if name != "Spammers" || creator != "mod.test" || items != 12 || !strings.Contains(labels, "spam") {
+		t.Errorf("mute list = %q by %q, %d items, labels %q", name, creator, items, labels)
+	}
+}
+
+func TestListSubscriptionsRequireAuth(t *testing.T) {
+	db := openTestDB(t)
+	client, _ := newTestClient(t, listsAPI(t))
+
+	err := runListSubscriptions(context.Background(), db, client, "listblocks")
+	if err == nil || !strings.Contains(err.Error(), "requires -auth-token") {
+		t.Fatalf("runListSubscriptions without a token = %v, want an error naming -auth-token", err)
+	}
+
+	client.authToken = "wrong"
+	err = runListSubscriptions(context.Background(), db, client, "mutelists")
+	if err == nil || !strings.Contains(err.Error(), "requires authentication") {
+		t.Fatalf("runListSubscriptions with a rejected token = %v, want an authentication error", err)
+	}
+}
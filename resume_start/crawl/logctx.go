@@ -0,0 +1,58 @@
+package crawl
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sort"
+	"strings"
+)
+
+// loggerKey is the context key of the logger carried by withLogger.
+type loggerKey struct{}
+
+// withLogger returns ctx carrying logger, so code called with it logs with the
+// caller's fields.
+func withLogger(ctx context.Context, logger *log.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns the logger ctx carries, or the standard logger.
+func loggerFrom(ctx context.Context) *log.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*log.Logger); ok {
+		return logger
+	}
+	return log.Default()
+}
+
+// withFields returns a child of logger, the standard logger when nil, that
+// writes key=value pairs from kv, sorted by key, ahead of each message, after
+// the parent's own. The timestamp stays first, so lines still sort by time.
+func withFields(logger *log.Logger, kv ...string) *log.Logger {
+	if logger == nil {
+		logger = log.Default()
+	}
+	var fields []string
+	for i := 0; i+1 < len(kv); i += 2 {
+		fields = append(fields, kv[i]+"="+kv[i+1])
+	}
+	sort.Strings(fields)
+
+	var b strings.Builder
+	b.WriteString(logger.Prefix())
+	for _, field := range fields {
+		b.WriteString(field + " ")
+	}
+	return log.New(logger.Writer(), b.String(), logger.Flags()|log.Lmsgprefix)
+}
+
+// newCrawlID returns a short random ID telling one crawl's log lines from
+// those of crawls running alongside it.
+func newCrawlID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "0"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,67 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestWithFieldsMergesParentFields(t *testing.T) {
+	var buf bytes.Buffer
+	crawlLog := withFields(log.New(&buf, "", 0), "crawl", "abc", "actor", "did:plc:a")
+	pageLog := withFields(crawlLog, "page", "2")
+	pageLog.Printf("Saved %d followers.", 3)
+	crawlLog.Print("Done.")
+
+	want := "actor=did:plc:a crawl=abc page=2 Saved 3 followers.\nactor=did:plc:a crawl=abc Done.\n"
+	if buf.String() != want {
+		t.Errorf("logged %q, want %q", buf.String(), want)
+	}
+
+	ctx := withLogger(context.Background(), pageLog)
+	buf.Reset()
+	loggerFrom(ctx).Print("From the context.")
+	if !strings.HasPrefix(buf.String(), "actor=did:plc:a crawl=abc page=2 ") {
+		t.Errorf("logger carried by the context wrote %q", buf.String())
+	}
+}
+
+func TestWithFieldsSortsKeys(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+	withFields(logger, "page", "1", "actor", "did:plc:a", "status", "ok", "crawl", "abc").Print("Done.")
+	withFields(logger, "crawl", "abc", "status", "ok", "actor", "did:plc:a", "page", "1").Print("Done.")
+
+	want := "actor=did:plc:a crawl=abc page=1 status=ok Done.\n"
+	if buf.String() != want+want {
+		t.Errorf("logged %q, want %q twice", buf.String(), want)
+	}
+}
+
+func TestCrawlLogLinesCarryCrawlAndPage(t *testing.T) {
+	logs := captureLog(t)
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 1), testFollowers("b", 1)}}
+	if _, err := newTestCrawler(t, newMemoryStore(), api).Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	var crawlID string
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if !strings.Contains(line, "Fetched 1 followers") {
+			continue
+		}
+		if !strings.HasPrefix(line, "actor=did:plc:subject crawl=") || !strings.Contains(line, " page=") {
+			t.Errorf("page log line lacks its fields: %q", line)
+			continue
+		}
+		id := strings.Fields(line)[1]
+		if crawlID != "" && id != crawlID {
+			t.Errorf("pages of one crawl logged %s and %s", crawlID, id)
+		}
+		crawlID = id
+	}
+	if crawlID == "" {
+		t.Fatalf("no page log lines:\n%s", logs)
+	}
+}
@@ -0,0 +1,138 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const metadataTable = "metadata"
+
+// initializeMetadata creates the key/value table holding facts about the crawls
+// stored in the database.
+func initializeMetadata(db *sql.DB) error {
+	ddl, err := metadataDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create metadata table: %w", err)
+	}
+	return nil
+}
+
+// metadataDDL returns the statements creating the metadata table.
+func metadataDDL() (string, error) {
+	table, err := quoteIdent(metadataTable)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			key TEXT PRIMARY KEY,
+			value TEXT,
+			updated_at DATETIME
+		);
+	`, table), nil
+}
+
+// setMetadata stores value under key, replacing any previous value.
+func setMetadata(ctx context.Context, db *sql.DB, key, value string) error {
+	table, err := quoteIdent(metadataTable)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT OR REPLACE INTO %s (key, value, updated_at) VALUES (?, ?, ?);
+	`, table), key, value, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to store metadata %s: %w", key, err)
+	}
+	return nil
+}
+
+// getMetadata returns the value stored under key, or an empty string.
+func getMetadata(ctx context.Context, db *sql.DB, key string) (string, error) {
+	table, err := quoteIdent(metadataTable)
+	if err != nil {
+		return "", err
+	}
+
+	var value string
+	err = db.QueryRowContext(ctx, fmt.Sprintf(`SELECT value FROM %s WHERE key = ?;`, table), key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read metadata %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// subjectKey is the metadata key of the subject of crawls of actor.
+func subjectKey(actor string) string {
+	return "subject:" + actor
+}
+
+// recordSubject stores the account the API reported as the subject of crawls of
+// actor, as JSON with its DID and handle. When actor is a handle this pins down
+// the DID the rows were crawled for.
+func recordSubject(ctx context.Context, db *sql.DB, actor string, subject Subject) error {
+	if err := recordIdentities(ctx, db, []identity{{did: subject.DID, handle: subject.Handle}}, time.Now().UTC()); err != nil {
+		return err
+	}
+	data, err := json.Marshal(subject)
+	if err != nil {
+		return fmt.Errorf("failed to encode subject: %w", err)
+	}
+	return setMetadata(ctx, db, subjectKey(actor), string(data))
+}
+
+// actorKey is the metadata key of the DID the followers table was crawled for.
+const actorKey = "actor"
+
+// errActorMismatch is returned when the followers table belongs to another actor.
+var errActorMismatch = errors.New("database was crawled for a different actor")
+
+// resolveDID returns the DID of actor, looking handles up in the identities
+// table or through the API. A handle that cannot be resolved is returned unchanged.
+func resolveDID(ctx context.Context, db *sql.DB, client *apiClient, actor string) string {
+	if strings.HasPrefix(actor, "did:") {
+		return actor
+	}
+	id, err := resolveIdentity(ctx, db, client, actor)
+	if err != nil {
+		log.Printf("Warning: could not resolve %s to a DID (%v), comparing it as given.\n", actor, err)
+		return actor
+	}
+	return id.did
+}
+
+// checkActor guards against mixing two accounts' followers in one database.
+// The first crawl records the actor's DID; later crawls of a different actor
+// fail with errActorMismatch unless force is set.
+func checkActor(ctx context.Context, db *sql.DB, client *apiClient, actor string, force bool) error {
+	did := resolveDID(ctx, db, client, actor)
+	stored, err := getMetadata(ctx, db, actorKey)
+	if err != nil {
+		return err
+	}
+	switch {
+	case stored == "":
+		return setMetadata(ctx, db, actorKey, did)
+	case stored == did:
+		return nil
+	case force:
+		log.Printf("Warning: database was crawled for %s, crawling %s into it anyway (-force).\n", stored, did)
+		return nil
+	default:
+		return fmt.Errorf("%w: it holds followers of %s, not %s; use another database or pass -force", errActorMismatch, stored, did)
+	}
+}
@@ -0,0 +1,82 @@
+package crawl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestAPIResponseCapturesSubject(t *testing.T) {
+	body := `{"subject":{"did":"did:plc:subject","handle":"subject.test"},"followers":[{"did":"did:plc:a","handle":"a.test"}],"cursor":""}`
+	var resp APIResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Subject == nil || resp.Subject.DID != "did:plc:subject" || resp.Subject.Handle != "subject.test" {
+		t.Fatalf("Subject = %+v, want did:plc:subject and subject.test", resp.Subject)
+	}
+}
+
+func TestCrawlRecordsSubject(t *testing.T) {
+	db := openTestDB(t)
+	var requests int
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		cursor := ""
+		if requests == 1 {
+			cursor = "c1"
+		}
+		fmt.Fprintf(w, `{"subject":{"did":"did:plc:subject","handle":"subject.test"},"followers":[{"did":"did:plc:f%d","handle":"f%d.test"}],"cursor":%q}`, requests, requests, cursor)
+	})
+	c := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), api, WithActor("subject.test"))
+
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	stored, err := getMetadata(context.Background(), db, subjectKey("subject.test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subject Subject
+	if err := json.Unmarshal([]byte(stored), &subject); err != nil {
+		t.Fatalf("stored subject %q is not JSON: %v", stored, err)
+	}
+	if subject != (Subject{DID: "did:plc:subject", Handle: "subject.test"}) {
+		t.Errorf("stored subject = %+v", subject)
+	}
+}
+
+func TestCheckActorRefusesAnotherActor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), dbFile)
+	db, err := initializeDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := checkActor(ctx, db, nil, "did:plc:first", false); err != nil {
+		t.Fatalf("first crawl: checkActor = %v", err)
+	}
+	db.Close()
+
+	db, err = initializeDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := checkActor(ctx, db, nil, "did:plc:first", false); err != nil {
+		t.Errorf("same actor: checkActor = %v, want nil", err)
+	}
+	if err := checkActor(ctx, db, nil, "did:plc:second", false); !errors.Is(err, errActorMismatch) {
+		t.Errorf("other actor: checkActor = %v, want errActorMismatch", err)
+	}
+	if err := checkActor(ctx, db, nil, "did:plc:second", true); err != nil {
+		t.Errorf("other actor with -force: checkActor = %v, want nil", err)
+	}
+	if stored, _ := getMetadata(ctx, db, actorKey); stored != "did:plc:first" {
+		t.Errorf("stored actor = %q after -force, want did:plc:first kept", stored)
+	}
+}
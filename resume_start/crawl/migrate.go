@@ -0,0 +1,234 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// dbtx is the subset of *sql.DB and *sql.Tx used by schema helpers, so they can
+// run inside or outside a transaction.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// migration upgrades the followers schema by one version. Migrations must be
+// idempotent: databases created before versioning start at version 0 even if
+// they already have some of the changes.
+type migration struct {
+	version int
+	name    string
+	apply   func(tx *sql.Tx, table string) error
+}
+
+// migrations lists every schema version in order. The database's PRAGMA
+// user_version records the last one applied.
+var migrations = []migration{
+	{1, "create followers table", func(tx *sql.Tx, table string) error {
+		_, err := tx.Exec(createTableSQL(table, followerColumns))
+		return err
+	}},
+	{2, "add fetch_seq", func(tx *sql.Tx, table string) error {
+		return ensureColumns(tx, tableName, schemaColumns(seqColumns))
+	}},
+	{3, "add first_seen", func(tx *sql.Tx, table string) error {
+		return ensureColumns(tx, tableName, schemaColumns(firstSeenColumns))
+	}},
+	{4, "add last_seen", func(tx *sql.Tx, table string) error {
+		return ensureColumns(tx, tableName, schemaColumns(lastSeenColumns))
+	}},
+	{5, "lowercase handles", func(tx *sql.Tx, table string) error {
+		if err := ensureColumns(tx, tableName, schemaColumns(handleOriginalColumns)); err != nil {
+			return err
+		}
+		existing, err := tableColumns(context.Background(), tx, table)
+		if err != nil {
+			return err
+		}
+		set := "handle = lower(handle)"
+		if existing["handle_original"] {
+			set = "handle_original = COALESCE(handle_original, handle), " + set
+		}
+		// A table keyed on handle can hold both cases of one handle; those rows
+		// are left as they are rather than failing the migration.
+		_, err = tx.Exec(fmt.Sprintf(`UPDATE OR IGNORE %s SET %s WHERE handle != lower(handle);`, table, set))
+		return err
+	}},
+}
+
+// schemaColumns returns the columns that are part of the schema in use, so a
+// migration doesn't add columns such as fetch_seq to a -minimal table.
+func schemaColumns(columns map[string]string) map[string]string {
+	selected := make(map[string]string, len(columns))
+	for name, typ := range columns {
+		if hasColumn(followerColumns, name) {
+			selected[name] = typ
+		}
+	}
+	return selected
+}
+
+// schemaVersion is the version a fully migrated database is at.
+func schemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// runMigrations applies every migration newer than the database's version, each
+// in its own transaction together with the version bump.
+func runMigrations(db *sql.DB) error {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version;`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version > schemaVersion() {
+		log.Printf("Warning: database schema version %d is newer than this tool's %d.\n", version, schemaVersion())
+		return nil
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %d: %w", m.version, err)
+		}
+		if err := m.apply(tx, table); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+		// PRAGMA does not accept bound parameters; the version is an int.
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d;`, m.version)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record schema version %d: %w", m.version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", m.version, err)
+		}
+		log.Printf("Applied schema migration %d: %s.\n", m.version, m.name)
+	}
+	if err := addMissingColumns(db); err != nil {
+		return err
+	}
+	return checkPrimaryKey(db, table, followerColumns)
+}
+
+// addMissingColumns adds the columns of the schema in use that the followers
+// table lacks whatever its version, such as those skipped while a -minimal run
+// migrated it.
+func addMissingColumns(db dbtx) error {
+	columns := make(map[string]string)
+	for _, col := range followerColumns {
+		if !col.key {
+			columns[col.name] = col.typ
+		}
+	}
+	return ensureColumns(db, tableName, columns)
+}
+
+// tableColumns returns the names of the columns table (already quoted) has.
+func tableColumns(ctx context.Context, db dbtx, table string) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`PRAGMA table_info(%s);`, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table info: %w", err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid          int
+			name, typ    string
+			notNull, pk  int
+			defaultValue sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("failed to scan table info: %w", err)
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate table info: %w", err)
+	}
+	return existing, nil
+}
+
+// checkPrimaryKey fails if table (already quoted) exists with a primary key
+// other than the key columns, since CREATE TABLE IF NOT EXISTS keeps the old one.
+func checkPrimaryKey(db dbtx, table string, columns []column) error {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s);`, table))
+	if err != nil {
+		return fmt.Errorf("failed to read table info: %w", err)
+	}
+	defer rows.Close()
+
+	pks := make(map[int]string)
+	for rows.Next() {
+		var (
+			cid          int
+			name, typ    string
+			notNull, pk  int
+			defaultValue sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan table info: %w", err)
+		}
+		if pk > 0 {
+			pks[pk] = name
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate table info: %w", err)
+	}
+	if len(pks) == 0 {
+		return nil // created by a version without a primary key
+	}
+
+	var have, want []string
+	for i := 1; i <= len(pks); i++ {
+		have = append(have, pks[i])
+	}
+	for _, col := range columns {
+		if col.key {
+			want = append(want, col.name)
+		}
+	}
+	if strings.Join(have, ",") != strings.Join(want, ",") {
+		return fmt.Errorf("table %s is keyed on (%s) but (%s) was requested; use -key %s or a fresh database",
+			table, strings.Join(have, ", "), strings.Join(want, ", "), strings.Join(have, ","))
+	}
+	return nil
+}
+
+// missingColumnsWarned records the table.column pairs presentColumns has
+// already warned about, so each is logged once rather than on every page.
+var missingColumnsWarned sync.Map
+
+// presentColumns returns the columns that table (already quoted) actually has,
+// so a save against a database from an older schema binds only what exists.
+func presentColumns(ctx context.Context, db dbtx, table string, columns []column) ([]column, error) {
+	existing, err := tableColumns(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+	present := make([]column, 0, len(columns))
+	for _, col := range columns {
+		if existing[col.name] {
+			present = append(present, col)
+		} else if _, warned := missingColumnsWarned.LoadOrStore(table+"."+col.name, true); !warned {
+			log.Printf("Column %s is missing from %s, not saving it.\n", col.name, table)
+		}
+	}
+	return present, nil
+}
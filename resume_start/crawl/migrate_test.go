@@ -0,0 +1,120 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResumeAgainstPriorSchemaVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), dbFile)
+
+	// The followers table as the first release created it, before versioning.
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(`
+		CREATE TABLE followers (
+			did TEXT PRIMARY KEY, handle TEXT, displayName TEXT, avatar TEXT,
+			viewer_muted BOOLEAN, viewer_blockedBy BOOLEAN, viewer_following TEXT,
+			labels TEXT, createdAt DATETIME, description TEXT, indexedAt DATETIME
+		);
+		INSERT INTO followers (did, handle) VALUES ('did:plc:a0', 'A0.Test');
+	`); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	db, err := initializeDB(path)
+	if err != nil {
+		t.Fatalf("initializeDB on a version 0 database: %v", err)
+	}
+	defer db.Close()
+
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version;`).Scan(&version); err != nil || version != schemaVersion() {
+		t.Fatalf("user_version = %d, %v; want %d", version, err, schemaVersion())
+	}
+	columns, err := tableColumns(context.Background(), db, `"followers"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range fullColumns {
+		if !columns[col.name] {
+			t.Errorf("migrated table lacks %s", col.name)
+		}
+	}
+	var handle, original string
+	if err := db.QueryRow(`SELECT handle, handle_original FROM followers WHERE did = 'did:plc:a0';`).Scan(&handle, &original); err != nil {
+		t.Fatal(err)
+	}
+	if handle != "a0.test" || original != "A0.Test" {
+		t.Errorf("migrated handle = %q (original %q), want a0.test (A0.Test)", handle, original)
+	}
+
+	// Resume a crawl at the second page into the migrated table.
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 1), testFollowers("b", 2)}}
+	c := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), api, WithCursor("c1"))
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("resumed Run: %v", err)
+	}
+	var n int
+	var seq sql.NullInt64
+	if err := db.QueryRow(`SELECT COUNT(*), MAX(fetch_seq) FROM followers;`).Scan(&n, &seq); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || seq.Int64 != 2 {
+		t.Errorf("after resuming: %d rows, max fetch_seq %d; want 3 and 2", n, seq.Int64)
+	}
+}
+
+func TestFullRunAddsColumnsMinimalMigrationSkipped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), dbFile)
+	useColumns(t, minimalColumns)
+	db, err := initializeDB(path)
+	if err != nil {
+		t.Fatalf("initializeDB with -minimal: %v", err)
+	}
+	db.Close()
+
+	// The minimal run left the database at the current version without the
+	// columns its migrations add.
+	useColumns(t, fullColumns)
+	db, err = initializeDB(path)
+	if err != nil {
+		t.Fatalf("initializeDB: %v", err)
+	}
+	defer db.Close()
+	columns, err := tableColumns(context.Background(), db, `"followers"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range fullColumns {
+		if !columns[col.name] {
+			t.Errorf("followers table lacks %s", col.name)
+		}
+	}
+}
+
+func TestMissingColumnWarnedOnce(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec(`CREATE TABLE warn_once (did TEXT PRIMARY KEY);`); err != nil {
+		t.Fatal(err)
+	}
+	logs := captureLog(t)
+	for page := 0; page < 3; page++ {
+		present, err := presentColumns(context.Background(), db, `"warn_once"`, minimalColumns)
+		if err != nil {
+			t.Fatalf("presentColumns: %v", err)
+		}
+		if len(present) != 1 || present[0].name != "did" {
+			t.Errorf("present columns = %v, want only did", present)
+		}
+	}
+	if n := strings.Count(logs.String(), "Column handle is missing"); n != 1 {
+		t.Errorf("warned %d times, want once:\n%s", n, logs)
+	}
+}
@@ -0,0 +1,101 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// crawlMode describes a value of -mode.
+type crawlMode struct {
+	name        string
+	endpoint    string // XRPC method the mode reads
+	auth        bool   // whether the endpoint needs -auth-token
+	description string
+	// run performs the mode. It is nil for followers, which the main crawl
+	// loop handles with its resume, diff and post-crawl steps.
+	run func(ctx context.Context, db *sql.DB, client *apiClient, actor string) error
+}
+
+// modes lists every -mode, in the order -list-modes prints them.
+var modes = []crawlMode{
+	{
+		name:        "followers",
+		endpoint:    "app.bsky.graph.getFollowers",
+		description: "Crawl the actor's followers into the followers table.",
+	},
+	{
+		name:        "following",
+		endpoint:    "app.bsky.graph.getFollows",
+		description: "Crawl the accounts the actor follows into the following table, for -report mutuals.",
+		run:         runFollowing,
+	},
+	{
+		name:        "known-followers",
+		endpoint:    "app.bsky.graph.getKnownFollowers",
+		auth:        true,
+		description: "Snapshot the actor's followers that the authenticated account follows.",
+		run:         runKnownFollowers,
+	},
+	{
+		name:        "suggestions",
+		endpoint:    "app.bsky.graph.getSuggestedFollowsByActor",
+		description: "Snapshot the accounts suggested to follow alongside the actor.",
+		run:         runSuggestions,
+	},
+	{
+		name:        "listblocks",
+		endpoint:    listEndpoints["listblocks"],
+		auth:        true,
+		description: "Snapshot the moderation lists the authenticated account blocks with.",
+		run: func(ctx context.Context, db *sql.DB, client *apiClient, _ string) error {
+			return runListSubscriptions(ctx, db, client, "listblocks")
+		},
+	},
+	{
+		name:        "mutelists",
+		endpoint:    listEndpoints["mutelists"],
+		auth:        true,
+		description: "Snapshot the moderation lists the authenticated account mutes with.",
+		run: func(ctx context.Context, db *sql.DB, client *apiClient, _ string) error {
+			return runListSubscriptions(ctx, db, client, "mutelists")
+		},
+	},
+}
+
+// findMode returns the mode called name.
+func findMode(name string) (crawlMode, bool) {
+	for _, m := range modes {
+		if m.name == name {
+			return m, true
+		}
+	}
+	return crawlMode{}, false
+}
+
+// modeNames returns the mode names joined for flag help and errors.
+func modeNames() string {
+	names := make([]string, len(modes))
+	for i, m := range modes {
+		names[i] = m.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// writeModes prints a table of the modes with their endpoint and whether they
+// need authentication.
+func writeModes(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODE\tAUTH\tENDPOINT\tDESCRIPTION")
+	for _, m := range modes {
+		auth := "no"
+		if m.auth {
+			auth = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.name, auth, m.endpoint, m.description)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,34 @@
+package crawl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestListModesPrintsEveryMode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeModes(&buf); err != nil {
+		t.Fatalf("writeModes: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(modes)+1 {
+		t.Fatalf("printed %d lines, want a header and %d modes:\n%s", len(lines), len(modes), buf.String())
+	}
+	for i, m := range modes {
+		fields := strings.Fields(lines[i+1])
+		auth := "no"
+		if m.auth {
+			auth = "yes"
+		}
+		if len(fields) < 3 || fields[0] != m.name || fields[1] != auth || fields[2] != m.endpoint {
+			t.Errorf("line for %s = %q, want its name, auth %s and endpoint %s", m.name, lines[i+1], auth, m.endpoint)
+		}
+		if found, ok := findMode(m.name); !ok || found.endpoint != m.endpoint {
+			t.Errorf("findMode(%s) = %+v, %v", m.name, found, ok)
+		}
+	}
+	if _, ok := findMode("blocks"); ok {
+		t.Error("findMode found an unregistered mode")
+	}
+}
@@ -0,0 +1,65 @@
+package crawl
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Relationships listed by the mutuals report.
+const (
+	relationMutual          = "mutual"             // follows the actor and is followed back
+	relationFan             = "fan"                // follows the actor only
+	relationNotFollowedBack = "not-following-back" // followed by the actor only
+)
+
+// mutualsReport cross-references the followers table with the following table
+// of -mode following, listing every account with its relationship to the
+// actor: mutual, fan or not-following-back.
+func mutualsReport(db *sql.DB) (reportTable, error) {
+	followers, err := quoteIdent(tableName)
+	if err != nil {
+		return reportTable{}, err
+	}
+	following, err := quoteIdent(followingTable)
+	if err != nil {
+		return reportTable{}, err
+	}
+
+	var exists int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`,
+		followingTable).Scan(&exists); err != nil {
+		return reportTable{}, fmt.Errorf("failed to look up following table: %w", err)
+	}
+	if exists == 0 {
+		return reportTable{}, fmt.Errorf("no %s table, run -mode following first", followingTable)
+	}
+
+	// SQLite has no FULL OUTER JOIN before 3.39, so the one-way follows of
+	// each side are selected separately.
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT ?, f.did, f.handle FROM %[1]s f JOIN %[2]s g ON g.did = f.did
+		UNION ALL
+		SELECT ?, f.did, f.handle FROM %[1]s f WHERE NOT EXISTS (SELECT 1 FROM %[2]s g WHERE g.did = f.did)
+		UNION ALL
+		SELECT ?, g.did, g.handle FROM %[2]s g WHERE NOT EXISTS (SELECT 1 FROM %[1]s f WHERE f.did = g.did)
+		ORDER BY 1, 2;
+	`, followers, following), relationMutual, relationFan, relationNotFollowedBack)
+	if err != nil {
+		return reportTable{}, fmt.Errorf("failed to query mutuals: %w", err)
+	}
+	defer rows.Close()
+
+	report := reportTable{columns: []string{"relationship", "did", "handle"}}
+	for rows.Next() {
+		var relation, did string
+		var handle sql.NullString
+		if err := rows.Scan(&relation, &did, &handle); err != nil {
+			return reportTable{}, fmt.Errorf("failed to scan mutual: %w", err)
+		}
+		report.rows = append(report.rows, []string{relation, did, handle.String})
+	}
+	if err := rows.Err(); err != nil {
+		return reportTable{}, fmt.Errorf("failed to iterate mutuals: %w", err)
+	}
+	return report, nil
+}
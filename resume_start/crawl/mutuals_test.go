@@ -0,0 +1,50 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMutualsReportSplitsRelationships(t *testing.T) {
+	db := openTestDB(t)
+	seedFollowers(t, db,
+		Follower{DID: "did:plc:both", Handle: "both.test"},
+		Follower{DID: "did:plc:fan", Handle: "fan.test"},
+	)
+	if _, err := mutualsReport(db); err == nil || !strings.Contains(err.Error(), "run -mode following first") {
+		t.Fatalf("mutualsReport without a following table = %v, want an error", err)
+	}
+
+	if err := initializeFollowing(db); err != nil {
+		t.Fatal(err)
+	}
+	follows := []Follower{{DID: "did:plc:both", Handle: "both.test"}, {DID: "did:plc:idol", Handle: "idol.test"}}
+	if err := saveFollowing(context.Background(), db, follows, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := mutualsReport(db)
+	if err != nil {
+		t.Fatalf("mutualsReport: %v", err)
+	}
+	want := [][]string{
+		{relationFan, "did:plc:fan", "fan.test"},
+		{relationMutual, "did:plc:both", "both.test"},
+		{relationNotFollowedBack, "did:plc:idol", "idol.test"},
+	}
+	if !reflect.DeepEqual(report.rows, want) {
+		t.Errorf("mutuals = %v, want %v", report.rows, want)
+	}
+
+	var buf bytes.Buffer
+	if err := writeReport(&buf, "csv", report); err != nil {
+		t.Fatalf("writeReport: %v", err)
+	}
+	if want := "relationship,did,handle\nfan,did:plc:fan,fan.test\nmutual,did:plc:both,both.test\nnot-following-back,did:plc:idol,idol.test\n"; buf.String() != want {
+		t.Errorf("CSV report = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,62 @@
+package crawl
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+)
+
+// newHandles returns the handles of followers first seen at or after since, in
+// the order they were fetched, or first seen on a -minimal table that doesn't
+// record fetch order. On a database that held no followers before
+// since, every follower is new, so none are returned.
+func newHandles(db *sql.DB, since time.Time) ([]string, error) {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := tableColumns(context.Background(), db, table)
+	if err != nil {
+		return nil, err
+	}
+	order := "first_seen, did"
+	if existing["fetch_seq"] {
+		order = "fetch_seq, did"
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT COALESCE(NULLIF(handle, ''), did) FROM %[1]s
+		WHERE first_seen >= ? AND EXISTS (SELECT 1 FROM %[1]s WHERE first_seen < ?)
+		ORDER BY %[2]s;
+	`, table, order), since.UTC(), since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query new followers: %w", err)
+	}
+	defer rows.Close()
+
+	var handles []string
+	for rows.Next() {
+		var handle string
+		if err := rows.Scan(&handle); err != nil {
+			return nil, fmt.Errorf("failed to scan new follower: %w", err)
+		}
+		handles = append(handles, handle)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate new followers: %w", err)
+	}
+	return handles, nil
+}
+
+// writeNewHandles writes one handle per line, for piping into a notifier.
+func writeNewHandles(w io.Writer, handles []string) error {
+	bw := bufio.NewWriter(w)
+	for _, handle := range handles {
+		fmt.Fprintln(bw, handle)
+	}
+	return bw.Flush()
+}
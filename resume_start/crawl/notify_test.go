@@ -0,0 +1,70 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestOnlyNewPrintsHandlesFirstSeenInRun(t *testing.T) {
+	db := openTestDB(t)
+	store := newSQLiteStore(db, tableName, followerColumns)
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 2)}}
+	ctx := context.Background()
+
+	first, err := newTestCrawler(t, store, api).Run(ctx)
+	if err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	if handles, err := newHandles(db, first.StartedAt); err != nil || len(handles) != 0 {
+		t.Errorf("first run's new handles = %q, %v; want none", handles, err)
+	}
+
+	api.pages = [][]Follower{append(testFollowers("a", 2), Follower{DID: "did:plc:new", Handle: "new.test"})}
+	second, err := newTestCrawler(t, store, api).Run(ctx)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	handles, err := newHandles(db, second.StartedAt)
+	if err != nil {
+		t.Fatalf("newHandles: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeNewHandles(&buf, handles); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "new.test\n" {
+		t.Errorf("printed %q, want only the new follower", got)
+	}
+}
+
+func TestOnlyNewOnMinimalTable(t *testing.T) {
+	// -minimal -only-new keeps first_seen but not fetch_seq.
+	columns := minimalColumns[:len(minimalColumns):len(minimalColumns)]
+	for _, col := range fullColumns {
+		if col.name == "first_seen" {
+			columns = append(columns, col)
+		}
+	}
+	useColumns(t, columns)
+	db := openTestDB(t)
+	store := newSQLiteStore(db, tableName, followerColumns)
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 2)}}
+	ctx := context.Background()
+
+	if _, err := newTestCrawler(t, store, api).Run(ctx); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	api.pages = [][]Follower{append(testFollowers("a", 2), Follower{DID: "did:plc:new", Handle: "new.test"})}
+	second, err := newTestCrawler(t, store, api).Run(ctx)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	handles, err := newHandles(db, second.StartedAt)
+	if err != nil {
+		t.Fatalf("newHandles: %v", err)
+	}
+	if len(handles) != 1 || handles[0] != "new.test" {
+		t.Errorf("new handles = %q, want only the new follower", handles)
+	}
+}
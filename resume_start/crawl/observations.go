@@ -0,0 +1,93 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// observationsTable receives every follower a -store-engine log crawl sees,
+// one row per sighting, instead of the followers table.
+const observationsTable = "follower_observations"
+
+// Storage engines selected with -store-engine.
+const (
+	// storeEngineTable upserts followers into the followers table, keeping
+	// their latest state.
+	storeEngineTable = "table"
+	// storeEngineLog appends every observation to the observations table,
+	// keeping each snapshot at the cost of space.
+	storeEngineLog = "log"
+)
+
+// observationColumns returns the observations schema for a followers schema:
+// the same profile fields, without a key or the bookkeeping an upsert needs,
+// and stamped with the time of the observation.
+func observationColumns(columns []column) []column {
+	var observed []column
+	for _, col := range columns {
+		switch col.name {
+		case "first_seen", "last_seen", "fetch_seq":
+			continue
+		}
+		col.key = false
+		observed = append(observed, col)
+	}
+	return append(observed, column{"observed_at", "DATETIME", false, func(Follower) interface{} { return time.Now().UTC() }, false})
+}
+
+// observationsDDL returns the statements creating the observations table for
+// the followers schema in use.
+func observationsDDL() (string, error) {
+	table, err := quoteIdent(observationsTable)
+	if err != nil {
+		return "", err
+	}
+	return createTableSQL(table, observationColumns(followerColumns)) +
+		fmt.Sprintf("\nCREATE INDEX IF NOT EXISTS follower_observations_did ON %s (did, observed_at);", table), nil
+}
+
+// logStore appends followers to the observations table rather than replacing
+// them, so every crawl leaves a full snapshot. A follower's stored state is
+// its latest observation.
+type logStore struct {
+	*sqliteStore
+}
+
+// newLogStore returns a store appending to the observations table of db, with
+// the profile fields of columns.
+func newLogStore(db *sql.DB, columns []column) *logStore {
+	return &logStore{newSQLiteStore(db, observationsTable, observationColumns(columns))}
+}
+
+func (s *logStore) Init(ctx context.Context) error {
+	ddl, err := observationsDDL()
+	if err != nil {
+		return err
+	}
+	for _, statement := range splitDDL(ddl) {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create %s table: %w", s.table, err)
+		}
+	}
+	return nil
+}
+
+// Count returns the number of distinct followers observed.
+func (s *logStore) Count(ctx context.Context) (int, error) {
+	table, err := quoteIdent(s.table)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	if err := s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(DISTINCT did) FROM %s;`, table)).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count followers: %w", err)
+	}
+	return n, nil
+}
+
+// Get returns the latest observation of did.
+func (s *logStore) Get(ctx context.Context, did string) (Follower, bool, error) {
+	return s.get(ctx, did, "ORDER BY observed_at DESC, rowid DESC LIMIT 1")
+}
@@ -0,0 +1,59 @@
+package crawl
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLogStoreAppendsEveryRun(t *testing.T) {
+	db := openTestDB(t)
+	store := newLogStore(db, followerColumns)
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 2), testFollowers("b", 1)}}
+	c := newTestCrawler(t, store, api)
+	ctx := context.Background()
+
+	if _, err := c.Run(ctx); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	api.mu.Lock()
+	api.pages = [][]Follower{{{DID: "did:plc:a0", Handle: "renamed.test"}, {DID: "did:plc:a1", Handle: "a1.test"}}, testFollowers("b", 1)}
+	api.mu.Unlock()
+	if _, err := c.Run(ctx); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+
+	rows, err := db.Query(`SELECT did, COUNT(*) FROM follower_observations GROUP BY did ORDER BY did;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	dids := 0
+	for rows.Next() {
+		var did string
+		var n int
+		if err := rows.Scan(&did, &n); err != nil {
+			t.Fatal(err)
+		}
+		dids++
+		if n != 2 {
+			t.Errorf("%s has %d observations, want one per run", did, n)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if dids != 3 {
+		t.Errorf("observed %d DIDs, want 3", dids)
+	}
+
+	if n, err := store.Count(ctx); err != nil || n != 3 {
+		t.Errorf("Count = %d, %v; want 3 distinct followers", n, err)
+	}
+	if f, ok, err := store.Get(ctx, "did:plc:a0"); err != nil || !ok || f.Handle != "renamed.test" {
+		t.Errorf("Get = %+v, %v, %v; want the latest observation", f, ok, err)
+	}
+	var upserted int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM followers;`).Scan(&upserted); err != nil || upserted != 0 {
+		t.Errorf("followers table has %d rows, %v; want it untouched", upserted, err)
+	}
+}
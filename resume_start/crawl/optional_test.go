@@ -0,0 +1,42 @@
+package crawl
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+)
+
+func TestMissingProfileFieldsStoredAsNull(t *testing.T) {
+	var followers []Follower
+	if err := json.Unmarshal([]byte(`[
+		{"did":"did:plc:a","handle":"a.test","description":"","avatar":null},
+		{"did":"did:plc:b","handle":"b.test","displayName":"","avatar":"https://cdn.example/b.jpg","description":"hi"}
+	]`), &followers); err != nil {
+		t.Fatal(err)
+	}
+	db := openTestDB(t)
+	seedFollowers(t, db, followers...)
+
+	for _, tc := range []struct {
+		did                              string
+		displayName, avatar, description sql.NullString
+	}{
+		// Absent and null fields are NULL, an empty string stays one.
+		{"did:plc:a", sql.NullString{}, sql.NullString{}, sql.NullString{Valid: true}},
+		{"did:plc:b", sql.NullString{Valid: true}, sql.NullString{String: "https://cdn.example/b.jpg", Valid: true}, sql.NullString{String: "hi", Valid: true}},
+	} {
+		var displayName, avatar, description sql.NullString
+		if err := db.QueryRow(`SELECT displayName, avatar, description FROM followers WHERE did = ?;`, tc.did).Scan(&displayName, &avatar, &description); err != nil {
+			t.Fatal(err)
+		}
+		if displayName != tc.displayName {
+			t.Errorf("displayName of %s = %+v, want %+v", tc.did, displayName, tc.displayName)
+		}
+		if (avatar.Valid != tc.avatar.Valid) || (avatar.Valid && avatar.String == "") {
+			t.Errorf("avatar of %s = %+v, want %+v", tc.did, avatar, tc.avatar)
+		}
+		if description != tc.description {
+			t.Errorf("description of %s = %+v, want %+v", tc.did, description, tc.description)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package crawl
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const pageHashesTable = "page_hashes"
+
+// initializePageHashes creates the table remembering which pages were saved.
+func initializePageHashes(db *sql.DB) error {
+	ddl, err := pageHashesDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create page hashes table: %w", err)
+	}
+	return nil
+}
+
+// pageHashesDDL returns the statements creating the page hashes table. Rows
+// are keyed by actor and the cursor that produced the page.
+func pageHashesDDL() (string, error) {
+	table, err := quoteIdent(pageHashesTable)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			actor TEXT,
+			cursor TEXT,
+			hash TEXT,
+			saved_at DATETIME,
+			PRIMARY KEY (actor, cursor)
+		);
+	`, table), nil
+}
+
+// pageHash returns a hash of the set of DIDs on a page, independent of order.
+func pageHash(followers []Follower) string {
+	dids := make([]string, len(followers))
+	for i, follower := range followers {
+		dids[i] = follower.DID
+	}
+	sort.Strings(dids)
+
+	h := sha256.New()
+	for _, did := range dids {
+		h.Write([]byte(did))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// pageSaved reports whether the page fetched at cursor for actor was already
+// saved with the same hash.
+func pageSaved(ctx context.Context, db *sql.DB, actor, cursor, hash string) (bool, error) {
+	table, err := quoteIdent(pageHashesTable)
+	if err != nil {
+		return false, err
+	}
+
+	var stored string
+	err = db.QueryRowContext(ctx, fmt.Sprintf(`SELECT hash FROM %s WHERE actor = ? AND cursor = ?;`, table), actor, cursor).Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up page hash: %w", err)
+	}
+	return stored == hash, nil
+}
+
+// recordPageHash remembers that the page fetched at cursor for actor was saved.
+func recordPageHash(ctx context.Context, db *sql.DB, actor, cursor, hash string) error {
+	table, err := quoteIdent(pageHashesTable)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT OR REPLACE INTO %s (actor, cursor, hash, saved_at) VALUES (?, ?, ?, ?);
+	`, table), actor, cursor, hash, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record page hash: %w", err)
+	}
+	return nil
+}
+
+// clearPageHashes forgets the saved pages of actor once a crawl has completed
+// and there is nothing left to resume.
+func clearPageHashes(ctx context.Context, db *sql.DB, actor string) error {
+	table, err := quoteIdent(pageHashesTable)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE actor = ?;`, table), actor); err != nil {
+		return fmt.Errorf("failed to clear page hashes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package crawl
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfilesWrittenAfterCrawl(t *testing.T) {
+	dir := t.TempDir()
+	cpuPath, memPath := filepath.Join(dir, "cpu.pprof"), filepath.Join(dir, "mem.pprof")
+	stop, err := startProfiling(cpuPath, memPath)
+	if err != nil {
+		t.Fatalf("startProfiling: %v", err)
+	}
+
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 50), testFollowers("b", 50)}}
+	if _, err := newTestCrawler(t, newMemoryStore(), api).Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	stop()
+
+	for _, path := range []string{cpuPath, memPath} {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("profile %s is missing or empty: %v", filepath.Base(path), err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package crawl
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"sync"
+)
+
+// profileCache memoizes getProfile results for the lifetime of the process, so
+// the preflight, reconciliation and other passes of one invocation fetch each
+// actor's profile only once.
+type profileCache struct {
+	mu       sync.Mutex
+	profiles map[string]Profile // keyed by the requested actor and by its DID
+}
+
+func (c *profileCache) get(actor string) (Profile, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	profile, ok := c.profiles[actor]
+	return profile, ok
+}
+
+func (c *profileCache) put(actor string, profile Profile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.profiles == nil {
+		c.profiles = make(map[string]Profile)
+	}
+	c.profiles[actor] = profile
+	if profile.DID != "" {
+		c.profiles[profile.DID] = profile
+	}
+}
+
+// getProfile returns the profile of actor, a DID or handle, from the cache or
+// from app.bsky.actor.getProfile.
+func (c *apiClient) getProfile(ctx context.Context, actor string) (Profile, error) {
+	if profile, ok := c.profiles.get(actor); ok {
+		return profile, nil
+	}
+
+	var profile Profile
+	if err := c.getJSON(ctx, c.xrpcURL("app.bsky.actor.getProfile", url.Values{"actor": {actor}}), &profile); err != nil {
+		return Profile{}, err
+	}
+	c.profiles.put(actor, profile)
+	return profile, nil
+}
+
+// preflightProfile logs who is about to be crawled and how many followers the
+// profile reports. A failure is logged and otherwise ignored; the crawl itself
+// will surface any real problem.
+func preflightProfile(ctx context.Context, client *apiClient, actor string) {
+	profile, err := client.getProfile(ctx, actor)
+	if err != nil {
+		log.Printf("Profile preflight for %s failed: %v", actor, err)
+		return
+	}
+	log.Printf("Crawling followers of %s (%s), profile reports %d followers.\n", profile.Handle, profile.DID, profile.FollowersCount)
+}
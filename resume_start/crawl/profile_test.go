@@ -0,0 +1,56 @@
+package crawl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetProfileFetchesOncePerRun(t *testing.T) {
+	var requests atomic.Int32
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		json.NewEncoder(w).Encode(Profile{DID: "did:plc:alice", Handle: "alice.test", FollowersCount: 42})
+	}))
+	ctx := context.Background()
+
+	preflightProfile(ctx, client, "alice.test")
+	profile, err := client.getProfile(ctx, "alice.test")
+	if err != nil {
+		t.Fatalf("getProfile: %v", err)
+	}
+	if profile.FollowersCount != 42 {
+		t.Errorf("FollowersCount = %d, want 42", profile.FollowersCount)
+	}
+	// The DID the handle resolved to is answered from the cache as well.
+	if _, err := client.getProfile(ctx, "did:plc:alice"); err != nil {
+		t.Fatalf("getProfile by DID: %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("profile fetched %d times, want once", n)
+	}
+}
+
+func TestGetProfileDoesNotCacheErrors(t *testing.T) {
+	var requests atomic.Int32
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			http.Error(w, `{"error":"InvalidRequest","message":"Profile not found"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(Profile{DID: "did:plc:alice"})
+	}))
+	ctx := context.Background()
+
+	if _, err := client.getProfile(ctx, "did:plc:alice"); err == nil {
+		t.Fatal("getProfile succeeded on a 400")
+	}
+	if _, err := client.getProfile(ctx, "did:plc:alice"); err != nil {
+		t.Fatalf("getProfile after a failure: %v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("profile fetched %d times, want the failure retried", n)
+	}
+}
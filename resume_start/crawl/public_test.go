@@ -0,0 +1,76 @@
+package crawl_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/baditaflorin/bluesky/resume_start/crawl"
+)
+
+// TestCrawlerFromAnotherPackage drives a Crawler with nothing but the exported
+// API, as a program embedding it would.
+func TestCrawlerFromAnotherPackage(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/xrpc/app.bsky.graph.getFollowers" || r.URL.Query().Get("actor") != "did:plc:subject" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(crawl.APIResponse{Followers: []crawl.Follower{
+			{DID: "did:plc:a", Handle: "a.test"},
+			{DID: "did:plc:b", Handle: "b.test"},
+		}})
+	}))
+	defer api.Close()
+
+	for _, tc := range []struct {
+		name  string
+		store func(t *testing.T) crawl.Store
+	}{
+		{"memory", func(*testing.T) crawl.Store { return crawl.NewMemoryStore() }},
+		{"sqlite", func(t *testing.T) crawl.Store {
+			store, err := crawl.OpenSQLiteStore(filepath.Join(t.TempDir(), "followers.db"))
+			if err != nil {
+				t.Fatalf("OpenSQLiteStore: %v", err)
+			}
+			return store
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := tc.store(t)
+			defer store.Close()
+			var logs bytes.Buffer
+			c, err := crawl.NewCrawler(context.Background(),
+				crawl.WithActor("did:plc:subject"),
+				crawl.WithStore(store),
+				crawl.WithClient(api.Client()),
+				crawl.WithServiceURL(api.URL),
+				crawl.WithLogger(log.New(&logs, "", 0)),
+				crawl.WithCommitBatch(10),
+			)
+			if err != nil {
+				t.Fatalf("NewCrawler: %v", err)
+			}
+
+			stats, err := c.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if stats.Status != "completed" || stats.FollowersSeen != 2 {
+				t.Errorf("stats = %+v, want a completed run seeing 2 followers", stats)
+			}
+			if n, err := store.Count(context.Background()); err != nil || n != 2 {
+				t.Errorf("Count = %d, %v; want 2", n, err)
+			}
+			if !strings.Contains(logs.String(), "actor=did:plc:subject") {
+				t.Errorf("crawl didn't log to the given logger:\n%s", logs.String())
+			}
+		})
+	}
+}
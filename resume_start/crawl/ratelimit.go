@@ -0,0 +1,101 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces out requests so that, across every goroutine sharing it, no
+// more than the configured number start per second.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// newRateLimiter returns a limiter allowing rps requests per second, or nil
+// (unlimited) when rps is not positive.
+func newRateLimiter(rps float64) *rateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rps)}
+}
+
+// wait blocks until the caller may make its next request. A nil limiter never blocks.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleepContext(ctx, time.Until(slot))
+}
+
+// maxRetryAfter caps how long a single Retry-After holds requests back.
+const maxRetryAfter = 10 * time.Minute
+
+// pacer holds back every request until the time the API last asked clients to
+// retry after. The limit is the API's, not the failing request's, so the wait
+// carries over to whatever request comes next, on this page or a later one.
+type pacer struct {
+	mu        sync.Mutex
+	notBefore time.Time
+}
+
+// holdUntil keeps requests from starting before t, unless they are already
+// held back longer.
+func (p *pacer) holdUntil(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t.After(p.notBefore) {
+		p.notBefore = t
+	}
+}
+
+// delay returns how long a request starting at now must wait.
+func (p *pacer) delay(now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.notBefore.After(now) {
+		return p.notBefore.Sub(now)
+	}
+	return 0
+}
+
+// parseRetryAfter parses a Retry-After value, in seconds or as an HTTP date,
+// into how long after now to wait, capped at maxRetryAfter.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(value); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		d = t.Sub(now)
+	} else {
+		return 0, false
+	}
+	if d < 0 {
+		d = 0
+	}
+	if d > maxRetryAfter {
+		d = maxRetryAfter
+	}
+	return d, true
+}
@@ -0,0 +1,84 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// ratiosLimit is the number of accounts listed at each end of the ratios report.
+const ratiosLimit = 50
+
+// ratioTails are the two ends of the ratios report: accounts following the
+// most accounts per follower of their own, a common sign of a bot, and those
+// followed by the most accounts per account they follow, which may have
+// bought their followers. Ties in the low tail rank the larger audience first.
+var ratioTails = []struct{ name, order string }{
+	{"high", "ratio DESC, did"},
+	{"low", "ratio ASC, followersCount DESC, did"},
+}
+
+// ratiosReport lists the enriched followers with the most extreme ratios of
+// accounts followed to followers at both ends. Accounts without followers
+// count as having one, so they still rank by how many they follow. An account
+// is listed once, in the high tail when the tails overlap. It is empty until
+// -enrich has stored follow counts.
+func ratiosReport(db *sql.DB) (reportTable, error) {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return reportTable{}, err
+	}
+	report := reportTable{columns: []string{"did", "handle", "follows", "followers", "posts", "ratio", "tail"}}
+
+	existing, err := tableColumns(context.Background(), db, table)
+	if err != nil {
+		return reportTable{}, err
+	}
+	if !existing["followsCount"] {
+		return report, nil
+	}
+
+	listed := make(map[string]bool)
+	for _, tail := range ratioTails {
+		if err := appendRatios(db, table, tail.name, tail.order, listed, &report); err != nil {
+			return reportTable{}, err
+		}
+	}
+	return report, nil
+}
+
+// appendRatios adds the first ratiosLimit accounts in order to report as the
+// named tail, skipping those already listed.
+func appendRatios(db *sql.DB, table, tail, order string, listed map[string]bool, report *reportTable) error {
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT did, handle, followsCount, followersCount, postsCount,
+			CAST(followsCount AS REAL) / MAX(followersCount, 1) AS ratio
+		FROM %s WHERE followsCount IS NOT NULL AND followersCount IS NOT NULL
+		ORDER BY %s LIMIT ?;
+	`, table, order), ratiosLimit)
+	if err != nil {
+		return fmt.Errorf("failed to query %s ratios: %w", tail, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var did, handle string
+		var follows, followers int
+		var posts sql.NullInt64
+		var ratio float64
+		if err := rows.Scan(&did, &handle, &follows, &followers, &posts, &ratio); err != nil {
+			return fmt.Errorf("failed to scan ratio: %w", err)
+		}
+		if listed[did] {
+			continue
+		}
+		listed[did] = true
+		report.rows = append(report.rows, []string{did, handle, strconv.Itoa(follows), strconv.Itoa(followers),
+			strconv.FormatInt(posts.Int64, 10), strconv.FormatFloat(ratio, 'f', 2, 64), tail})
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate %s ratios: %w", tail, err)
+	}
+	return nil
+}
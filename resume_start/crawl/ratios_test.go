@@ -0,0 +1,90 @@
+package crawl
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRatiosReportListsBothTails(t *testing.T) {
+	db := openTestDB(t)
+	if err := ensureColumns(db, tableName, enrichColumns); err != nil {
+		t.Fatal(err)
+	}
+	followers := testFollowers("r", 2*ratiosLimit+20)
+	followers = append(followers, Follower{DID: "did:plc:big", Handle: "big.test"}, Follower{DID: "did:plc:small", Handle: "small.test"})
+	seedFollowers(t, db, followers...)
+	setCounts := func(did string, follows, followers int) {
+		t.Helper()
+		if _, err := db.Exec(`UPDATE followers SET followsCount = ?, followersCount = ?, postsCount = 3 WHERE did = ?;`, follows, followers, did); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The ratio grows with the index.
+	for i, f := range followers[:len(followers)-2] {
+		setCounts(f.DID, i, 1000+i)
+	}
+	setCounts("did:plc:big", 0, 500)
+	setCounts("did:plc:small", 0, 5)
+
+	report, err := ratiosReport(db)
+	if err != nil {
+		t.Fatalf("ratiosReport: %v", err)
+	}
+	if len(report.rows) != 2*ratiosLimit {
+		t.Fatalf("listed %d accounts, want %d in each tail", len(report.rows), ratiosLimit)
+	}
+	listed := make(map[string]bool)
+	var high, low []string
+	for _, row := range report.rows {
+		if listed[row[0]] {
+			t.Errorf("%s listed twice", row[0])
+		}
+		listed[row[0]] = true
+		switch row[6] {
+		case "high":
+			high = append(high, row[0])
+		case "low":
+			low = append(low, row[0])
+		}
+	}
+	if len(high) != ratiosLimit || high[0] != followers[len(followers)-3].DID || high[1] != followers[len(followers)-4].DID {
+		t.Errorf("high tail starts %v, want the largest ratios first", high[:2])
+	}
+	if want := []string{followers[0].DID, "did:plc:big", "did:plc:small", followers[1].DID}; len(low) != ratiosLimit || !reflect.DeepEqual(low[:4], want) {
+		t.Errorf("low tail starts %v, want %v", low[:4], want)
+	}
+	top := 2*ratiosLimit + 19
+	if first := report.rows[0]; first[2] != strconv.Itoa(top) || first[3] != strconv.Itoa(1000+top) || first[4] != "3" || first[5] != "0.11" {
+		t.Errorf("first row = %v, want the stored counts and their ratio", first)
+	}
+}
+
+func TestEnrichRefetchesRowsWithoutFollowsCount(t *testing.T) {
+	db := openTestDB(t)
+	seedFollowers(t, db, testFollowers("a", 3)...)
+	srv := &profilesServer{}
+	client, _ := newTestClient(t, srv)
+	ctx := context.Background()
+
+	if err := enrichFollowers(ctx, db, client, 0, time.Hour); err != nil {
+		t.Fatalf("first enrich: %v", err)
+	}
+	var follows int
+	if err := db.QueryRow(`SELECT followsCount FROM followers WHERE did = 'did:plc:a1';`).Scan(&follows); err != nil || follows != 1 {
+		t.Fatalf("stored followsCount = %d, %v; want 1", follows, err)
+	}
+
+	// A row enriched before follow counts were stored.
+	if _, err := db.Exec(`UPDATE followers SET followsCount = NULL WHERE did = 'did:plc:a1';`); err != nil {
+		t.Fatal(err)
+	}
+	if err := enrichFollowers(ctx, db, client, 0, time.Hour); err != nil {
+		t.Fatalf("second enrich: %v", err)
+	}
+	if len(srv.calls) != 2 || !reflect.DeepEqual(srv.calls[1], []string{"did:plc:a1"}) {
+		t.Errorf("getProfiles calls = %v, want the row without a follow count refetched", srv.calls)
+	}
+}
@@ -0,0 +1,136 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+)
+
+// reconcileEntry identifies one follower in a reconciliation report.
+type reconcileEntry struct {
+	DID    string `json:"did"`
+	Handle string `json:"handle"`
+}
+
+// reconcileReport lists where the stored followers and the API disagree.
+type reconcileReport struct {
+	Stored         int              `json:"stored"`
+	Fetched        int              `json:"fetched"`
+	Reported       int              `json:"reported,omitempty"` // followersCount from the profile, if available
+	MissingFromAPI []reconcileEntry `json:"missing_from_api"`
+	MissingFromDB  []reconcileEntry `json:"missing_from_db"`
+	Applied        bool             `json:"applied"`
+}
+
+// fetchAllFollowers pages through the full followers list without saving anything.
+func fetchAllFollowers(ctx context.Context, client *apiClient, actor string) ([]Follower, error) {
+	var all []Follower
+	cursor := ""
+	for {
+		followers, newCursor, err := fetchFollowers(ctx, client, actor, cursor)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, followers...)
+		if newCursor == "" {
+			return all, nil
+		}
+		cursor = newCursor
+	}
+}
+
+// reconcile recrawls the followers list and reports stored rows the API no longer
+// returns (likely unfollows or deleted accounts) and returned followers missing
+// from the database. The database is only changed when apply is set.
+func reconcile(ctx context.Context, db *sql.DB, client *apiClient, actor string, apply bool, w io.Writer) error {
+	stored, err := loadHandles(db)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Recrawling followers for reconciliation...")
+	fetched, err := fetchAllFollowers(ctx, client, actor)
+	if err != nil {
+		return fmt.Errorf("failed to recrawl followers: %w", err)
+	}
+
+	report := reconcileReport{Stored: len(stored), Fetched: len(fetched)}
+	if profile, err := client.getProfile(ctx, actor); err != nil {
+		log.Printf("Failed to fetch profile of %s: %v", actor, err)
+	} else {
+		report.Reported = profile.FollowersCount
+	}
+	fetchedDIDs := make(map[string]bool, len(fetched))
+	var missing []Follower
+	for _, follower := range fetched {
+		fetchedDIDs[follower.DID] = true
+		if _, ok := stored[follower.DID]; !ok {
+			missing = append(missing, follower)
+			report.MissingFromDB = append(report.MissingFromDB, reconcileEntry{DID: follower.DID, Handle: follower.Handle})
+		}
+	}
+	for did, handle := range stored {
+		if !fetchedDIDs[did] {
+			report.MissingFromAPI = append(report.MissingFromAPI, reconcileEntry{DID: did, Handle: handle})
+		}
+	}
+	sort.Slice(report.MissingFromAPI, func(i, j int) bool { return report.MissingFromAPI[i].DID < report.MissingFromAPI[j].DID })
+	sort.Slice(report.MissingFromDB, func(i, j int) bool { return report.MissingFromDB[i].DID < report.MissingFromDB[j].DID })
+
+	if apply {
+		if err := applyReconciliation(ctx, db, report.MissingFromAPI, missing); err != nil {
+			return err
+		}
+		report.Applied = true
+	}
+
+	log.Printf("Reconciliation: %d stored, %d fetched, %d missing from API, %d missing from DB.\n",
+		report.Stored, report.Fetched, len(report.MissingFromAPI), len(report.MissingFromDB))
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// applyReconciliation deletes stale rows and inserts the missing followers in
+// one transaction, so a failure leaves the table as it was.
+func applyReconciliation(ctx context.Context, db *sql.DB, stale []reconcileEntry, missing []Follower) error {
+	followersTable, err := quoteIdent(tableName)
+	if err != nil {
+		return err
+	}
+	labelTable, err := quoteIdent(labelsTable)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, entry := range stale {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE did = ?;`, followersTable), entry.DID); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", entry.DID, err)
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE did = ?;`, labelTable), entry.DID); err != nil {
+			return fmt.Errorf("failed to delete labels of %s: %w", entry.DID, err)
+		}
+	}
+	if _, err := insertFollowers(ctx, tx, tableName, followerColumns, missing, tolerantSave); err != nil {
+		return fmt.Errorf("failed to insert missing followers: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	log.Printf("Deleted %d followers no longer returned by the API.\n", len(stale))
+	if len(missing) > 0 {
+		log.Printf("Inserted %d followers missing from the database.\n", len(missing))
+	}
+	return nil
+}
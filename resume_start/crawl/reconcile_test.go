@@ -0,0 +1,84 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestReconcileReportsDivergence(t *testing.T) {
+	db := openTestDB(t)
+	seedFollowers(t, db,
+		Follower{DID: "did:plc:a", Handle: "a.test"},
+		Follower{DID: "did:plc:b", Handle: "b.test"},
+		Follower{DID: "did:plc:c", Handle: "c.test"},
+	)
+	mux := http.NewServeMux()
+	mux.Handle("/xrpc/app.bsky.graph.getFollowers", &followersAPI{pages: [][]Follower{
+		{{DID: "did:plc:b", Handle: "b.test"}, {DID: "did:plc:c", Handle: "c.test"}},
+		{{DID: "did:plc:d", Handle: "d.test"}},
+	}})
+	mux.HandleFunc("/xrpc/app.bsky.actor.getProfile", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Profile{DID: "did:plc:subject", FollowersCount: 3})
+	})
+	client, _ := newTestClient(t, mux)
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	if err := reconcile(ctx, db, client, "did:plc:subject", false, &out); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	var report reconcileReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("report is not JSON: %v\n%s", err, out.String())
+	}
+	want := reconcileReport{
+		Stored:         3,
+		Fetched:        3,
+		Reported:       3,
+		MissingFromAPI: []reconcileEntry{{DID: "did:plc:a", Handle: "a.test"}},
+		MissingFromDB:  []reconcileEntry{{DID: "did:plc:d", Handle: "d.test"}},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+	if handles, err := loadHandles(db); err != nil || len(handles) != 3 || handles["did:plc:a"] == "" {
+		t.Fatalf("stored followers after a dry run = %v, %v; want them unchanged", handles, err)
+	}
+
+	out.Reset()
+	if err := reconcile(ctx, db, client, "did:plc:subject", true, &out); err != nil {
+		t.Fatalf("reconcile with -apply: %v", err)
+	}
+	handles, err := loadHandles(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHandles := map[string]string{"did:plc:b": "b.test", "did:plc:c": "c.test", "did:plc:d": "d.test"}
+	if !reflect.DeepEqual(handles, wantHandles) {
+		t.Errorf("stored followers after -apply = %v, want %v", handles, wantHandles)
+	}
+}
+
+func TestReconcileApplyIsAtomic(t *testing.T) {
+	db := openTestDB(t)
+	seedFollowers(t, db, Follower{DID: "did:plc:a", Handle: "a.test"})
+	if _, err := db.Exec(`
+		CREATE TRIGGER reject_d BEFORE INSERT ON followers WHEN NEW.did = 'did:plc:d'
+		BEGIN SELECT RAISE(ABORT, 'rejected'); END;
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := []reconcileEntry{{DID: "did:plc:a", Handle: "a.test"}}
+	missing := []Follower{{DID: "did:plc:d", Handle: "d.test"}}
+	if err := applyReconciliation(context.Background(), db, stale, missing); err == nil {
+		t.Fatal("applyReconciliation succeeded though an insert failed")
+	}
+	if handles, err := loadHandles(db); err != nil || handles["did:plc:a"] != "a.test" {
+		t.Errorf("stored followers = %v, %v; want the stale row kept when the inserts fail", handles, err)
+	}
+}
@@ -0,0 +1,188 @@
+package crawl
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// isCorruptDB reports whether err is SQLite complaining that the database file
+// is malformed or not a database at all.
+func isCorruptDB(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrCorrupt || sqliteErr.Code == sqlite3.ErrNotADB
+}
+
+// recoverDB salvages what it can from a corrupt database. It logs the result of
+// PRAGMA integrity_check, copies every readable row of every table into a fresh
+// file, then moves the corrupt file aside as <dbFile>.corrupt-<unix time> and
+// puts the fresh file in its place.
+func recoverDB(dbFile string) error {
+	src, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		return fmt.Errorf("failed to open corrupt database: %w", err)
+	}
+	defer src.Close()
+
+	logIntegrityCheck(src)
+
+	recovered := dbFile + ".recovered"
+	if err := os.Remove(recovered); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale %s: %w", recovered, err)
+	}
+	dst, err := sql.Open("sqlite3", recovered)
+	if err != nil {
+		return fmt.Errorf("failed to create recovered database: %w", err)
+	}
+
+	total, err := copyTables(src, dst)
+	if closeErr := dst.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close recovered database: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(recovered)
+		return err
+	}
+	src.Close()
+
+	aside := fmt.Sprintf("%s.corrupt-%d", dbFile, time.Now().Unix())
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Rename(dbFile+suffix, aside+suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to move corrupt database aside: %w", err)
+		}
+	}
+	if err := os.Rename(recovered, dbFile); err != nil {
+		return fmt.Errorf("failed to replace database with recovered copy: %w", err)
+	}
+	log.Printf("Recovered %d rows into %s; the corrupt file was kept as %s.\n", total, dbFile, aside)
+	return nil
+}
+
+// logIntegrityCheck logs each problem PRAGMA integrity_check reports. A check
+// that cannot run at all is logged and otherwise ignored.
+func logIntegrityCheck(db *sql.DB) {
+	rows, err := db.Query(`PRAGMA integrity_check;`)
+	if err != nil {
+		log.Printf("Integrity check failed to run: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			log.Printf("Integrity check failed: %v", err)
+			return
+		}
+		log.Printf("Integrity check: %s\n", line)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Integrity check stopped early: %v", err)
+	}
+}
+
+// copyTables recreates every user table of src in dst and copies the rows it
+// can read. A table that fails part way keeps the rows copied before the
+// failure. It returns the number of rows copied.
+func copyTables(src, dst *sql.DB) (int, error) {
+	type table struct{ name, ddl string }
+	rows, err := src.Query(`SELECT name, sql FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%';`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema, nothing is salvageable: %w", err)
+	}
+	var tables []table
+	for rows.Next() {
+		var t table
+		if err := rows.Scan(&t.name, &t.ddl); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read schema: %w", err)
+		}
+		tables = append(tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Schema listing stopped early, some tables may be missing: %v", err)
+	}
+	rows.Close()
+
+	total := 0
+	for _, t := range tables {
+		if _, err := dst.Exec(t.ddl); err != nil {
+			return total, fmt.Errorf("failed to recreate table %s: %w", t.name, err)
+		}
+		n, err := copyRows(src, dst, t.name)
+		total += n
+		if err != nil {
+			log.Printf("Table %s: salvaged %d rows before error: %v", t.name, n, err)
+			continue
+		}
+		log.Printf("Table %s: salvaged %d rows.\n", t.name, n)
+	}
+	return total, nil
+}
+
+// copyRows copies rows of one table from src to dst in a single transaction,
+// committing whatever was read before src returned an error.
+func copyRows(src, dst *sql.DB, name string) (int, error) {
+	quoted, err := quoteIdent(name)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := src.Query(fmt.Sprintf(`SELECT * FROM %s;`, quoted))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ")
+
+	tx, err := dst.Begin()
+	if err != nil {
+		return 0, err
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf(`INSERT OR IGNORE INTO %s VALUES (%s);`, quoted, placeholders))
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	defer stmt.Close()
+
+	n := 0
+	values := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	var readErr error
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			readErr = err
+			break
+		}
+		if _, err := stmt.Exec(values...); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		n++
+	}
+	if readErr == nil {
+		readErr = rows.Err()
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return n, readErr
+}
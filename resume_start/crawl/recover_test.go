@@ -0,0 +1,74 @@
+package crawl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCorruptDatabaseDetected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), dbFile)
+	if err := os.WriteFile(path, []byte(strings.Repeat("not a database ", 512)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := initializeDB(path)
+	if err == nil {
+		db.Close()
+		t.Fatal("initializeDB opened a file that isn't a database")
+	}
+	if !isCorruptDB(err) {
+		t.Fatalf("initializeDB = %v, want it recognized as corruption", err)
+	}
+}
+
+func TestRecoverDBSalvagesReadableRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), dbFile)
+	db, err := initializeDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	followers := make([]Follower, 400)
+	for i := range followers {
+		followers[i] = Follower{DID: fmt.Sprintf("did:plc:%04d", i), Handle: fmt.Sprintf("u%d.test", i), Description: strings.Repeat("x", 200)}
+	}
+	seedFollowers(t, db, followers...)
+	closeDB(db)
+
+	// Overwrite the last pages of the file, where the most recent rows live.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte(strings.Repeat("\xff", 8192)), info.Size()-8192); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if err := recoverDB(path); err != nil {
+		t.Fatalf("recoverDB: %v", err)
+	}
+	aside, _ := filepath.Glob(path + ".corrupt-*")
+	if len(aside) != 1 {
+		t.Errorf("corrupt file kept as %v, want one copy", aside)
+	}
+
+	db, err = initializeDB(path)
+	if err != nil {
+		t.Fatalf("opening the recovered database: %v", err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM followers;`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n == 0 || n > len(followers) {
+		t.Errorf("recovered %d followers, want some of the %d", n, len(followers))
+	}
+	t.Logf("recovered %d of %d followers", n, len(followers))
+}
@@ -0,0 +1,167 @@
+package crawl
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// reportTable is the result of a read-only report: a header and rows of cells.
+type reportTable struct {
+	columns []string
+	rows    [][]string
+}
+
+// reports maps each -report name to the query producing it.
+var reports = map[string]func(db *sql.DB) (reportTable, error){
+	"growth":          growthReport,
+	"enrich-failures": enrichFailuresReport,
+	"anomalies":       anomaliesReport,
+	"ratios":          ratiosReport,
+	"mutuals":         mutualsReport,
+}
+
+// reportNames returns the available report names, sorted.
+func reportNames() []string {
+	names := make([]string, 0, len(reports))
+	for name := range reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runReport builds the named report and writes it to stdout in format.
+func runReport(db *sql.DB, name, format string) error {
+	build, ok := reports[name]
+	if !ok {
+		return fmt.Errorf("unknown report %q, expected one of %v", name, reportNames())
+	}
+	table, err := build(db)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if err := writeReport(w, format, table); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// writeReport writes table as aligned text, a JSON array of objects, or CSV.
+func writeReport(w io.Writer, format string, table reportTable) error {
+	switch format {
+	case "text":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(table.columns, "\t"))
+		for _, row := range table.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case "json":
+		objects := make([]map[string]string, 0, len(table.rows))
+		for _, row := range table.rows {
+			object := make(map[string]string, len(row))
+			for i, cell := range row {
+				object[table.columns[i]] = cell
+			}
+			objects = append(objects, object)
+		}
+		return json.NewEncoder(w).Encode(objects)
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(table.columns); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(table.rows); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+}
+
+// growthReport counts new followers per day by first_seen. Followers stored
+// before first_seen was recorded have none and are left out.
+func growthReport(db *sql.DB) (reportTable, error) {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return reportTable{}, err
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT substr(first_seen, 1, 10) AS day, COUNT(*)
+		FROM %s WHERE first_seen IS NOT NULL
+		GROUP BY day ORDER BY day;
+	`, table))
+	if err != nil {
+		return reportTable{}, fmt.Errorf("failed to query growth: %w", err)
+	}
+	defer rows.Close()
+
+	report := reportTable{columns: []string{"date", "new_followers"}}
+	for rows.Next() {
+		var day string
+		var count int
+		if err := rows.Scan(&day, &count); err != nil {
+			return reportTable{}, fmt.Errorf("failed to scan growth: %w", err)
+		}
+		report.rows = append(report.rows, []string{day, strconv.Itoa(count)})
+	}
+	if err := rows.Err(); err != nil {
+		return reportTable{}, fmt.Errorf("failed to iterate growth: %w", err)
+	}
+	return report, nil
+}
+
+// enrichFailuresReport lists the followers the enrich pass could not fetch a
+// profile for, most recent attempt first. It is empty if enrichment never ran.
+func enrichFailuresReport(db *sql.DB) (reportTable, error) {
+	table, err := quoteIdent(enrichFailuresTable)
+	if err != nil {
+		return reportTable{}, err
+	}
+	report := reportTable{columns: []string{"did", "reason", "attempted_at"}}
+
+	var exists int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`,
+		enrichFailuresTable).Scan(&exists); err != nil {
+		return reportTable{}, fmt.Errorf("failed to look up enrich failures table: %w", err)
+	}
+	if exists == 0 {
+		return report, nil
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`SELECT did, reason, attempted_at FROM %s ORDER BY attempted_at DESC, did;`, table))
+	if err != nil {
+		return reportTable{}, fmt.Errorf("failed to query enrich failures: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var did, reason string
+		var attemptedAt time.Time
+		if err := rows.Scan(&did, &reason, &attemptedAt); err != nil {
+			return reportTable{}, fmt.Errorf("failed to scan enrich failure: %w", err)
+		}
+		report.rows = append(report.rows, []string{did, reason, attemptedAt.UTC().Format(time.RFC3339)})
+	}
+	if err := rows.Err(); err != nil {
+		return reportTable{}, fmt.Errorf("failed to iterate enrich failures: %w", err)
+	}
+	return report, nil
+}
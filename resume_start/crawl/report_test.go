@@ -0,0 +1,62 @@
+package crawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGrowthReportCountsPerDay(t *testing.T) {
+	db := openTestDB(t)
+	seedFollowers(t, db, testFollowers("", 6)...)
+	day := func(s string) time.Time {
+		d, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	for did, firstSeen := range map[string]interface{}{
+		"did:plc:0": day("2024-03-01T08:00:00Z"),
+		"did:plc:1": day("2024-03-01T23:59:59Z"),
+		"did:plc:2": day("2024-03-02T00:00:00Z"),
+		"did:plc:3": day("2024-03-04T12:00:00Z"),
+		"did:plc:4": day("2024-03-04T13:00:00Z"),
+		"did:plc:5": nil, // stored before first_seen was recorded
+	} {
+		if _, err := db.Exec(`UPDATE followers SET first_seen = ? WHERE did = ?;`, firstSeen, did); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := growthReport(db)
+	if err != nil {
+		t.Fatalf("growthReport: %v", err)
+	}
+	want := [][]string{{"2024-03-01", "2"}, {"2024-03-02", "1"}, {"2024-03-04", "2"}}
+	if !reflect.DeepEqual(report.rows, want) {
+		t.Errorf("growth = %v, want %v", report.rows, want)
+	}
+
+	var buf bytes.Buffer
+	if err := writeReport(&buf, "json", report); err != nil {
+		t.Fatalf("writeReport: %v", err)
+	}
+	var objects []map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &objects); err != nil {
+		t.Fatalf("JSON report: %v\n%s", err, buf.String())
+	}
+	if len(objects) != 3 || objects[0]["date"] != "2024-03-01" || objects[0]["new_followers"] != "2" {
+		t.Errorf("JSON report = %v", objects)
+	}
+
+	buf.Reset()
+	if err := writeReport(&buf, "csv", report); err != nil {
+		t.Fatalf("writeReport: %v", err)
+	}
+	if want := "date,new_followers\n2024-03-01,2\n2024-03-02,1\n2024-03-04,2\n"; buf.String() != want {
+		t.Errorf("CSV report = %q, want %q", buf.String(), want)
+	}
+}
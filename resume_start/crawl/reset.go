@@ -0,0 +1,87 @@
+package crawl
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// resetTables are emptied by -reset: the followers table and the state of the
+// crawls into it. The run history, the identities cache and the tables of
+// other modes are kept.
+var resetTables = []string{
+	tableName, labelsTable, pageHashesTable, cursorLogTable, metadataTable,
+	invalidFollowersTable, enrichFailuresTable,
+}
+
+// confirmReset asks on w whether to go ahead with a reset and reports whether
+// the answer read from r was "yes".
+func confirmReset(r io.Reader, w io.Writer) bool {
+	fmt.Fprintf(w, "This deletes every row of %s. Type yes to continue: ", strings.Join(resetTables, ", "))
+	answer, _ := bufio.NewReader(r).ReadString('\n')
+	return strings.TrimSpace(answer) == "yes"
+}
+
+// resetDB deletes every row of resetTables in one transaction, keeping the
+// tables themselves, and returns how many rows each lost. Tables the database
+// doesn't have are skipped.
+func resetDB(db *sql.DB) (map[string]int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	removed := make(map[string]int64)
+	for _, name := range resetTables {
+		table, err := quoteIdent(name)
+		if err != nil {
+			return nil, err
+		}
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`, name).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to look up table %s: %w", name, err)
+		}
+		if exists == 0 {
+			continue
+		}
+		result, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s;`, table))
+		if err != nil {
+			return nil, fmt.Errorf("failed to clear %s: %w", name, err)
+		}
+		if removed[name], err = result.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("failed to count rows removed from %s: %w", name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit reset: %w", err)
+	}
+	for _, name := range resetTables {
+		if n, ok := removed[name]; ok {
+			log.Printf("Reset removed %d rows from %s.\n", n, name)
+		}
+	}
+	return removed, nil
+}
+
+// removeCrawlState deletes the cursor file and checkpoint, whichever are set,
+// so the next crawl starts from the beginning.
+func removeCrawlState(cursorFile, checkpointFile string) error {
+	if cursorFile != "" {
+		if err := removeCursorFile(cursorFile); err != nil {
+			return err
+		}
+	}
+	if checkpointFile != "" {
+		if err := os.Remove(checkpointFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove checkpoint: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResetClearsFollowersAndCursor(t *testing.T) {
+	db := openTestDB(t)
+	dir := t.TempDir()
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 2), testFollowers("b", 2)}}
+	c := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), api, WithLimit(1))
+	c.cursorFile = filepath.Join(dir, "cursor")
+	c.checkpointFile = filepath.Join(dir, "checkpoint.json")
+	ctx := context.Background()
+	if _, err := c.Run(ctx); !errors.Is(err, errPageCap) {
+		t.Fatalf("Run = %v, want errPageCap", err)
+	}
+	if cursor, _ := getMetadata(ctx, db, cursorKey("did:plc:subject")); cursor != "c1" {
+		t.Fatalf("stored cursor = %q before the reset, want c1", cursor)
+	}
+
+	removed, err := resetDB(db)
+	if err != nil {
+		t.Fatalf("resetDB: %v", err)
+	}
+	if removed[tableName] != 2 {
+		t.Errorf("reset removed %d followers, want 2", removed[tableName])
+	}
+	var followers, runs int
+	db.QueryRow(`SELECT COUNT(*) FROM followers;`).Scan(&followers)
+	db.QueryRow(`SELECT COUNT(*) FROM runs;`).Scan(&runs)
+	if followers != 0 || runs != 1 {
+		t.Errorf("after the reset: %d followers and %d runs, want none and the run history kept", followers, runs)
+	}
+	if cursor, _ := getMetadata(ctx, db, cursorKey("did:plc:subject")); cursor != "" {
+		t.Errorf("stored cursor = %q after the reset, want it cleared", cursor)
+	}
+
+	if err := removeCrawlState(c.cursorFile, c.checkpointFile); err != nil {
+		t.Fatalf("removeCrawlState: %v", err)
+	}
+	for _, path := range []string{c.cursorFile, c.checkpointFile} {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s still exists after the reset: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestConfirmReset(t *testing.T) {
+	for answer, want := range map[string]bool{"yes\n": true, " yes \n": true, "y\n": false, "": false, "no\n": false} {
+		var prompt bytes.Buffer
+		if got := confirmReset(strings.NewReader(answer), &prompt); got != want {
+			t.Errorf("confirmReset(%q) = %v, want %v", answer, got, want)
+		}
+		if !strings.Contains(prompt.String(), "Type yes to continue") {
+			t.Errorf("prompt = %q", prompt.String())
+		}
+	}
+}
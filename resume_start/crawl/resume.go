@@ -0,0 +1,115 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Sources of the cursor a crawl resumes from, selected with -resume-strategy.
+const (
+	// resumeFlag only resumes from -cursor.
+	resumeFlag = "flag"
+	// resumeFile only resumes from -cursor-file, or failing that -checkpoint.
+	resumeFile = "file"
+	// resumeDB only resumes from the cursor recorded in the database.
+	resumeDB = "db"
+	// resumeAuto takes the first cursor found, in the order -cursor,
+	// -cursor-file, -checkpoint, database.
+	resumeAuto = "auto"
+)
+
+// cursorKey is the metadata key of the cursor a crawl of actor resumes from.
+func cursorKey(actor string) string {
+	return "cursor:" + actor
+}
+
+// resumePoint is where a crawl resumes: the cursor, the source it was read
+// from, and the checkpoint when that source is -checkpoint.
+type resumePoint struct {
+	cursor     string
+	source     string
+	checkpoint *checkpoint
+}
+
+// resumeSources are the places a crawl's resume cursor is read from.
+type resumeSources struct {
+	actor          string
+	flagCursor     string
+	cursorFile     string
+	checkpointFile string
+	db             *sql.DB
+}
+
+// chooseResume returns where a crawl resumes under strategy. A zero
+// resumePoint means the crawl starts from the beginning.
+func chooseResume(ctx context.Context, strategy string, src resumeSources) (resumePoint, error) {
+	var lookups []func() (resumePoint, error)
+	switch strategy {
+	case resumeFlag:
+		lookups = append(lookups, src.fromFlag)
+	case resumeFile:
+		lookups = append(lookups, src.fromCursorFile, src.fromCheckpoint)
+	case resumeDB:
+		lookups = append(lookups, func() (resumePoint, error) { return src.fromDB(ctx) })
+	case resumeAuto:
+		lookups = append(lookups, src.fromFlag, src.fromCursorFile, src.fromCheckpoint,
+			func() (resumePoint, error) { return src.fromDB(ctx) })
+	default:
+		return resumePoint{}, fmt.Errorf("unknown resume strategy %q, expected flag, file, db or auto", strategy)
+	}
+
+	for _, lookup := range lookups {
+		point, err := lookup()
+		if err != nil || point.cursor != "" {
+			return point, err
+		}
+	}
+	return resumePoint{}, nil
+}
+
+func (src resumeSources) fromFlag() (resumePoint, error) {
+	return resumePoint{cursor: src.flagCursor, source: "-cursor"}, nil
+}
+
+func (src resumeSources) fromCursorFile() (resumePoint, error) {
+	if src.cursorFile == "" {
+		return resumePoint{}, nil
+	}
+	cursor, err := readCursorFile(src.cursorFile)
+	if err != nil {
+		return resumePoint{}, err
+	}
+	return resumePoint{cursor: cursor, source: "cursor file " + src.cursorFile}, nil
+}
+
+// fromCheckpoint resumes an unfinished checkpoint of the same actor.
+func (src resumeSources) fromCheckpoint() (resumePoint, error) {
+	if src.checkpointFile == "" {
+		return resumePoint{}, nil
+	}
+	cp, err := readCheckpoint(src.checkpointFile)
+	if err != nil {
+		return resumePoint{}, err
+	}
+	switch {
+	case cp == nil || cp.Done:
+		return resumePoint{}, nil
+	case cp.Actor != src.actor:
+		log.Printf("Warning: checkpoint %s belongs to actor %s, ignoring it for %s.\n", src.checkpointFile, cp.Actor, src.actor)
+		return resumePoint{}, nil
+	}
+	return resumePoint{cursor: cp.Cursor, source: "checkpoint " + src.checkpointFile, checkpoint: cp}, nil
+}
+
+func (src resumeSources) fromDB(ctx context.Context) (resumePoint, error) {
+	if src.db == nil {
+		return resumePoint{}, nil
+	}
+	cursor, err := getMetadata(ctx, src.db, cursorKey(src.actor))
+	if err != nil {
+		return resumePoint{}, err
+	}
+	return resumePoint{cursor: cursor, source: "database"}, nil
+}
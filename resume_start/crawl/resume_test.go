@@ -0,0 +1,65 @@
+package crawl
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestChooseResumeWithConflictingSources(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	all := resumeSources{
+		actor:          "did:plc:subject",
+		flagCursor:     "from-flag",
+		cursorFile:     filepath.Join(dir, "cursor"),
+		checkpointFile: filepath.Join(dir, "checkpoint.json"),
+		db:             db,
+	}
+	if err := writeCursorFile(all.cursorFile, "from-file"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCheckpoint(all.checkpointFile, &checkpoint{Actor: all.actor, Cursor: "from-checkpoint"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := setMetadata(ctx, db, cursorKey(all.actor), "from-db"); err != nil {
+		t.Fatal(err)
+	}
+
+	noFlag, noCursorFile := all, all
+	noFlag.flagCursor = ""
+	noCursorFile.cursorFile = ""
+	noneButDB := resumeSources{actor: all.actor, db: db}
+	for _, tc := range []struct {
+		name, strategy string
+		src            resumeSources
+		cursor, source string
+	}{
+		{"flag", resumeFlag, all, "from-flag", "-cursor"},
+		{"file", resumeFile, all, "from-file", "cursor file " + all.cursorFile},
+		{"file falls back to checkpoint", resumeFile, noCursorFile, "from-checkpoint", "checkpoint " + all.checkpointFile},
+		{"db", resumeDB, all, "from-db", "database"},
+		{"auto prefers the flag", resumeAuto, all, "from-flag", "-cursor"},
+		{"auto then the cursor file", resumeAuto, noFlag, "from-file", "cursor file " + all.cursorFile},
+		{"auto then the database", resumeAuto, noneButDB, "from-db", "database"},
+		{"flag without one starts over", resumeFlag, noFlag, "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			point, err := chooseResume(ctx, tc.strategy, tc.src)
+			if err != nil {
+				t.Fatalf("chooseResume: %v", err)
+			}
+			if point.cursor != tc.cursor || point.source != tc.source {
+				t.Errorf("resumed from %q in %q, want %q in %q", point.cursor, point.source, tc.cursor, tc.source)
+			}
+			if (point.checkpoint != nil) != (tc.source == "checkpoint "+all.checkpointFile) {
+				t.Errorf("checkpoint = %+v", point.checkpoint)
+			}
+		})
+	}
+
+	if _, err := chooseResume(ctx, "newest", all); err == nil {
+		t.Error("chooseResume accepted an unknown strategy")
+	}
+}
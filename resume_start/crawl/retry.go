@@ -0,0 +1,193 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// errCircuitOpen is returned when the circuit breaker is open and configured to abort.
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// circuitBreaker counts consecutive failed API requests across pages. Once the
+// threshold is reached the circuit opens and further requests either pause for
+// the cooldown or abort, depending on configuration.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	abort     bool
+
+	mu       sync.Mutex // guards failures, as concurrent crawls share the breaker
+	failures int
+}
+
+// allow blocks while the circuit is open, or returns errCircuitOpen when the
+// breaker is configured to abort. A nil breaker or zero threshold never opens.
+func (b *circuitBreaker) allow(ctx context.Context, s sleeper) error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	failures := b.failures
+	b.mu.Unlock()
+	if failures < b.threshold {
+		return nil
+	}
+	if b.abort {
+		return fmt.Errorf("%w after %d consecutive failures", errCircuitOpen, failures)
+	}
+
+	log.Printf("Circuit breaker open after %d consecutive failures, cooling down for %v...\n", failures, b.cooldown)
+	if err := s.Sleep(ctx, b.cooldown); err != nil {
+		return err
+	}
+
+	// Half-open: allow a single trial request, a failure re-opens the circuit.
+	b.mu.Lock()
+	b.failures = b.threshold - 1
+	b.mu.Unlock()
+	return nil
+}
+
+// success closes the circuit.
+func (b *circuitBreaker) success() {
+	if b != nil {
+		b.mu.Lock()
+		b.failures = 0
+		b.mu.Unlock()
+	}
+}
+
+// failure records a failed request.
+func (b *circuitBreaker) failure() {
+	if b != nil {
+		b.mu.Lock()
+		b.failures++
+		b.mu.Unlock()
+	}
+}
+
+// permanentError marks a failure that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// permanent wraps err so retry returns it immediately instead of retrying.
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// jitter randomizes backoff so clients that failed together don't retry in
+// lockstep. Its source is injectable so a fixed seed gives a reproducible
+// sequence of delays.
+type jitter struct {
+	mu  sync.Mutex // guards rng, as concurrent crawls share the client
+	rng *rand.Rand
+}
+
+// newJitter returns a jitter drawing from rng.
+func newJitter(rng *rand.Rand) *jitter {
+	return &jitter{rng: rng}
+}
+
+// apply returns d plus a random extra of up to half of d. A nil jitter leaves d
+// unchanged.
+func (j *jitter) apply(d time.Duration) time.Duration {
+	if j == nil || d < 2 {
+		return d
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return d + time.Duration(j.rng.Int63n(int64(d/2)))
+}
+
+// retry calls fn up to maxRetries times with a jittered backoff between attempts,
+// reporting every outcome to the circuit breaker so its state persists across
+// pages. All waiting goes through s.
+func retry(ctx context.Context, breaker *circuitBreaker, s sleeper, j *jitter, fn func(attempt int) error) error {
+	var lastErr error
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		if err := breaker.allow(ctx, s); err != nil {
+			return err
+		}
+
+		err := fn(attempt)
+		if err == nil {
+			breaker.success()
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		breaker.failure()
+		lastErr = err
+
+		loggerFrom(ctx).Printf("Attempt %d failed (category=%s): %v. Retrying after backoff...\n", attempt, classifyError(err), err)
+		backoff := j.apply(time.Duration(attempt) * time.Second) // Exponential backoff
+		if err := s.Sleep(ctx, backoff); err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("exceeded max retries: %w", lastErr)
+}
+
+// classifyError buckets a failed attempt so flaky networks can be told apart
+// from API problems in the logs.
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection_reset"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &opErr):
+		return "network"
+	default:
+		return "api"
+	}
+}
+
+// sleeper waits between attempts. The real implementation is contextSleeper;
+// tests can substitute a fake to drive time deterministically and record the
+// backoff schedule.
+type sleeper interface {
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// contextSleeper sleeps for real via sleepContext.
+type contextSleeper struct{}
+
+func (contextSleeper) Sleep(ctx context.Context, d time.Duration) error {
+	return sleepContext(ctx, d)
+}
+
+// sleepContext pauses for d, returning early with the context's error if it is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,317 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeSleeper records the pauses asked of it instead of waiting.
+type fakeSleeper struct {
+	mu    sync.Mutex
+	slept []time.Duration
+}
+
+func (s *fakeSleeper) Sleep(ctx context.Context, d time.Duration) error {
+	s.mu.Lock()
+	s.slept = append(s.slept, d)
+	s.mu.Unlock()
+	return ctx.Err()
+}
+
+func (s *fakeSleeper) durations() []time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Duration(nil), s.slept...)
+}
+
+// newTestClient returns an API client of a mock server serving h, which
+// records its backoff instead of sleeping.
+func newTestClient(t *testing.T, h http.Handler) (*apiClient, *fakeSleeper) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	s := &fakeSleeper{}
+	return &apiClient{
+		http:        srv.Client(),
+		serviceURL:  srv.URL,
+		maxBodySize: defaultMaxBodySize,
+		limiter:     newRateLimiter(0),
+		sleeper:     s,
+	}, s
+}
+
+func TestCircuitBreakerTripsOnSustainedFailures(t *testing.T) {
+	var requests int
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	client.breaker = &circuitBreaker{threshold: 3, abort: true}
+
+	var v APIResponse
+	err := client.getJSON(context.Background(), client.xrpcURL("app.bsky.graph.getFollowers", nil), &v)
+	if !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("getJSON = %v, want errCircuitOpen", err)
+	}
+	if requests != 3 {
+		t.Errorf("made %d requests, want 3 before the circuit opened", requests)
+	}
+}
+
+func TestCircuitBreakerPersistsAcrossPages(t *testing.T) {
+	breaker := &circuitBreaker{threshold: maxRetries + 2, abort: true}
+	s := &fakeSleeper{}
+	var calls int
+	failing := func(int) error {
+		calls++
+		return errors.New("unavailable")
+	}
+
+	if err := retry(context.Background(), breaker, s, nil, failing); err == nil || errors.Is(err, errCircuitOpen) {
+		t.Fatalf("first page: retry = %v, want retries exhausted", err)
+	}
+	calls = 0
+	if err := retry(context.Background(), breaker, s, nil, failing); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("second page: retry = %v, want errCircuitOpen", err)
+	}
+	if calls != 2 {
+		t.Errorf("second page made %d attempts, want 2", calls)
+	}
+}
+
+func TestCircuitBreakerCooldown(t *testing.T) {
+	breaker := &circuitBreaker{threshold: 2, cooldown: time.Minute}
+	s := &fakeSleeper{}
+	var calls int
+	err := retry(context.Background(), breaker, s, nil, func(int) error {
+		calls++
+		if calls <= 2 {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	cooled := false
+	for _, d := range s.durations() {
+		cooled = cooled || d == time.Minute
+	}
+	if !cooled {
+		t.Errorf("slept %v, want a cooldown of %v", s.durations(), time.Minute)
+	}
+	if breaker.failures != 0 {
+		t.Errorf("failures = %d after a success, want 0", breaker.failures)
+	}
+}
+
+// failingTransport fails every request with err.
+type failingTransport struct {
+	err   error
+	calls int
+}
+
+func (t *failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	t.calls++
+	return nil, t.err
+}
+
+// timeoutError is a net.Error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryClassifiesNetworkErrors(t *testing.T) {
+	for _, tc := range []struct {
+		category string
+		err      error
+	}{
+		{"dns", &net.DNSError{Err: "no such host", Name: "public.api.bsky.app", IsNotFound: true}},
+		{"connection_reset", &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}},
+		{"timeout", timeoutError{}},
+		{"network", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}},
+	} {
+		t.Run(tc.category, func(t *testing.T) {
+			transport := &failingTransport{err: tc.err}
+			client := &apiClient{
+				http:       &http.Client{Transport: transport},
+				serviceURL: "https://public.api.bsky.app",
+				limiter:    newRateLimiter(0),
+				sleeper:    &fakeSleeper{},
+			}
+			var logs bytes.Buffer
+			ctx := withLogger(context.Background(), log.New(&logs, "", 0))
+
+			var v APIResponse
+			err := client.getJSON(ctx, client.xrpcURL("app.bsky.graph.getFollowers", nil), &v)
+			if err == nil {
+				t.Fatal("getJSON succeeded")
+			}
+			if transport.calls != maxRetries {
+				t.Errorf("made %d attempts, want %d", transport.calls, maxRetries)
+			}
+			if got := classifyError(err); got != tc.category {
+				t.Errorf("classifyError = %s, want %s", got, tc.category)
+			}
+			if !strings.Contains(logs.String(), "category="+tc.category) {
+				t.Errorf("logs lack category=%s:\n%s", tc.category, logs.String())
+			}
+		})
+	}
+}
+
+func TestRetryBackoffDurations(t *testing.T) {
+	client, s := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+	}))
+	var v APIResponse
+	if err := client.getJSON(context.Background(), client.xrpcURL("app.bsky.graph.getFollowers", nil), &v); err == nil {
+		t.Fatal("getJSON succeeded against a failing server")
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second, 5 * time.Second}
+	if got := s.durations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("slept %v, want %v", got, want)
+	}
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	var requests int
+	client, s := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "7")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"followers":[]}`))
+	}))
+	var v APIResponse
+	if err := client.getJSON(context.Background(), client.xrpcURL("app.bsky.graph.getFollowers", nil), &v); err != nil {
+		t.Fatalf("getJSON: %v", err)
+	}
+	got := s.durations()
+	if len(got) != 2 || got[0] != time.Second || got[1] <= 6*time.Second || got[1] > 7*time.Second {
+		t.Errorf("slept %v, want the 1s backoff then the rest of the 7s Retry-After", got)
+	}
+}
+
+func TestCrawlEmptyPageRetryDurations(t *testing.T) {
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 1), {}, testFollowers("c", 1)}}
+	client, s := newTestClient(t, api)
+	c := &crawler{store: newMemoryStore(), client: client, actor: "did:plc:subject", pageDelay: 250 * time.Millisecond}
+
+	if _, err := c.crawl(context.Background(), ""); err != nil {
+		t.Fatalf("crawl: %v", err)
+	}
+	want := []time.Duration{
+		250 * time.Millisecond,                        // after the first page
+		time.Second, 2 * time.Second, 3 * time.Second, // refetching the empty page
+		250 * time.Millisecond, // after accepting it
+	}
+	if got := s.durations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("slept %v, want %v", got, want)
+	}
+}
+
+func TestRetryJitterIsReproducibleWithSeed(t *testing.T) {
+	backoff := func() []time.Duration {
+		client, s := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+		}))
+		client.jitter = newJitter(rand.New(rand.NewSource(42)))
+		var v APIResponse
+		if err := client.getJSON(context.Background(), client.xrpcURL("app.bsky.graph.getFollowers", nil), &v); err == nil {
+			t.Fatal("getJSON succeeded against a failing server")
+		}
+		return s.durations()
+	}
+
+	first, second := backoff(), backoff()
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("seed 42 slept %v, then %v; want the same sequence", first, second)
+	}
+	if len(first) != maxRetries {
+		t.Fatalf("slept %d times, want %d", len(first), maxRetries)
+	}
+	jittered := false
+	for i, d := range first {
+		base := time.Duration(i+1) * time.Second
+		if d < base || d >= base+base/2 {
+			t.Errorf("backoff %d = %v, want within [%v, %v)", i+1, d, base, base+base/2)
+		}
+		jittered = jittered || d != base
+	}
+	if !jittered {
+		t.Errorf("slept %v, want jitter added to the backoff", first)
+	}
+}
+
+func TestRetryAfterHoldsBackNextPage(t *testing.T) {
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 1), testFollowers("b", 1)}}
+	limited := false
+	client, s := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !limited {
+			limited = true
+			w.Header().Set("Retry-After", "30")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		api.ServeHTTP(w, r)
+	}))
+
+	_, cursor, err := fetchFollowers(context.Background(), client, "did:plc:subject", "")
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	firstPage := len(s.durations())
+	if _, _, err := fetchFollowers(context.Background(), client, "did:plc:subject", cursor); err != nil {
+		t.Fatalf("second page: %v", err)
+	}
+
+	// The fake sleeper doesn't let time pass, so the window is still open
+	// when the second page starts.
+	got := s.durations()
+	if len(got) != firstPage+1 {
+		t.Fatalf("slept %v, want one wait before the second page", got)
+	}
+	if d := got[firstPage]; d <= 25*time.Second || d > 30*time.Second {
+		t.Errorf("second page waited %v, want the rest of the 30s Retry-After", d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"7", 7 * time.Second, true},
+		{" 0 ", 0, true},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"86400", maxRetryAfter, true},
+		{"", 0, false},
+		{"soon", 0, false},
+	} {
+		got, ok := parseRetryAfter(tc.value, now)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tc.value, got, ok, tc.want, tc.ok)
+		}
+	}
+}
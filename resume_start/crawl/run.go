@@ -0,0 +1,299 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Crawler runs followers crawls of one actor into a store. With a SQLite store
+// of the followers table it also records each run and what changed since the
+// previous one. It is what the CLI runs in its default mode, configured from
+// its flags with the options below.
+type Crawler struct {
+	crawler
+
+	// cursor is where every run starts; empty crawls the full list.
+	cursor string
+	// baseline holds the handles stored before the next run, keyed by DID.
+	// It is reloaded from the database when nil.
+	baseline map[string]string
+	// onStaleCursor is what a run does when the API rejects its cursor: one of
+	// the staleCursor policies.
+	onStaleCursor string
+	// publisher, when set, receives an event for every change a run finds.
+	publisher Publisher
+	// atomicRecrawl makes runs from the start of the list crawl into a
+	// staging table that replaces the followers table once they complete.
+	atomicRecrawl bool
+}
+
+// Policies for a cursor the API rejects, selected with -on-stale-cursor.
+const (
+	// staleCursorRestart crawls the whole list from the beginning instead.
+	staleCursorRestart = "restart"
+	// staleCursorAbort fails the run, leaving the cursor for inspection.
+	staleCursorAbort = "abort"
+	// staleCursorSkip skips the run and forgets the cursor, so the next run
+	// starts from the beginning.
+	staleCursorSkip = "skip"
+)
+
+// errRunSkipped is returned by a run that didn't crawl.
+var errRunSkipped = errors.New("run skipped")
+
+// Option configures a Crawler.
+type Option func(*Crawler)
+
+// WithActor sets the DID or handle whose followers are crawled.
+func WithActor(actor string) Option {
+	return func(cr *Crawler) { cr.actor = actor }
+}
+
+// WithLimit stops each run after pages pages; 0 crawls to the end.
+func WithLimit(pages int) Option {
+	return func(cr *Crawler) { cr.maxPages = pages }
+}
+
+// WithClient sends API requests through client instead of http.DefaultClient.
+func WithClient(client *http.Client) Option {
+	return func(cr *Crawler) { cr.client.http = client }
+}
+
+// WithServiceURL sets the AppView the API requests are sent to.
+func WithServiceURL(serviceURL string) Option {
+	return func(cr *Crawler) { cr.client.serviceURL = serviceURL }
+}
+
+// WithLogger sends the crawl's log messages to logger instead of the standard logger.
+func WithLogger(logger *log.Logger) Option {
+	return func(cr *Crawler) { cr.logger = logger }
+}
+
+// WithStore sets the store followers are saved in; it is initialized by
+// NewCrawler. The database of a SQLite store also receives the run history,
+// and that of a log store the page hashes and cursor log.
+func WithStore(store Store) Option {
+	return func(cr *Crawler) {
+		cr.store = store
+		cr.db = nil
+		switch s := store.(type) {
+		case *sqliteStore:
+			cr.db = s.db
+		case *logStore:
+			cr.db = s.db
+		}
+	}
+}
+
+// WithCursor starts every run at cursor instead of the beginning of the list.
+func WithCursor(cursor string) Option {
+	return func(cr *Crawler) { cr.cursor = cursor }
+}
+
+// WithStaleCursorPolicy sets what a run does when the API rejects its cursor:
+// "restart" (the default) crawls from the beginning, "abort" fails the run and
+// "skip" skips it, leaving the next run to start from the beginning.
+func WithStaleCursorPolicy(policy string) Option {
+	return func(cr *Crawler) { cr.onStaleCursor = policy }
+}
+
+// WithPublisher publishes an event for every new, lost and renamed follower
+// a run finds. Like the recorded changes, none are published for the first
+// run, when every follower is new.
+func WithPublisher(publisher Publisher) Option {
+	return func(cr *Crawler) { cr.publisher = publisher }
+}
+
+// WithCursorFile records the latest cursor in path after each page and
+// removes the file once a run completes.
+func WithCursorFile(path string) Option {
+	return func(cr *Crawler) { cr.cursorFile = path }
+}
+
+// WithCheckpointFile records the progress of each run in path after every page.
+func WithCheckpointFile(path string) Option {
+	return func(cr *Crawler) { cr.checkpointFile = path }
+}
+
+// withCheckpoint continues the progress of a loaded checkpoint when the first
+// run starts at its cursor.
+func withCheckpoint(cp *checkpoint) Option {
+	return func(cr *Crawler) { cr.checkpoint = cp }
+}
+
+// WithCursorLog appends every cursor the API returns to the cursor_log table
+// of the store's database, which NewCrawler creates if needed.
+func WithCursorLog(enabled bool) Option {
+	return func(cr *Crawler) { cr.cursorLog = enabled }
+}
+
+// WithFailFast aborts a run on the first failed save instead of retrying the page.
+func WithFailFast(failFast bool) Option {
+	return func(cr *Crawler) { cr.failFast = failFast }
+}
+
+// WithCommitBatch commits followers every rows rows across pages instead of
+// once per page; 0 commits each page.
+func WithCommitBatch(rows int) Option {
+	return func(cr *Crawler) { cr.commitBatch = rows }
+}
+
+// WithPageDelay pauses at least delay between saving a page and fetching the next.
+func WithPageDelay(delay time.Duration) Option {
+	return func(cr *Crawler) { cr.pageDelay = delay }
+}
+
+// WithMaxConsecutiveEmpty stops a run after pages pages in a row without a
+// follower that isn't stored yet; 0 crawls to the end.
+func WithMaxConsecutiveEmpty(pages int) Option {
+	return func(cr *Crawler) { cr.maxConsecutiveEmpty = pages }
+}
+
+// WithAtomicRecrawl makes runs from the start of the list crawl into a staging
+// table that replaces the followers table only once they complete.
+func WithAtomicRecrawl(enabled bool) Option {
+	return func(cr *Crawler) { cr.atomicRecrawl = enabled }
+}
+
+// withFilters adds filters deciding which fetched followers are saved, run in
+// the order given after those already added.
+func withFilters(filters ...followerFilter) Option {
+	return func(cr *Crawler) { cr.filters = append(cr.filters, filters...) }
+}
+
+// withAPIClient shares an already configured API client, as the CLI does.
+func withAPIClient(client *apiClient) Option {
+	return func(cr *Crawler) { cr.client = client }
+}
+
+// NewCrawler returns a Crawler of the default actor against the public AppView,
+// adjusted by opts. A store is required.
+func NewCrawler(ctx context.Context, opts ...Option) (*Crawler, error) {
+	cr := &Crawler{crawler: crawler{
+		actor: defaultActor,
+		client: &apiClient{
+			http:        http.DefaultClient,
+			serviceURL:  defaultServiceURL,
+			maxBodySize: defaultMaxBodySize,
+			limiter:     newRateLimiter(0),
+		},
+	}, onStaleCursor: staleCursorRestart}
+	for _, opt := range opts {
+		opt(cr)
+	}
+	if cr.store == nil {
+		return nil, errors.New("crawler has no store, use WithStore")
+	}
+	if err := cr.store.Init(ctx); err != nil {
+		return nil, err
+	}
+	if cr.cursorLog && cr.db != nil {
+		if err := initializeCursorLog(cr.db); err != nil {
+			return nil, err
+		}
+	}
+	return cr, nil
+}
+
+// Run crawls the followers once and records the run. A run that completes from
+// the start of the list is compared with the followers of the previous full
+// run to find new, lost and renamed ones; partial runs only report what they
+// saw. The returned Stats describe the run even when err is set, unless the
+// run could not be started at all, in which case they are zero. Runs into any
+// other store are not recorded: Stats.ID is 0 and changes are only found from
+// the second run.
+func (cr *Crawler) Run(ctx context.Context) (Stats, error) {
+	if s, ok := cr.store.(*sqliteStore); !ok || s.table != tableName {
+		return cr.run(ctx, 0)
+	}
+	if cr.baseline == nil {
+		baseline, err := loadBaseline(cr.db, cr.actor)
+		if err != nil {
+			return Stats{}, fmt.Errorf("failed to load stored followers: %w", err)
+		}
+		cr.baseline = baseline
+	}
+
+	runID, err := startRun(cr.db, cr.actor)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to record run: %w", err)
+	}
+	if cr.atomicRecrawl && cr.cursor == "" {
+		return cr.runAtomic(ctx, runID)
+	}
+	return cr.run(ctx, runID)
+}
+
+// run crawls the followers and compares them with the baseline, recording the
+// outcome under runID unless it is 0.
+func (cr *Crawler) run(ctx context.Context, runID int64) (Stats, error) {
+	startedAt := time.Now()
+	startCursor := cr.cursor
+	seen, err := cr.crawl(ctx, cr.cursor)
+	if errors.Is(err, errStaleCursor) {
+		switch cr.onStaleCursor {
+		case staleCursorRestart:
+			cr.logf("Warning: %v. Restarting from the beginning of the list.\n", err)
+			cr.cursor, startCursor = "", ""
+			seen, err = cr.crawl(ctx, "")
+		case staleCursorSkip:
+			cr.logf("Warning: %v. Skipping this run; the next one starts from the beginning of the list.\n", err)
+			cr.cursor = ""
+			cr.recordDone()
+			err = fmt.Errorf("%w: %v", errRunSkipped, err)
+		}
+	}
+	status := runStatus(ctx, err)
+
+	// A resumed or unfinished crawl only saw part of the list, so it can't tell who left.
+	var changes []followerChange
+	if status == "completed" {
+		added, lost := diffFollowers(cr.baseline, seen, startCursor == "")
+		renamed := renamedFollowers(cr.baseline, seen)
+		logDiff(cr.logf, cr.baseline, seen, added, lost, renamed)
+		changes = runChanges(cr.baseline, seen, added, lost, renamed)
+	}
+	// Without a previous state every follower is new, which isn't worth listing.
+	listed := changes
+	if len(cr.baseline) == 0 {
+		listed = nil
+	}
+	if runID != 0 {
+		if err := finishRun(cr.db, runID, status, startCursor, len(seen), changes); err != nil {
+			cr.logf("Failed to record run %d: %v", runID, err)
+		}
+		if err := recordChanges(cr.db, runID, listed); err != nil {
+			cr.logf("Failed to record changes of run %d: %v", runID, err)
+		}
+	}
+	if cr.publisher != nil && len(listed) > 0 {
+		// Publish even when shutting down, as the changes are already recorded.
+		if err := cr.publisher.Publish(context.WithoutCancel(ctx), changeEvents(cr.actor, listed, time.Now())); err != nil {
+			cr.logf("Failed to publish %d events: %v", len(listed), err)
+		}
+	}
+
+	if startCursor == "" && status == "completed" {
+		cr.baseline = seen
+	} else if runID != 0 {
+		cr.baseline = nil
+	}
+
+	return Stats{
+		ID:            runID,
+		Status:        status,
+		StartCursor:   startCursor,
+		StartedAt:     startedAt,
+		FinishedAt:    time.Now(),
+		FollowersSeen: len(seen),
+		NewFollowers:  countChanges(changes, changeNew),
+		LostFollowers: countChanges(changes, changeLost),
+		HandleChanges: countChanges(changes, changeHandle),
+		Changes:       listed,
+		Err:           err,
+	}, err
+}
@@ -0,0 +1,178 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunReportsDeltaSincePreviousRun(t *testing.T) {
+	db := openTestDB(t)
+	store := newSQLiteStore(db, tableName, followerColumns)
+	api := &followersAPI{pages: [][]Follower{{
+		{DID: "did:plc:a", Handle: "a.test"},
+		{DID: "did:plc:b", Handle: "b.test"},
+		{DID: "did:plc:c", Handle: "c.test"},
+	}}}
+	ctx := context.Background()
+
+	first, err := newTestCrawler(t, store, api).Run(ctx)
+	if err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	if first.NewFollowers != 3 || len(first.Changes) != 0 {
+		t.Errorf("first run: %d new, changes %v; want 3 new and none listed", first.NewFollowers, first.Changes)
+	}
+
+	// a unfollows, b changes handle and d follows.
+	api.pages = [][]Follower{{
+		{DID: "did:plc:b", Handle: "bee.test"},
+		{DID: "did:plc:c", Handle: "c.test"},
+		{DID: "did:plc:d", Handle: "d.test"},
+	}}
+	second, err := newTestCrawler(t, store, api).Run(ctx)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	want := []followerChange{
+		{Kind: changeNew, DID: "did:plc:d", Handle: "d.test"},
+		{Kind: changeLost, DID: "did:plc:a", Handle: "a.test"},
+		{Kind: changeHandle, DID: "did:plc:b", Handle: "bee.test", OldHandle: "b.test"},
+	}
+	if !reflect.DeepEqual(second.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", second.Changes, want)
+	}
+	if second.NewFollowers != 1 || second.LostFollowers != 1 || second.HandleChanges != 1 {
+		t.Errorf("second run counted %d new, %d lost, %d renamed; want 1 each", second.NewFollowers, second.LostFollowers, second.HandleChanges)
+	}
+
+	var recorded int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM follower_changes WHERE run_id = ?;`, second.ID).Scan(&recorded); err != nil || recorded != 3 {
+		t.Errorf("recorded changes of run %d = %d, %v; want 3", second.ID, recorded, err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeSummary(&buf, "text", second); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"new: d.test (did:plc:d)", "lost: a.test (did:plc:a)", "b.test -> bee.test (did:plc:b)"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("summary lacks %q:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestNewCrawlerAppliesOptions(t *testing.T) {
+	if _, err := NewCrawler(context.Background()); err == nil {
+		t.Error("NewCrawler without a store succeeded")
+	}
+
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 1), testFollowers("b", 1), testFollowers("c", 1)}}
+	c := newTestCrawler(t, newMemoryStore(), api, WithCursor("c1"), WithLimit(1), WithStaleCursorPolicy(staleCursorAbort))
+	stats, err := c.Run(context.Background())
+	if !errors.Is(err, errPageCap) {
+		t.Fatalf("Run = %v, want errPageCap", err)
+	}
+	if stats.StartCursor != "c1" || stats.FollowersSeen != 1 {
+		t.Errorf("run started at %q and saw %d followers, want c1 and 1", stats.StartCursor, stats.FollowersSeen)
+	}
+	if !reflect.DeepEqual(api.cursors, []string{"c1"}) {
+		t.Errorf("requested cursors %q, want only c1", api.cursors)
+	}
+	if c.onStaleCursor != staleCursorAbort {
+		t.Errorf("stale cursor policy = %q, want %q", c.onStaleCursor, staleCursorAbort)
+	}
+}
+
+func TestHandleCaseIsNotARename(t *testing.T) {
+	db := openTestDB(t)
+	store := newSQLiteStore(db, tableName, followerColumns)
+	api := &followersAPI{pages: [][]Follower{{{DID: "did:plc:a", Handle: "Alice.bsky.social"}}}}
+	ctx := context.Background()
+
+	if _, err := newTestCrawler(t, store, api).Run(ctx); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	var handle, original string
+	if err := db.QueryRow(`SELECT handle, handle_original FROM followers WHERE did = 'did:plc:a';`).Scan(&handle, &original); err != nil {
+		t.Fatal(err)
+	}
+	if handle != "alice.bsky.social" || original != "Alice.bsky.social" {
+		t.Errorf("stored handle %q, original %q; want alice.bsky.social and Alice.bsky.social", handle, original)
+	}
+
+	api.pages = [][]Follower{{{DID: "did:plc:a", Handle: "alice.bsky.social"}}}
+	stats, err := newTestCrawler(t, store, api).Run(ctx)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if stats.HandleChanges != 0 || stats.NewFollowers != 0 || len(stats.Changes) != 0 {
+		t.Errorf("changing only the case recorded changes %+v, want none", stats.Changes)
+	}
+	if got := renamedFollowers(map[string]string{"did:plc:a": "Alice.bsky.social"}, map[string]string{"did:plc:a": "alice.bsky.social"}); len(got) != 0 {
+		t.Errorf("renamedFollowers = %v, want none", got)
+	}
+}
+
+func TestStaleCursorPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy     string
+		err        error
+		status     string
+		seen       int
+		nextCursor string
+	}{
+		{staleCursorRestart, nil, "completed", 3, ""},
+		{staleCursorAbort, errStaleCursor, "failed", 0, "expired"},
+		{staleCursorSkip, errRunSkipped, "skipped", 0, ""},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			db := openTestDB(t)
+			api := &followersAPI{pages: [][]Follower{testFollowers("a", 2), testFollowers("b", 1)}}
+			c := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), api, WithCursor("expired"), WithStaleCursorPolicy(tc.policy))
+
+			stats, err := c.Run(context.Background())
+			if tc.err == nil && err != nil || tc.err != nil && !errors.Is(err, tc.err) {
+				t.Fatalf("Run = %v, want %v", err, tc.err)
+			}
+			if stats.Status != tc.status || stats.FollowersSeen != tc.seen {
+				t.Errorf("run %s with %d followers seen, want %s with %d", stats.Status, stats.FollowersSeen, tc.status, tc.seen)
+			}
+			if c.cursor != tc.nextCursor {
+				t.Errorf("next run starts at %q, want %q", c.cursor, tc.nextCursor)
+			}
+			var recorded string
+			if err := db.QueryRow(`SELECT status FROM runs WHERE id = ?;`, stats.ID).Scan(&recorded); err != nil || recorded != tc.status {
+				t.Errorf("recorded run status %q, %v; want %s", recorded, err, tc.status)
+			}
+		})
+	}
+}
+
+func TestLostFollowerReportedOnceAcrossProcesses(t *testing.T) {
+	db := openTestDB(t)
+	captureLog(t)
+	all := []Follower{{DID: "did:plc:a", Handle: "a.test"}, {DID: "did:plc:b", Handle: "b.test"}, {DID: "did:plc:c", Handle: "c.test"}}
+	for i, tc := range []struct {
+		followers []Follower
+		lost, new int
+	}{
+		{all, 0, 3},
+		{all[:2], 1, 0}, // c unfollows
+		{all[:2], 0, 0},
+		{all, 0, 1}, // c follows again
+	} {
+		// Each run is a new process with a new Crawler.
+		c := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), &followersAPI{pages: [][]Follower{tc.followers}})
+		stats, err := c.Run(context.Background())
+		if err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+		if stats.LostFollowers != tc.lost || stats.NewFollowers != tc.new {
+			t.Errorf("run %d: lost %d, new %d; want lost %d, new %d", i+1, stats.LostFollowers, stats.NewFollowers, tc.lost, tc.new)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const runsTable = "runs"
+
+// initializeRuns creates the table recording each crawl run.
+func initializeRuns(db *sql.DB) error {
+	ddl, err := runsDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create runs table: %w", err)
+	}
+	return ensureColumns(db, runsTable, map[string]string{"actor": "TEXT", "handle_changes": "INTEGER", "start_cursor": "TEXT"})
+}
+
+// runsDDL returns the statements creating the runs table.
+func runsDDL() (string, error) {
+	table, err := quoteIdent(runsTable)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			started_at DATETIME,
+			finished_at DATETIME,
+			status TEXT,
+			followers_seen INTEGER,
+			new_followers INTEGER,
+			lost_followers INTEGER,
+			actor TEXT,
+			handle_changes INTEGER,
+			start_cursor TEXT
+		);
+	`, table), nil
+}
+
+// startRun records the start of a crawl run of actor and returns its ID.
+func startRun(db *sql.DB, actor string) (int64, error) {
+	table, err := quoteIdent(runsTable)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := db.Exec(fmt.Sprintf(`INSERT INTO %s (started_at, status, actor) VALUES (?, ?, ?);`, table), time.Now().UTC(), "running", actor)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert run: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// finishRun records the outcome of a crawl run that started at startCursor.
+func finishRun(db *sql.DB, id int64, status, startCursor string, seen int, changes []followerChange) error {
+	table, err := quoteIdent(runsTable)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`
+		UPDATE %s SET finished_at = ?, status = ?, start_cursor = ?, followers_seen = ?, new_followers = ?, lost_followers = ?, handle_changes = ? WHERE id = ?;
+	`, table), time.Now().UTC(), status, startCursor, seen,
+		countChanges(changes, changeNew), countChanges(changes, changeLost), countChanges(changes, changeHandle), id)
+	if err != nil {
+		return fmt.Errorf("failed to update run: %w", err)
+	}
+	return nil
+}
+
+// runStatus classifies the outcome of a crawl.
+func runStatus(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return "completed"
+	case errors.Is(err, errPageCap):
+		return "capped"
+	case errors.Is(err, errNoProgress):
+		return "stopped"
+	case errors.Is(err, errRunSkipped):
+		return "skipped"
+	case ctx.Err() != nil && errors.Is(err, ctx.Err()):
+		return "interrupted"
+	default:
+		return "failed"
+	}
+}
+
+// loadHandles returns the handles of all stored followers keyed by DID.
+func loadHandles(db *sql.DB) (map[string]string, error) {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`SELECT did, handle FROM %s;`, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query followers: %w", err)
+	}
+	defer rows.Close()
+
+	handles := make(map[string]string)
+	for rows.Next() {
+		var did, handle string
+		if err := rows.Scan(&did, &handle); err != nil {
+			return nil, fmt.Errorf("failed to scan follower: %w", err)
+		}
+		handles[did] = handle
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate followers: %w", err)
+	}
+	return handles, nil
+}
+
+// loadBaseline returns the handles of the followers of actor as of the
+// previous completed full run, keyed by DID: those last seen since it started.
+// Followers that run found lost are left out, so later runs don't report them
+// lost again. Without such a run, or on a table without last_seen, every
+// stored follower is returned.
+func loadBaseline(db *sql.DB, actor string) (map[string]string, error) {
+	runs, err := quoteIdent(runsTable)
+	if err != nil {
+		return nil, err
+	}
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := tableColumns(context.Background(), db, table)
+	if err != nil {
+		return nil, err
+	}
+	if !existing["last_seen"] {
+		return loadHandles(db)
+	}
+	var startedAt time.Time
+	err = db.QueryRow(fmt.Sprintf(`
+		SELECT started_at FROM %s
+		WHERE actor = ? AND status = 'completed' AND COALESCE(start_cursor, '') = ''
+		ORDER BY id DESC LIMIT 1;
+	`, runs), actor).Scan(&startedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return loadHandles(db)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up previous run: %w", err)
+	}
+
+	// Times are stored in UTC, so comparing them as text orders them.
+	rows, err := db.Query(fmt.Sprintf(`SELECT did, handle FROM %s WHERE last_seen >= ?;`, table), startedAt.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query followers: %w", err)
+	}
+	defer rows.Close()
+
+	handles := make(map[string]string)
+	for rows.Next() {
+		var did, handle string
+		if err := rows.Scan(&did, &handle); err != nil {
+			return nil, fmt.Errorf("failed to scan follower: %w", err)
+		}
+		handles[did] = handle
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate followers: %w", err)
+	}
+	return handles, nil
+}
+
+// diffFollowers returns the DIDs seen now but not before, and, when the crawl
+// covered the full list, the DIDs seen before but no longer present.
+func diffFollowers(before, now map[string]string, full bool) (added, lost []string) {
+	for did := range now {
+		if _, ok := before[did]; !ok {
+			added = append(added, did)
+		}
+	}
+	if full {
+		for did := range before {
+			if _, ok := now[did]; !ok {
+				lost = append(lost, did)
+			}
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(lost)
+	return added, lost
+}
+
+// renamedFollowers returns the DIDs present before and now under a different
+// handle. A change of case only is not a rename.
+func renamedFollowers(before, now map[string]string) []string {
+	var renamed []string
+	for did, handle := range now {
+		if old, ok := before[did]; ok && old != "" && handle != "" && normalizeHandle(old) != normalizeHandle(handle) {
+			renamed = append(renamed, did)
+		}
+	}
+	sort.Strings(renamed)
+	return renamed
+}
+
+// logDiff logs new, lost and renamed followers. Individual accounts are only
+// listed when there was a previous state to compare against.
+func logDiff(logf func(format string, args ...interface{}), before, now map[string]string, added, lost, renamed []string) {
+	logf("Run diff: %d new followers, %d lost followers, %d handle changes.\n", len(added), len(lost), len(renamed))
+	if len(before) == 0 {
+		return
+	}
+	for _, did := range added {
+		logf("New follower: %s (%s)\n", now[did], did)
+	}
+	for _, did := range lost {
+		logf("Lost follower: %s (%s)\n", before[did], did)
+	}
+	for _, did := range renamed {
+		logf("Handle change: %s -> %s (%s)\n", before[did], now[did], did)
+	}
+}
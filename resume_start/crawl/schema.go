@@ -0,0 +1,319 @@
+package crawl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// column describes a followers table column and how its value is bound from a
+// Follower. Key columns together form the table's primary key. A preserved
+// column keeps the value of the row being updated and is only bound for new rows.
+type column struct {
+	name     string
+	typ      string
+	key      bool
+	value    func(Follower) interface{}
+	preserve bool
+}
+
+// fullColumns is the default schema, storing every field of the followers response.
+var fullColumns = []column{
+	{"did", "TEXT", true, func(f Follower) interface{} { return f.DID }, false},
+	{"handle", "TEXT", false, func(f Follower) interface{} { return normalizeHandle(f.Handle) }, false},
+	{"displayName", "TEXT", false, func(f Follower) interface{} { return f.optional(fieldDisplayName, f.DisplayName) }, false},
+	{"avatar", "TEXT", false, func(f Follower) interface{} { return f.optional(fieldAvatar, storedAvatar(f.Avatar)) }, false},
+	{"viewer_muted", "BOOLEAN", false, func(f Follower) interface{} { return f.Viewer.Muted }, false},
+	{"viewer_blockedBy", "BOOLEAN", false, func(f Follower) interface{} { return f.Viewer.BlockedBy }, false},
+	{"viewer_following", "TEXT", false, func(f Follower) interface{} { return f.Viewer.Following }, false},
+	{"labels", "TEXT", false, func(f Follower) interface{} { return labelSerializer.Serialize(f.Labels) }, false},
+	{"createdAt", "DATETIME", false, func(f Follower) interface{} { return f.CreatedAt.Time }, false},
+	{"description", "TEXT", false, func(f Follower) interface{} {
+		return f.optional(fieldDescription, storedDescription(f.Description))
+	}, false},
+	{"indexedAt", "DATETIME", false, func(f Follower) interface{} { return f.IndexedAt.Time }, false},
+	{"fetch_seq", "INTEGER", false, func(f Follower) interface{} { return f.FetchSeq }, false},
+	{"first_seen", "DATETIME", false, func(Follower) interface{} { return time.Now().UTC() }, true},
+	{"last_seen", "DATETIME", false, func(Follower) interface{} { return time.Now().UTC() }, false},
+	{"handle_original", "TEXT", false, func(f Follower) interface{} { return f.Handle }, false},
+}
+
+// storedAvatar returns the form an avatar URL is stored in.
+func storedAvatar(avatar string) string {
+	if normalizeAvatars {
+		return stripAvatarHost(avatar)
+	}
+	return avatar
+}
+
+// maxDescriptionLen is set by -max-description-len: descriptions longer than
+// this many characters are truncated before they are stored. 0 stores them whole.
+var maxDescriptionLen int
+
+// storedDescription returns the form a description is stored in.
+func storedDescription(description string) string {
+	return truncateRunes(description, maxDescriptionLen)
+}
+
+// truncateRunes shortens s to at most max runes, the last of them an
+// ellipsis, so multi-byte characters are never split. A non-positive max
+// leaves s unchanged.
+func truncateRunes(s string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
+
+// normalizeHandle returns the form handles are stored and compared in. Handles
+// are case-insensitive, so Alice.bsky.social and alice.bsky.social are the same
+// account; the handle as the API returned it is kept in handle_original.
+func normalizeHandle(handle string) string {
+	return strings.ToLower(handle)
+}
+
+// firstSeenColumns are added to tables created before first_seen was recorded.
+var firstSeenColumns = map[string]string{"first_seen": "DATETIME"}
+
+// lastSeenColumns are added to tables created before last_seen was recorded.
+var lastSeenColumns = map[string]string{"last_seen": "DATETIME"}
+
+// handleOriginalColumns are added to tables created before handles were lowercased.
+var handleOriginalColumns = map[string]string{"handle_original": "TEXT"}
+
+// seqColumns are added to tables created before rows carried a fetch sequence.
+var seqColumns = map[string]string{"fetch_seq": "INTEGER"}
+
+// minimalColumns is the -minimal schema, keeping only what identifies a follower.
+var minimalColumns = fullColumns[:2]
+
+// followerColumns is the schema in use for the followers table.
+var followerColumns = fullColumns
+
+// actorFollowersTable holds followers of several actors in one database, keyed by
+// actor and follower so an account following more than one actor is kept once per actor.
+const actorFollowersTable = "actor_followers"
+
+// actorColumns returns the multi-actor schema, binding actor to every row.
+func actorColumns(actor string) []column {
+	columns := []column{{"actor_did", "TEXT", true, func(Follower) interface{} { return actor }, false}}
+	return append(columns, fullColumns...)
+}
+
+// actorFollowersDDL returns the statement creating the multi-actor followers table.
+func actorFollowersDDL() (string, error) {
+	table, err := quoteIdent(actorFollowersTable)
+	if err != nil {
+		return "", err
+	}
+	return createTableSQL(table, actorColumns("")), nil
+}
+
+// keyedColumns returns a copy of columns with key as the only key column.
+func keyedColumns(columns []column, key string) ([]column, error) {
+	if !hasColumn(columns, key) {
+		return nil, fmt.Errorf("unknown key column %q", key)
+	}
+	keyed := make([]column, len(columns))
+	for i, col := range columns {
+		col.key = col.name == key
+		keyed[i] = col
+	}
+	return keyed, nil
+}
+
+// hasColumn reports whether columns include one named name.
+func hasColumn(columns []column, name string) bool {
+	for _, col := range columns {
+		if col.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// createTableSQL returns the CREATE TABLE statement for table with the given columns.
+func createTableSQL(table string, columns []column) string {
+	var defs, keys []string
+	for _, col := range columns {
+		defs = append(defs, col.name+" "+col.typ)
+		if col.key {
+			keys = append(keys, col.name)
+		}
+	}
+	if len(keys) > 0 {
+		defs = append(defs, "PRIMARY KEY ("+strings.Join(keys, ", ")+")")
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n);", table, strings.Join(defs, ",\n\t"))
+}
+
+// insertSQL returns the upsert statement binding every column for table. A
+// row whose key columns match an existing one updates it in place, so only
+// the crawled columns change: a preserved column keeps its stored value, and
+// columns other steps added, such as those of the enrich pass, are untouched.
+func insertSQL(table string, columns []column) string {
+	var names, placeholders, keys, updates []string
+	for _, col := range columns {
+		names = append(names, col.name)
+		placeholders = append(placeholders, "?")
+		switch {
+		case col.key:
+			keys = append(keys, col.name)
+		case !col.preserve:
+			updates = append(updates, fmt.Sprintf("%[1]s = excluded.%[1]s", col.name))
+		}
+	}
+	// A table without keys, such as the observations log, only ever appends.
+	var conflict string
+	switch {
+	case len(keys) > 0 && len(updates) > 0:
+		conflict = fmt.Sprintf("\n\t\tON CONFLICT (%s) DO UPDATE SET %s", strings.Join(keys, ", "), strings.Join(updates, ", "))
+	case len(keys) > 0:
+		conflict = fmt.Sprintf("\n\t\tON CONFLICT (%s) DO NOTHING", strings.Join(keys, ", "))
+	}
+	return fmt.Sprintf(`
+		INSERT INTO %s (%s)
+		VALUES (%s)%s;
+	`, table, strings.Join(names, ", "), strings.Join(placeholders, ", "), conflict)
+}
+
+// columnValues returns the values bound for follower in the order insertSQL
+// expects them.
+func columnValues(columns []column, follower Follower) []interface{} {
+	values := make([]interface{}, 0, len(columns))
+	for _, col := range columns {
+		values = append(values, col.value(follower))
+	}
+	return values
+}
+
+// formatLabels converts labels to a comma-separated string of "type:value".
+func formatLabels(labels []Label) string {
+	parts := make([]string, 0, len(labels))
+	for _, label := range labels {
+		parts = append(parts, fmt.Sprintf("%s:%s", label.Type, label.Value))
+	}
+	return strings.Join(parts, ",")
+}
+
+// schemaOptions selects the optional tables a run creates.
+type schemaOptions struct {
+	mode           string
+	cursorLog      bool
+	enrich         bool
+	dedupeHandles  bool
+	multiActor     bool
+	validateDID    bool
+	storeEngine    string
+	detectLanguage bool
+}
+
+// schemaStatements returns the DDL executed for the selected options, in order.
+func schemaStatements(opts schemaOptions) ([]string, error) {
+	table, err := quoteIdent(tableName)
+	if err != nil {
+		return nil, err
+	}
+	statements := []string{
+		`PRAGMA journal_mode=WAL;`,
+		createTableSQL(table, followerColumns),
+	}
+
+	builders := []func() (string, error){runsDDL, labelsDDL, changesDDL, pageHashesDDL, metadataDDL, identitiesDDL}
+	if opts.multiActor {
+		builders = append(builders, actorFollowersDDL)
+	}
+	if opts.cursorLog {
+		builders = append(builders, cursorLogDDL)
+	}
+	if opts.mode == "following" {
+		builders = append(builders, followingDDL)
+	}
+	if opts.mode == "known-followers" {
+		builders = append(builders, knownFollowersDDL)
+	}
+	if opts.mode == "suggestions" {
+		builders = append(builders, suggestionsDDL)
+	}
+	if _, ok := listEndpoints[opts.mode]; ok {
+		builders = append(builders, listSubscriptionsDDL)
+	}
+	if opts.dedupeHandles {
+		builders = append(builders, aliasesDDL)
+	}
+	if opts.validateDID {
+		builders = append(builders, invalidFollowersDDL)
+	}
+	if opts.enrich {
+		builders = append(builders, enrichFailuresDDL)
+	}
+	if opts.storeEngine == storeEngineLog {
+		builders = append(builders, observationsDDL)
+	}
+	for _, build := range builders {
+		ddl, err := build()
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, splitDDL(ddl)...)
+	}
+
+	if opts.enrich {
+		names := make([]string, 0, len(enrichColumns))
+		for name := range enrichColumns {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, name, enrichColumns[name]))
+		}
+	}
+
+	if opts.detectLanguage {
+		for name, typ := range languageColumns {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, name, typ))
+		}
+	}
+
+	return statements, nil
+}
+
+// splitDDL splits a multi-statement DDL string into individually formatted
+// statements, re-indenting column definitions with a single tab.
+func splitDDL(ddl string) []string {
+	var statements []string
+	var lines []string
+	for _, line := range strings.Split(ddl, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "CREATE") && !strings.HasPrefix(line, ")") {
+			line = "\t" + line
+		}
+		lines = append(lines, line)
+		if strings.HasSuffix(line, ";") {
+			statements = append(statements, strings.Join(lines, "\n"))
+			lines = nil
+		}
+	}
+	return statements
+}
+
+// printSchema writes the DDL for the selected options to w.
+func printSchema(w io.Writer, opts schemaOptions) error {
+	statements, err := schemaStatements(opts)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := fmt.Fprintf(w, "%s\n\n", statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
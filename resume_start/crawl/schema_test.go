@@ -0,0 +1,131 @@
+package crawl
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// useColumns switches the followers schema to columns for the rest of the test.
+func useColumns(t *testing.T, columns []column) {
+	t.Helper()
+	saved := followerColumns
+	followerColumns = columns
+	t.Cleanup(func() { followerColumns = saved })
+}
+
+// columnNames returns the sorted column names of table (already quoted) in db.
+func columnNames(t *testing.T, db dbtx, table string) []string {
+	t.Helper()
+	existing, err := tableColumns(context.Background(), db, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range existing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestMinimalTableHasOnlyIdentity(t *testing.T) {
+	useColumns(t, minimalColumns)
+	db := openTestDB(t)
+
+	if got := columnNames(t, db, `"followers"`); !reflect.DeepEqual(got, []string{"did", "handle"}) {
+		t.Fatalf("minimal followers columns = %v, want exactly did and handle", got)
+	}
+
+	seedFollowers(t, db, Follower{DID: "did:plc:a", Handle: "A.test", DisplayName: "dropped"})
+	f, ok, err := newSQLiteStore(db, tableName, followerColumns).Get(context.Background(), "did:plc:a")
+	if err != nil || !ok {
+		t.Fatalf("Get = %v, %v", ok, err)
+	}
+	if f.Handle != "a.test" || f.DisplayName != "" {
+		t.Errorf("stored follower = %+v", f)
+	}
+}
+
+func TestPrintSchemaListsColumns(t *testing.T) {
+	var out strings.Builder
+	if err := printSchema(&out, schemaOptions{cursorLog: true, enrich: true}); err != nil {
+		t.Fatalf("printSchema: %v", err)
+	}
+	ddl := out.String()
+	for _, want := range []string{`CREATE TABLE IF NOT EXISTS "followers"`, "did TEXT", "handle TEXT", "displayName", "avatar", "description", "indexedAt", "cursor_log", "followersCount"} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("schema is missing %q:\n%s", want, ddl)
+		}
+	}
+	if strings.Contains(ddl, `"following"`) {
+		t.Errorf("schema of the default mode includes the following table:\n%s", ddl)
+	}
+}
+
+func TestHandleKeyMakesSharedHandlesConflict(t *testing.T) {
+	columns, err := keyedColumns(followerColumns, "handle")
+	if err != nil {
+		t.Fatalf("keyedColumns: %v", err)
+	}
+	useColumns(t, columns)
+	db := openTestDB(t)
+
+	seedFollowers(t, db, Follower{DID: "did:plc:old", Handle: "alice.test", DisplayName: "Old"})
+	seedFollowers(t, db, Follower{DID: "did:plc:new", Handle: "alice.test", DisplayName: "New"})
+
+	var n int
+	var did, name string
+	if err := db.QueryRow(`SELECT COUNT(*), did, displayName FROM followers;`).Scan(&n, &did, &name); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || did != "did:plc:new" || name != "New" {
+		t.Errorf("followers = %d row(s), last %s %q; want the second DID to replace the first", n, did, name)
+	}
+}
+
+func TestKeyedColumnsRejectsUnknownKey(t *testing.T) {
+	if _, err := keyedColumns(followerColumns, "email"); err == nil {
+		t.Error("keyedColumns accepted a column that doesn't exist")
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"héllo wörld", 0, "héllo wörld"},
+		{"héllo wörld", 11, "héllo wörld"},
+		{"héllo wörld", 7, "héllo …"},
+		{"日本語のプロフィール", 4, "日本語…"},
+		{"🌍🎉👋", 2, "🌍…"},
+		{"ab", 1, "…"},
+	} {
+		got := truncateRunes(tc.s, tc.max)
+		if got != tc.want || !utf8.ValidString(got) {
+			t.Errorf("truncateRunes(%q, %d) = %q, want %q", tc.s, tc.max, got, tc.want)
+		}
+	}
+}
+
+func TestMaxDescriptionLenTruncatesStoredBio(t *testing.T) {
+	saved := maxDescriptionLen
+	t.Cleanup(func() { maxDescriptionLen = saved })
+	maxDescriptionLen = 10
+
+	db := openTestDB(t)
+	long := strings.Repeat("Ünïcödé ", 100)
+	seedFollowers(t, db, Follower{DID: "did:plc:a", Handle: "a.test", Description: long})
+	var stored string
+	if err := db.QueryRow(`SELECT description FROM followers WHERE did = 'did:plc:a';`).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Ünïcödé Ü…"; stored != want {
+		t.Errorf("stored description = %q, want %q", stored, want)
+	}
+}
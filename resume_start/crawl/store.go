@@ -0,0 +1,193 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Store is where a crawl saves followers.
+type Store interface {
+	// Init prepares the store for saving, creating its table if needed.
+	Init(ctx context.Context) error
+	// Save adds or replaces followers, keyed by DID, all or none of them.
+	Save(ctx context.Context, followers []Follower) error
+	// Count returns the number of followers stored.
+	Count(ctx context.Context) (int, error)
+	// Get returns the stored follower with did, and whether there is one.
+	Get(ctx context.Context, did string) (Follower, bool, error)
+	// Close releases what the store owns. A store given a database by its
+	// caller leaves it open.
+	Close() error
+}
+
+// sqliteStore stores followers in a table of a SQLite database with the given
+// schema. Key columns other than did, such as the actor of the multi-actor
+// table, scope the store to the rows they are bound to.
+type sqliteStore struct {
+	db      *sql.DB
+	table   string
+	columns []column
+	ownsDB  bool // db was opened by the store, so Close closes it
+}
+
+// OpenSQLiteStore opens the SQLite database at path, creating it if needed, and
+// returns a store of its followers table. Closing the store closes the database.
+func OpenSQLiteStore(path string) (Store, error) {
+	db, err := initializeDB(path)
+	if err != nil {
+		return nil, err
+	}
+	s := newSQLiteStore(db, tableName, followerColumns)
+	s.ownsDB = true
+	return s, nil
+}
+
+// newSQLiteStore returns a store writing to table of db. The database is
+// borrowed: closing the store leaves it open for its other users.
+func newSQLiteStore(db *sql.DB, table string, columns []column) *sqliteStore {
+	return &sqliteStore{db: db, table: table, columns: columns}
+}
+
+func (s *sqliteStore) Init(ctx context.Context) error {
+	table, err := quoteIdent(s.table)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, createTableSQL(table, s.columns)); err != nil {
+		return fmt.Errorf("failed to create %s table: %w", s.table, err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) Save(ctx context.Context, followers []Follower) error {
+	return saveFollowersTo(ctx, s.db, s.table, s.columns, followers)
+}
+
+// scope returns the WHERE conditions and arguments selecting this store's rows.
+func (s *sqliteStore) scope() (conditions []string, args []interface{}) {
+	for _, col := range s.columns {
+		if col.key && col.name != "did" {
+			conditions = append(conditions, col.name+" = ?")
+			args = append(args, col.value(Follower{}))
+		}
+	}
+	return conditions, args
+}
+
+func (s *sqliteStore) Count(ctx context.Context) (int, error) {
+	table, err := quoteIdent(s.table)
+	if err != nil {
+		return 0, err
+	}
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, table)
+	conditions, args := s.scope()
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	var n int
+	if err := s.db.QueryRowContext(ctx, query+";", args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count followers: %w", err)
+	}
+	return n, nil
+}
+
+// Get reads the identity and profile text of a follower; the columns missing
+// from a -minimal schema are left empty.
+func (s *sqliteStore) Get(ctx context.Context, did string) (Follower, bool, error) {
+	return s.get(ctx, did, "")
+}
+
+// get is Get with suffix, such as an ORDER BY clause, appended to the query
+// to pick among several rows of the follower.
+func (s *sqliteStore) get(ctx context.Context, did, suffix string) (Follower, bool, error) {
+	table, err := quoteIdent(s.table)
+	if err != nil {
+		return Follower{}, false, err
+	}
+	var f Follower
+	fields := map[string]interface{}{
+		"did":         &f.DID,
+		"handle":      &f.Handle,
+		"displayName": &f.DisplayName,
+		"avatar":      &f.Avatar,
+		"description": &f.Description,
+	}
+	var names []string
+	var dest []interface{}
+	for _, col := range s.columns {
+		if field, ok := fields[col.name]; ok {
+			names = append(names, "COALESCE("+col.name+", '')")
+			dest = append(dest, field)
+		}
+	}
+
+	conditions, args := s.scope()
+	conditions = append(conditions, "did = ?")
+	args = append(args, did)
+	err = s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT %s FROM %s WHERE %s %s;`,
+		strings.Join(names, ", "), table, strings.Join(conditions, " AND "), suffix), args...).Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Follower{}, false, nil
+	}
+	if err != nil {
+		return Follower{}, false, fmt.Errorf("failed to read follower %s: %w", did, err)
+	}
+	return f, true, nil
+}
+
+func (s *sqliteStore) Close() error {
+	if !s.ownsDB {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// memoryStore keeps followers in a map, for embedding programs and tests that
+// have no use for a database file.
+type memoryStore struct {
+	mu        sync.Mutex
+	followers map[string]Follower
+}
+
+// NewMemoryStore returns an empty store keeping followers in memory.
+func NewMemoryStore() Store {
+	return newMemoryStore()
+}
+
+// newMemoryStore returns an empty in-memory store.
+func newMemoryStore() *memoryStore {
+	return &memoryStore{followers: make(map[string]Follower)}
+}
+
+func (s *memoryStore) Init(context.Context) error { return nil }
+
+func (s *memoryStore) Save(ctx context.Context, followers []Follower) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range followers {
+		s.followers[f.DID] = f
+	}
+	return nil
+}
+
+func (s *memoryStore) Count(context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.followers), nil
+}
+
+func (s *memoryStore) Get(_ context.Context, did string) (Follower, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.followers[did]
+	return f, ok, nil
+}
+
+func (s *memoryStore) Close() error { return nil }
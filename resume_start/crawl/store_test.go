@@ -0,0 +1,67 @@
+package crawl
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestCrawlIntoMemoryStore(t *testing.T) {
+	store := newMemoryStore()
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 2), testFollowers("b", 3)}}
+	c := newTestCrawler(t, store, api)
+	ctx := context.Background()
+
+	stats, err := c.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stats.ID != 0 || stats.FollowersSeen != 5 {
+		t.Errorf("run %d saw %d followers, want an unrecorded run of 5", stats.ID, stats.FollowersSeen)
+	}
+	if n, err := store.Count(ctx); err != nil || n != 5 {
+		t.Fatalf("Count = %d, %v; want 5", n, err)
+	}
+	for _, want := range append(testFollowers("a", 2), testFollowers("b", 3)...) {
+		got, ok, err := store.Get(ctx, want.DID)
+		if err != nil || !ok {
+			t.Errorf("Get(%s) = %v, %v; want the saved follower", want.DID, ok, err)
+			continue
+		}
+		if got.Handle != want.Handle {
+			t.Errorf("Get(%s).Handle = %q, want %q", want.DID, got.Handle, want.Handle)
+		}
+	}
+	if _, ok, err := store.Get(ctx, "did:plc:missing"); ok || err != nil {
+		t.Errorf("Get of an unknown DID = %v, %v; want not found", ok, err)
+	}
+
+	// A second crawl updates the followers in place.
+	if _, err := c.Run(ctx); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if n, _ := store.Count(ctx); n != 5 {
+		t.Errorf("Count after a second crawl = %d, want 5", n)
+	}
+}
+
+func TestClosingStoreLeavesBorrowedDBOpen(t *testing.T) {
+	db := openTestDB(t)
+	if err := newSQLiteStore(db, tableName, followerColumns).Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Errorf("database closed with the store that borrowed it: %v", err)
+	}
+
+	owned, err := OpenSQLiteStore(filepath.Join(t.TempDir(), dbFile))
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore: %v", err)
+	}
+	if err := owned.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := owned.(*sqliteStore).db.Ping(); err == nil {
+		t.Error("store left the database it opened open")
+	}
+}
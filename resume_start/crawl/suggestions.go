@@ -0,0 +1,132 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+const (
+	suggestionsTable = "suggestions"
+)
+
+// SuggestionsResponse represents the structure of the getSuggestedFollowsByActor response.
+type SuggestionsResponse struct {
+	Suggestions []Follower `json:"suggestions"`
+}
+
+// initializeSuggestions creates the table holding suggested-follow snapshots. Each
+// row is a point-in-time observation, so the same DID can appear once per snapshot.
+func initializeSuggestions(db *sql.DB) error {
+	ddl, err := suggestionsDDL()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create suggestions table: %w", err)
+	}
+	return nil
+}
+
+// suggestionsDDL returns the statements creating the suggestions table.
+func suggestionsDDL() (string, error) {
+	table, err := quoteIdent(suggestionsTable)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			did TEXT,
+			handle TEXT,
+			displayName TEXT,
+			avatar TEXT,
+			labels TEXT,
+			createdAt DATETIME,
+			description TEXT,
+			indexedAt DATETIME,
+			observed_at DATETIME,
+			PRIMARY KEY (did, observed_at)
+		);
+	`, table), nil
+}
+
+// runSuggestions captures a snapshot of the accounts Bluesky suggests alongside the actor.
+func runSuggestions(ctx context.Context, db *sql.DB, client *apiClient, actor string) error {
+	if err := initializeSuggestions(db); err != nil {
+		return err
+	}
+
+	suggestions, err := fetchSuggestions(ctx, client, actor)
+	if err != nil {
+		return err
+	}
+
+	observedAt := time.Now().UTC()
+	if err := saveSuggestions(ctx, db, suggestions, observedAt); err != nil {
+		return err
+	}
+	log.Printf("Saved snapshot of %d suggestions observed at %s.\n", len(suggestions), observedAt.Format(time.RFC3339))
+	return nil
+}
+
+// fetchSuggestions requests the suggested follows for the actor.
+func fetchSuggestions(ctx context.Context, client *apiClient, actor string) ([]Follower, error) {
+	var suggestionsResp SuggestionsResponse
+	if err := client.getJSON(ctx, client.xrpcURL("app.bsky.graph.getSuggestedFollowsByActor", url.Values{"actor": {actor}}), &suggestionsResp); err != nil {
+		return nil, err
+	}
+
+	return suggestionsResp.Suggestions, nil
+}
+
+// saveSuggestions stores a snapshot of suggested profiles in a single transaction.
+func saveSuggestions(ctx context.Context, db *sql.DB, suggestions []Follower, observedAt time.Time) error {
+	table, err := quoteIdent(suggestionsTable)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+		INSERT OR REPLACE INTO %s (did, handle, displayName, avatar, labels, createdAt, description, indexedAt, observed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+	`, table))
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, suggestion := range suggestions {
+		_, err := stmt.Exec(
+			suggestion.DID,
+			suggestion.Handle,
+			suggestion.DisplayName,
+			suggestion.Avatar,
+			formatLabels(suggestion.Labels),
+			suggestion.CreatedAt.Time,
+			suggestion.Description,
+			suggestion.IndexedAt.Time,
+			observedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save suggestion %s: %w", suggestion.DID, err)
+		}
+	}
+	if err := recordIdentities(ctx, tx, followerIdentities(suggestions), observedAt); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
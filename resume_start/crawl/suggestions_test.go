@@ -0,0 +1,37 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestRunSuggestionsSnapshots(t *testing.T) {
+	db := openTestDB(t)
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/xrpc/app.bsky.graph.getSuggestedFollowsByActor" || r.URL.Query().Get("actor") != "alice.test" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"suggestions":[{"did":"did:plc:s1","handle":"s1.test","displayName":"S1"},{"did":"did:plc:s2","handle":"s2.test"}]}`)
+	}))
+
+	for i := 0; i < 2; i++ {
+		if err := runSuggestions(context.Background(), db, client, "alice.test"); err != nil {
+			t.Fatalf("runSuggestions: %v", err)
+		}
+	}
+
+	var rows, snapshots int
+	if err := db.QueryRow(`SELECT COUNT(*), COUNT(DISTINCT observed_at) FROM suggestions;`).Scan(&rows, &snapshots); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 4 || snapshots != 2 {
+		t.Fatalf("suggestions = %d rows in %d snapshots, want 4 in 2", rows, snapshots)
+	}
+	var name string
+	if err := db.QueryRow(`SELECT displayName FROM suggestions WHERE did = 'did:plc:s1' LIMIT 1;`).Scan(&name); err != nil || name != "S1" {
+		t.Fatalf("displayName = %q, %v", name, err)
+	}
+}
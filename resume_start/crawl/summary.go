@@ -0,0 +1,91 @@
+package crawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Stats summarizes one crawl run. It is returned by Crawler.Run and printed as
+// the end-of-run report.
+type Stats struct {
+	ID            int64
+	Status        string
+	StartCursor   string
+	StartedAt     time.Time
+	FinishedAt    time.Time
+	FollowersSeen int
+	NewFollowers  int
+	LostFollowers int
+	HandleChanges int
+	Changes       []followerChange // what changed since the previous run
+	Err           error
+}
+
+// MarshalJSON renders the stats with snake_case keys, the duration in seconds
+// and the error as a string, so wrapping scripts don't depend on Go's encoding.
+func (s Stats) MarshalJSON() ([]byte, error) {
+	out := struct {
+		ID              int64            `json:"run_id"`
+		Status          string           `json:"status"`
+		StartCursor     string           `json:"start_cursor"`
+		StartedAt       string           `json:"started_at"`
+		FinishedAt      string           `json:"finished_at"`
+		DurationSeconds float64          `json:"duration_seconds"`
+		FollowersSeen   int              `json:"followers_seen"`
+		NewFollowers    int              `json:"new_followers"`
+		LostFollowers   int              `json:"lost_followers"`
+		HandleChanges   int              `json:"handle_changes"`
+		Changes         []followerChange `json:"changes"`
+		Error           string           `json:"error,omitempty"`
+	}{
+		ID:              s.ID,
+		Status:          s.Status,
+		StartCursor:     s.StartCursor,
+		StartedAt:       s.StartedAt.UTC().Format(time.RFC3339),
+		FinishedAt:      s.FinishedAt.UTC().Format(time.RFC3339),
+		DurationSeconds: s.FinishedAt.Sub(s.StartedAt).Seconds(),
+		FollowersSeen:   s.FollowersSeen,
+		NewFollowers:    s.NewFollowers,
+		LostFollowers:   s.LostFollowers,
+		HandleChanges:   s.HandleChanges,
+		Changes:         s.Changes,
+	}
+	if out.Changes == nil {
+		out.Changes = []followerChange{}
+	}
+	if s.Err != nil {
+		out.Error = s.Err.Error()
+	}
+	return json.Marshal(out)
+}
+
+// summaryFormats are the accepted values of -summary-format.
+var summaryFormats = map[string]bool{"text": true, "json": true}
+
+// writeSummary writes the end-of-run report in the given format: a single JSON
+// object per line, or a human-readable line.
+func writeSummary(w io.Writer, format string, s Stats) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(s)
+	}
+
+	_, err := fmt.Fprintf(w, "Run %d %s in %v: %d followers seen, %d new, %d lost, %d handle changes.\n",
+		s.ID, s.Status, s.FinishedAt.Sub(s.StartedAt).Round(time.Millisecond), s.FollowersSeen, s.NewFollowers, s.LostFollowers, s.HandleChanges)
+	for _, change := range s.Changes {
+		if err != nil {
+			break
+		}
+		switch change.Kind {
+		case changeHandle:
+			_, err = fmt.Fprintf(w, "  %s: %s -> %s (%s)\n", change.Kind, change.OldHandle, change.Handle, change.DID)
+		default:
+			_, err = fmt.Fprintf(w, "  %s: %s (%s)\n", change.Kind, change.Handle, change.DID)
+		}
+	}
+	if err == nil && s.Err != nil {
+		_, err = fmt.Fprintf(w, "Error: %v\n", s.Err)
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package crawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummaryJSONKeys(t *testing.T) {
+	started := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := Stats{
+		ID:            7,
+		Status:        "failed",
+		StartedAt:     started,
+		FinishedAt:    started.Add(90 * time.Second),
+		FollowersSeen: 120,
+		NewFollowers:  2,
+		Err:           errors.New("page cap reached"),
+	}
+	var buf bytes.Buffer
+	if err := writeSummary(&buf, "json", s); err != nil {
+		t.Fatalf("writeSummary: %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 1 {
+		t.Errorf("summary spans %d lines, want one object on one line", lines)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("summary is not JSON: %v\n%s", err, buf.String())
+	}
+	for _, key := range []string{"run_id", "status", "start_cursor", "started_at", "finished_at", "duration_seconds",
+		"followers_seen", "new_followers", "lost_followers", "handle_changes", "changes", "error"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("summary lacks %q: %s", key, buf.String())
+		}
+	}
+	if got["run_id"] != 7.0 || got["duration_seconds"] != 90.0 || got["started_at"] != "2024-03-01T12:00:00Z" || got["error"] != "page cap reached" {
+		t.Errorf("summary = %s", buf.String())
+	}
+	if changes, ok := got["changes"].([]interface{}); !ok || len(changes) != 0 {
+		t.Errorf("changes = %v, want an empty array", got["changes"])
+	}
+}
+
+func TestSummaryText(t *testing.T) {
+	var buf bytes.Buffer
+	s := Stats{ID: 1, Status: "completed", FollowersSeen: 3, NewFollowers: 1}
+	if err := writeSummary(&buf, "text", s); err != nil {
+		t.Fatalf("writeSummary: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "Run 1 completed in ") || !strings.Contains(buf.String(), "3 followers seen, 1 new") {
+		t.Errorf("text summary = %q", buf.String())
+	}
+}
@@ -0,0 +1,45 @@
+package crawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// bskyTimeLayouts are the timestamp formats the API has been observed to return.
+var bskyTimeLayouts = []string{
+	time.RFC3339Nano,                      // 2024-01-02T03:04:05.678Z or 2024-01-02T03:04:05.678+01:00
+	"2006-01-02T15:04:05.999999999Z0700",  // numeric offset without a colon, e.g. +0100
+	"2006-01-02T15:04:05.999999999",       // no zone, assumed UTC
+	"2006-01-02 15:04:05.999999999Z07:00", // space instead of T
+}
+
+// bskyTime is a timestamp that tolerates the API's format variants and is
+// normalized to UTC. An empty string or null leaves it zero.
+type bskyTime struct {
+	time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *bskyTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("timestamp must be a string: %w", err)
+	}
+	if s == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	for _, layout := range bskyTimeLayouts {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			t.Time = parsed.UTC()
+			return nil
+		}
+	}
+	return fmt.Errorf("unrecognized timestamp format %q", s)
+}
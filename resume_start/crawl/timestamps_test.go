@@ -0,0 +1,55 @@
+package crawl
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestBskyTimeFormats(t *testing.T) {
+	want := time.Date(2024, 1, 2, 3, 4, 5, 678000000, time.UTC)
+	for _, tc := range []struct {
+		json string
+		want time.Time
+	}{
+		{`"2024-01-02T03:04:05.678Z"`, want},
+		{`"2024-01-02T04:04:05.678+01:00"`, want},
+		{`"2024-01-02T04:04:05.678+0100"`, want},
+		{`"2024-01-02T03:04:05.678"`, want},
+		{`"2024-01-02 03:04:05.678Z"`, want},
+		{`"2024-01-02T03:04:05Z"`, want.Truncate(time.Second)},
+		{`""`, time.Time{}},
+		{`null`, time.Time{}},
+	} {
+		var got bskyTime
+		if err := json.Unmarshal([]byte(tc.json), &got); err != nil {
+			t.Errorf("unmarshal %s: %v", tc.json, err)
+			continue
+		}
+		if !got.Equal(tc.want) || got.Location() != time.UTC {
+			t.Errorf("unmarshal %s = %v, want %v", tc.json, got.Time, tc.want)
+		}
+	}
+}
+
+func TestBskyTimeRejectsUnknownFormat(t *testing.T) {
+	for _, data := range []string{`"02/01/2024"`, `1704164645`} {
+		var got bskyTime
+		if err := json.Unmarshal([]byte(data), &got); err == nil {
+			t.Errorf("unmarshal %s = %v, want an error", data, got.Time)
+		}
+	}
+}
+
+func TestFollowerTimestampsParsed(t *testing.T) {
+	var f Follower
+	if err := json.Unmarshal([]byte(`{"did":"did:plc:a","handle":"a.test","createdAt":"2023-05-06T07:08:09.000Z","indexedAt":""}`), &f); err != nil {
+		t.Fatalf("unmarshal follower: %v", err)
+	}
+	if want := time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC); !f.CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %v, want %v", f.CreatedAt.Time, want)
+	}
+	if !f.IndexedAt.IsZero() {
+		t.Errorf("IndexedAt = %v, want zero for an empty string", f.IndexedAt.Time)
+	}
+}
@@ -0,0 +1,35 @@
+package crawl
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCrawlProducesSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+	saved := tracer
+	tracer = provider.Tracer("test")
+	defer func() { tracer = saved }()
+
+	db := openTestDB(t)
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 2), testFollowers("b", 1)}}
+	c := newTestCrawler(t, newSQLiteStore(db, tableName, followerColumns), api)
+	if _, err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for _, span := range exporter.GetSpans() {
+		counts[span.Name]++
+	}
+	for name, want := range map[string]int{"crawl": 1, "fetchFollowers": 2, "saveFollowers": 2} {
+		if counts[name] != want {
+			t.Errorf("%d %s spans, want %d (got %v)", counts[name], name, want, counts)
+		}
+	}
+}
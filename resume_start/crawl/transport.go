@@ -0,0 +1,224 @@
+package crawl
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// transportOptions configures the base transport.
+type transportOptions struct {
+	caFile              string
+	insecure            bool
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	http2               bool
+	pinSHA256           string // base64 or hex SHA-256 of the API server's public key
+	pinHost             string // the API host pinSHA256 applies to; other hosts are not pinned
+}
+
+// newTransport returns the base transport for API requests. It always honours
+// HTTPS_PROXY/HTTP_PROXY/NO_PROXY, trusts the certificates in caFile in addition
+// to the system roots, and skips verification entirely when insecure is set.
+// Idle connections are kept per host so a long crawl of the same service reuses
+// them, and HTTP/2 is negotiated unless disabled. With a pin, connections to
+// pinHost are only accepted from servers whose certificate carries the pinned
+// public key; the same transport also reaches avatar CDNs and webhooks, which
+// are verified as usual.
+func newTransport(opts transportOptions) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if opts.maxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = opts.maxIdleConnsPerHost
+	}
+	if opts.idleConnTimeout > 0 {
+		transport.IdleConnTimeout = opts.idleConnTimeout
+	}
+	transport.ForceAttemptHTTP2 = opts.http2
+	if !opts.http2 {
+		// A non-nil empty map is how net/http is told not to upgrade to HTTP/2.
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	caFile, insecure := opts.caFile, opts.insecure
+	if caFile == "" && !insecure && opts.pinSHA256 == "" {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in CA file")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if insecure {
+		log.Println("WARNING: TLS certificate verification is DISABLED (-insecure-skip-verify). Connections can be intercepted; never use this outside debugging.")
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if opts.pinSHA256 != "" {
+		pin, err := parsePin(opts.pinSHA256)
+		if err != nil {
+			return nil, err
+		}
+		// Connections to IP addresses carry no server name to tell the API
+		// host from any other.
+		if opts.pinHost == "" || net.ParseIP(opts.pinHost) != nil {
+			return nil, fmt.Errorf("-pin-sha256 needs a service URL with a host name, not %q", opts.pinHost)
+		}
+		tlsConfig.VerifyConnection = verifyPin(pin, opts.pinHost)
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
+
+// parsePin decodes a public key pin given as base64, as in HTTP public key
+// pinning, or as hex.
+func parsePin(pin string) ([]byte, error) {
+	if b, err := hex.DecodeString(pin); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	if b, err := base64.StdEncoding.DecodeString(pin); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	return nil, fmt.Errorf("invalid -pin-sha256 %q, expected the base64 or hex SHA-256 of a public key", pin)
+}
+
+// serviceHost returns the host name of serviceURL, or an empty string if it
+// has none.
+func serviceHost(serviceURL string) string {
+	u, err := url.Parse(serviceURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// verifyPin returns a connection check accepting host only from servers whose
+// leaf certificate's SubjectPublicKeyInfo hashes to pin; connections to other
+// hosts pass. It runs after the usual chain verification, so a pinned key does
+// not excuse an untrusted chain unless -insecure-skip-verify turned that off.
+func verifyPin(pin []byte, host string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if !strings.EqualFold(cs.ServerName, host) {
+			return nil
+		}
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("server presented no certificate")
+		}
+		sum := sha256.Sum256(cs.PeerCertificates[0].RawSubjectPublicKeyInfo)
+		if subtle.ConstantTimeCompare(sum[:], pin) != 1 {
+			return fmt.Errorf("server public key %s does not match -pin-sha256", base64.StdEncoding.EncodeToString(sum[:]))
+		}
+		return nil
+	}
+}
+
+// loggingTransport logs every request and response passing through base for
+// wire-level debugging, with credentials redacted.
+type loggingTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	log.Printf("HTTP request: %s %s bytes=%d headers=%v\n", req.Method, req.URL, req.ContentLength, redactHeaders(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		log.Printf("HTTP error: %s %s after %v: %v\n", req.Method, req.URL, time.Since(start), err)
+		return nil, err
+	}
+
+	// The body is read later by the caller, so the byte count is logged on close.
+	resp.Body = &countingBody{
+		ReadCloser: resp.Body,
+		onClose: func(n int64) {
+			log.Printf("HTTP response: %s %s status=%d bytes=%d duration=%v\n", req.Method, req.URL, resp.StatusCode, n, time.Since(start))
+		},
+	}
+	return resp, nil
+}
+
+// redactHeaders returns a copy of h with the Authorization value hidden.
+func redactHeaders(h http.Header) http.Header {
+	redacted := h.Clone()
+	if redacted.Get("Authorization") != "" {
+		redacted.Set("Authorization", "REDACTED")
+	}
+	return redacted
+}
+
+// countingBody counts bytes read from a response body and reports the total once closed.
+type countingBody struct {
+	io.ReadCloser
+	n       int64
+	onClose func(n int64)
+	closed  bool
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		b.onClose(b.n)
+	}
+	return b.ReadCloser.Close()
+}
+
+// connStats counts API requests and how many of them reused a pooled connection.
+type connStats struct {
+	requests atomic.Int64
+	reused   atomic.Int64
+}
+
+// trace attaches a ClientTrace recording connection reuse to ctx.
+func (s *connStats) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			s.requests.Add(1)
+			if info.Reused {
+				s.reused.Add(1)
+			}
+		},
+	})
+}
+
+// log reports connection reuse, if any requests were made.
+func (s *connStats) log() {
+	if s.requests.Load() == 0 {
+		return
+	}
+	log.Printf("HTTP connections: %d requests, %d on a reused connection.\n", s.requests.Load(), s.reused.Load())
+}
@@ -0,0 +1,236 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// captureLog sends the standard logger's output to the returned buffer for
+// the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	w, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(w)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func TestLoggingTransportLogsPair(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"followers":[]}`)
+	}))
+	defer srv.Close()
+	logs := captureLog(t)
+
+	client := &http.Client{Transport: &loggingTransport{base: http.DefaultTransport}}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/xrpc/app.bsky.graph.getFollowers", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	out := logs.String()
+	if !strings.Contains(out, "HTTP request: GET "+srv.URL+"/xrpc/app.bsky.graph.getFollowers") {
+		t.Errorf("request not logged:\n%s", out)
+	}
+	if !strings.Contains(out, "status=200 bytes=16") {
+		t.Errorf("response not logged with status and size:\n%s", out)
+	}
+	if strings.Contains(out, "secret-token") || !strings.Contains(out, "REDACTED") {
+		t.Errorf("Authorization not redacted:\n%s", out)
+	}
+}
+
+// writeCertPEM writes the certificate of srv to a PEM file and returns its path.
+func writeCertPEM(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTransportTrustsCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	transport, err := newTransport(transportOptions{caFile: writeCertPEM(t, srv)})
+	if err != nil {
+		t.Fatalf("newTransport: %v", err)
+	}
+	if transport.TLSClientConfig == nil || transport.TLSClientConfig.RootCAs == nil {
+		t.Fatal("CA file was not loaded into the TLS config")
+	}
+	if transport.Proxy == nil {
+		t.Error("transport does not honour HTTPS_PROXY")
+	}
+	resp, err := (&http.Client{Transport: transport}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request to a server signed by the CA file: %v", err)
+	}
+	resp.Body.Close()
+
+	plain, err := newTransport(transportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&http.Client{Transport: plain}).Get(srv.URL); err == nil {
+		t.Error("request without the CA file succeeded, want a certificate error")
+	}
+}
+
+func TestTransportRejectsBadCAFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(path, []byte("not a certificate"), 0o600)
+	if _, err := newTransport(transportOptions{caFile: path}); err == nil {
+		t.Error("newTransport accepted a CA file without certificates")
+	}
+	if _, err := newTransport(transportOptions{caFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("newTransport accepted a missing CA file")
+	}
+}
+
+func TestTransportInsecureWarns(t *testing.T) {
+	logs := captureLog(t)
+	transport, err := newTransport(transportOptions{insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !transport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("InsecureSkipVerify is not set")
+	}
+	if !strings.Contains(logs.String(), "WARNING") {
+		t.Errorf("no warning logged:\n%s", logs)
+	}
+}
+
+// countingListener counts the connections it accepts.
+type countingListener struct {
+	net.Listener
+	accepted atomic.Int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return conn, err
+}
+
+func TestTransportReusesConnections(t *testing.T) {
+	api := &followersAPI{pages: [][]Follower{testFollowers("a", 1), testFollowers("b", 1), testFollowers("c", 1)}}
+	srv := httptest.NewUnstartedServer(api)
+	listener := &countingListener{Listener: srv.Listener}
+	srv.Listener = listener
+	srv.Start()
+	defer srv.Close()
+
+	transport, err := newTransport(transportOptions{maxIdleConnsPerHost: 4, idleConnTimeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &apiClient{
+		http:        &http.Client{Transport: transport},
+		serviceURL:  srv.URL,
+		maxBodySize: defaultMaxBodySize,
+		limiter:     newRateLimiter(0),
+		sleeper:     &fakeSleeper{},
+		conns:       &connStats{},
+	}
+	cursor := ""
+	for i := 0; i < 3; i++ {
+		_, next, err := fetchFollowers(context.Background(), client, "did:plc:subject", cursor)
+		if err != nil {
+			t.Fatalf("fetchFollowers page %d: %v", i+1, err)
+		}
+		cursor = next
+	}
+
+	if n := listener.accepted.Load(); n != 1 {
+		t.Errorf("server accepted %d connections, want 1 reused for every page", n)
+	}
+	if requests, reused := client.conns.requests.Load(), client.conns.reused.Load(); requests != 3 || reused != 2 {
+		t.Errorf("connStats = %d requests, %d reused; want 3 and 2", requests, reused)
+	}
+}
+
+// pinOf returns the base64 -pin-sha256 of the certificate of srv.
+func pinOf(srv *httptest.Server) string {
+	sum := sha256.Sum256(srv.Certificate().RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestTransportPinsAPIHostOnly(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	caFile := writeCertPEM(t, srv)
+	// example.com is the API host: its name is in the test certificate and it
+	// is dialled at the test server. The server's own 127.0.0.1 URL stands in
+	// for another host, such as an avatar CDN.
+	apiURL := "https://example.com/xrpc/app.bsky.graph.getFollowers"
+	client := func(pin string) *http.Client {
+		t.Helper()
+		transport, err := newTransport(transportOptions{caFile: caFile, pinSHA256: pin, pinHost: serviceHost("https://example.com")})
+		if err != nil {
+			t.Fatalf("newTransport: %v", err)
+		}
+		transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+		}
+		return &http.Client{Transport: transport}
+	}
+
+	wrong := client(base64.StdEncoding.EncodeToString(make([]byte, sha256.Size)))
+	if _, err := wrong.Get(apiURL); err == nil || !strings.Contains(err.Error(), "does not match -pin-sha256") {
+		t.Errorf("API request with a mismatched pin = %v, want it rejected", err)
+	}
+	resp, err := wrong.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request to another host was pinned: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = client(pinOf(srv)).Get(apiURL)
+	if err != nil {
+		t.Fatalf("API request with the matching pin: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestTransportPinNeedsHostName(t *testing.T) {
+	pin := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+	for _, host := range []string{"", "127.0.0.1", "::1"} {
+		if _, err := newTransport(transportOptions{pinSHA256: pin, pinHost: host}); err == nil {
+			t.Errorf("newTransport pinned host %q, want an error", host)
+		}
+	}
+	if _, err := newTransport(transportOptions{pinSHA256: "not-a-pin", pinHost: "example.com"}); err == nil {
+		t.Error("newTransport accepted a malformed pin")
+	}
+}
@@ -0,0 +1,142 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// version is the release this binary was built from, set at build time with
+// -ldflags "-X github.com/baditaflorin/bluesky/resume_start/crawl.version=v1.2.3".
+// Builds without it report "dev".
+var version = "dev"
+
+// latestReleaseURL is the GitHub API endpoint describing the latest release.
+const latestReleaseURL = "https://api.github.com/repos/baditaflorin/bluesky/releases/latest"
+
+// updateCheckTimeout bounds the -check-update request.
+const updateCheckTimeout = 10 * time.Second
+
+// release is the part of a GitHub release the update check reads.
+type release struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+}
+
+// checkUpdate looks up the latest release at releaseURL and writes to w
+// whether it is newer than current. It never installs anything. A failed
+// lookup is logged as a warning, as the check is only a convenience.
+func checkUpdate(ctx context.Context, client *http.Client, releaseURL, current string, w io.Writer) {
+	latest, err := fetchLatestRelease(ctx, client, releaseURL)
+	if err != nil {
+		log.Printf("Warning: failed to check for updates: %v", err)
+		return
+	}
+
+	newer, err := compareVersions(latest.TagName, current)
+	switch {
+	case err != nil:
+		fmt.Fprintf(w, "Running %s; the latest release is %s %s\n", current, latest.TagName, latest.HTMLURL)
+	case newer > 0:
+		fmt.Fprintf(w, "A newer version is available: %s (running %s) %s\n", latest.TagName, current, latest.HTMLURL)
+	default:
+		fmt.Fprintf(w, "Running %s, the latest version.\n", current)
+	}
+}
+
+// fetchLatestRelease requests the latest release once. It goes through a plain
+// HTTP client rather than the API client, so the -auth-token meant for
+// Bluesky is never sent to GitHub.
+func fetchLatestRelease(ctx context.Context, client *http.Client, releaseURL string) (release, error) {
+	ctx, cancel := context.WithTimeout(ctx, updateCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, releaseURL, nil)
+	if err != nil {
+		return release{}, fmt.Errorf("failed to build release request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return release{}, fmt.Errorf("failed to request latest release: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return release{}, newStatusError(resp)
+	}
+
+	var latest release
+	if err := decodeBody(resp.Body, defaultMaxBodySize, &latest); err != nil {
+		return release{}, err
+	}
+	if latest.TagName == "" {
+		return release{}, errors.New("latest release has no tag")
+	}
+	return latest, nil
+}
+
+// semver is a parsed vMAJOR.MINOR.PATCH[-PRERELEASE] version; build metadata
+// is ignored.
+type semver struct {
+	parts      [3]int
+	prerelease string
+}
+
+// parseSemver parses v, with or without its leading v. Missing minor and
+// patch numbers are taken as 0.
+func parseSemver(v string) (semver, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	var sv semver
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s, sv.prerelease = s[:i], s[i+1:]
+	}
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return semver{}, fmt.Errorf("invalid version %q", v)
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return semver{}, fmt.Errorf("invalid version %q", v)
+		}
+		sv.parts[i] = n
+	}
+	return sv, nil
+}
+
+// compareVersions returns a positive number when a is newer than b, a
+// negative one when it is older and 0 when they are the same. A prerelease is
+// older than its release; prereleases of the same release compare as text.
+func compareVersions(a, b string) (int, error) {
+	va, err := parseSemver(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseSemver(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range va.parts {
+		if va.parts[i] != vb.parts[i] {
+			return va.parts[i] - vb.parts[i], nil
+		}
+	}
+	switch {
+	case va.prerelease == vb.prerelease:
+		return 0, nil
+	case va.prerelease == "":
+		return 1, nil
+	case vb.prerelease == "":
+		return -1, nil
+	}
+	return strings.Compare(va.prerelease, vb.prerelease), nil
+}
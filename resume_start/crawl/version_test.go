@@ -0,0 +1,83 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int // the sign of the comparison
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"v1.2.4", "v1.2.3", 1},
+		{"v1.10.0", "v1.9.9", 1},
+		{"v2", "v1.99.99", 1},
+		{"1.2", "v1.2.0", 0},
+		{"v1.2.3", "v1.2.3-rc.1", 1},
+		{"v1.2.3-rc.1", "v1.2.3-rc.2", -1},
+		{"v1.2.3+build.5", "v1.2.3", 0},
+		{"v0.9.0", "v1.0.0", -1},
+	} {
+		got, err := compareVersions(tc.a, tc.b)
+		if err != nil {
+			t.Errorf("compareVersions(%s, %s): %v", tc.a, tc.b, err)
+			continue
+		}
+		if sign(got) != tc.want {
+			t.Errorf("compareVersions(%s, %s) = %d, want sign %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+	for _, v := range []string{"dev", "v1.2.3.4", "v1.x", ""} {
+		if _, err := compareVersions(v, "v1.0.0"); err == nil {
+			t.Errorf("compareVersions accepted %q", v)
+		}
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
+
+func TestCheckUpdate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			http.Error(w, "rate limited", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"tag_name":"v1.3.0","html_url":"https://example.test/releases/v1.3.0"}`)
+	}))
+	t.Cleanup(srv.Close)
+	logs := captureLog(t)
+
+	for _, tc := range []struct{ current, want string }{
+		{"v1.2.9", "A newer version is available: v1.3.0 (running v1.2.9) https://example.test/releases/v1.3.0\n"},
+		{"v1.3.0", "Running v1.3.0, the latest version.\n"},
+		{"v1.4.0-rc.1", "Running v1.4.0-rc.1, the latest version.\n"},
+		{"dev", "Running dev; the latest release is v1.3.0 https://example.test/releases/v1.3.0\n"},
+	} {
+		var buf bytes.Buffer
+		checkUpdate(context.Background(), srv.Client(), srv.URL+"/latest", tc.current, &buf)
+		if buf.String() != tc.want {
+			t.Errorf("running %s printed %q, want %q", tc.current, buf.String(), tc.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	checkUpdate(context.Background(), srv.Client(), srv.URL+"/broken", "v1.2.9", &buf)
+	if buf.Len() != 0 || !strings.Contains(logs.String(), "Warning: failed to check for updates: unexpected status 403 Forbidden: rate limited") {
+		t.Errorf("failed check printed %q and logged:\n%s", buf.String(), logs)
+	}
+}